@@ -25,27 +25,42 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/weaveworks/common/logging"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/component-base/version"
 
+	"github.com/gardener/logging/pkg/chunk"
 	gardenerclientsetversioned "github.com/gardener/logging/pkg/cluster/clientset/versioned"
 	gardeninternalcoreinformers "github.com/gardener/logging/pkg/cluster/informers/externalversions"
 	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/controller"
 	"github.com/gardener/logging/pkg/healthz"
 	"github.com/gardener/logging/pkg/metrics"
+	"github.com/gardener/logging/pkg/toptalkers"
 	"github.com/gardener/logging/pkg/valiplugin"
 )
 
 var (
 	// registered vali plugin instances, required for disposal during shutdown
-	plugins          []valiplugin.Vali
-	pluginsMutex     sync.RWMutex
-	logger           log.Logger
-	informer         cache.SharedIndexInformer
-	informerStopChan chan struct{}
-	pprofOnce        sync.Once
+	plugins             []valiplugin.Vali
+	pluginsMutex        sync.RWMutex
+	logger              log.Logger
+	informer            cache.SharedIndexInformer
+	informerStopChan    chan struct{}
+	podInformer         cache.SharedIndexInformer
+	podInformerStopChan chan struct{}
+	// informer of the namespaces, used by the namespace target source
+	namespaceInformer         cache.SharedIndexInformer
+	namespaceInformerStopChan chan struct{}
+	pprofOnce                 sync.Once
+	// progress of the failed chunks of each plugin instance, guarded by the pluginsMutex
+	chunkProgress = map[valiplugin.Vali]*chunk.Progress{}
 )
 
 func init() {
@@ -58,6 +73,7 @@ func init() {
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
 		http.Handle("/healthz", healthz.Handler("", ""))
+		http.Handle("/debug/top", toptalkers.Handler())
 		if err := http.ListenAndServe(":2021", nil); err != nil {
 			level.Error(logger).Log("Fluent-bit-gardener-output-plugin", err.Error())
 		}
@@ -78,6 +94,86 @@ func initClusterInformer() {
 	}
 }
 
+// Initializes and starts the informer of the namespaces
+func initNamespaceInformer() {
+	if namespaceInformer == nil || namespaceInformer.IsStopped() {
+		c, err := rest.InClusterConfig()
+		if err != nil {
+			panic(err)
+		}
+		kubernetesClient, err := kubernetes.NewForConfig(c)
+		if err != nil {
+			panic(err)
+		}
+		kubeInformerFactory := informers.NewSharedInformerFactory(kubernetesClient, time.Minute*30)
+		namespaceInformer = kubeInformerFactory.Core().V1().Namespaces().Informer()
+		namespaceInformerStopChan = make(chan struct{})
+		kubeInformerFactory.Start(namespaceInformerStopChan)
+	}
+}
+
+// newTargetSource returns the source of the dynamic targets of the controller
+func newTargetSource(conf *config.Config, _logger log.Logger) controller.TargetSource {
+	if len(conf.PluginConfig.DynamicHostPath) == 0 {
+		return nil
+	}
+	switch conf.ControllerConfig.DynamicTargetSource {
+	case config.TargetSourceNamespace:
+		initNamespaceInformer()
+		return controller.NewNamespaceSource(namespaceInformer, _logger)
+	case config.TargetSourceFile:
+		return controller.NewFileSource(conf.ControllerConfig.DynamicTargetFile, _logger)
+	default:
+		initClusterInformer()
+		return controller.NewClusterSource(informer, _logger)
+	}
+}
+
+// Initializes and starts the informer of the pods scheduled on the node
+func initPodInformer(nodeName string) {
+	if podInformer == nil || podInformer.IsStopped() {
+		c, err := rest.InClusterConfig()
+		if err != nil {
+			panic(err)
+		}
+		kubernetesClient, err := kubernetes.NewForConfig(c)
+		if err != nil {
+			panic(err)
+		}
+		kubeInformerFactory := informers.NewSharedInformerFactoryWithOptions(kubernetesClient, time.Minute*30,
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+			}),
+		)
+		podInformer = kubeInformerFactory.Core().V1().Pods().Informer()
+		// Only the metadata used for the enrichment is kept in the informer cache.
+		if err := podInformer.SetTransform(stripPod); err != nil {
+			panic(err)
+		}
+		podInformerStopChan = make(chan struct{})
+		kubeInformerFactory.Start(podInformerStopChan)
+	}
+}
+
+func stripPod(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return obj, nil
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pod.Name,
+			Namespace:       pod.Namespace,
+			UID:             pod.UID,
+			ResourceVersion: pod.ResourceVersion,
+			Labels:          pod.Labels,
+			Annotations:     pod.Annotations,
+			OwnerReferences: pod.OwnerReferences,
+		},
+		Spec: corev1.PodSpec{NodeName: pod.Spec.NodeName},
+	}, nil
+}
+
 func setPprofProfile() {
 	pprofOnce.Do(func() {
 		runtime.SetMutexProfileFraction(5)
@@ -118,21 +214,23 @@ func FLBPluginInit(ctx unsafe.Pointer) int {
 		level.Error(logger).Log("[flb-go]", "failed to launch", "error", err)
 		return output.FLB_ERROR
 	}
+	// ParseConfig already rejected the invalid combinations, only the warnings are left
+	warnings, _ := config.Validate(&pluginConfig{ctx: ctx}, conf)
 
 	if conf.Pprof {
 		setPprofProfile()
 	}
 
-	if len(conf.PluginConfig.DynamicHostPath) > 0 {
-		initClusterInformer()
+	if conf.PluginConfig.PodMetadata != nil {
+		initPodInformer(conf.PluginConfig.PodMetadata.NodeName)
 	}
 
 	id, _, _ := strings.Cut(string(uuid.NewUUID()), "-")
 	_logger := log.With(newLogger(conf.LogLevel), "ts", log.DefaultTimestampUTC, "id", id)
 
-	dumpConfiguration(_logger, conf)
+	dumpConfiguration(_logger, conf, warnings)
 
-	plugin, err := valiplugin.NewPlugin(informer, conf, _logger)
+	plugin, err := valiplugin.NewPlugin(newTargetSource(conf, _logger), podInformer, conf, _logger)
 	if err != nil {
 		metrics.Errors.WithLabelValues(metrics.ErrorNewPlugin).Inc()
 		level.Error(_logger).Log("msg", "error creating plugin", "err", err)
@@ -144,6 +242,7 @@ func FLBPluginInit(ctx unsafe.Pointer) int {
 	// remember plugin instance, required to cleanly dispose when fluent-bit is shutting down
 	pluginsMutex.Lock()
 	plugins = append(plugins, plugin)
+	chunkProgress[plugin] = chunk.NewProgress(chunk.DefaultMaxChunks, chunk.DefaultTTL)
 	pluginsMutex.Unlock()
 
 	_ = level.Info(_logger).Log(
@@ -165,51 +264,57 @@ func FLBPluginFlushCtx(ctx, data unsafe.Pointer, length C.int, tag *C.char) int
 	var ts interface{}
 	var record map[interface{}]interface{}
 
+	fluentBitTag := C.GoString(tag)
 	dec := output.NewDecoder(data, int(length))
 
-	for {
+	// The records sent by a previous attempt of the chunk are skipped.
+	pluginsMutex.RLock()
+	progress := chunkProgress[plugin]
+	pluginsMutex.RUnlock()
+	chunkData := unsafe.Slice((*byte)(data), int(length))
+	var resumed chunk.Sent
+	if progress != nil {
+		resumed = progress.Resume(fluentBitTag, chunkData)
+	}
+
+	var records []valiplugin.ChunkRecord
+	// indexes are the indexes of the records in the chunk
+	var indexes []int
+	var total int
+	for ; ; total++ {
 		ret, ts, record = output.GetRecord(dec)
 		if ret != 0 {
 			break
 		}
-
-		var timestamp time.Time
-		switch t := ts.(type) {
-		case output.FLBTime:
-			timestamp = ts.(output.FLBTime).Time
-		case uint64:
-			timestamp = time.Unix(int64(t), 0)
-		case []interface{}:
-			// fluent-bit 2.1.x introduces support for log metadata.
-			// We need to iterate over the slice fields, where one field is the record timestamp
-			// and the other field in the slice is the newly introduced metadata in the form of a map
-			// see https://github.com/fluent/fluent-bit/issues/6666#issuecomment-1380200701
-			parsed := false
-			for _, v := range ts.([]interface{}) {
-				if flb, ok := v.(output.FLBTime); ok {
-					timestamp, parsed = flb.Time, true
-					break
-				}
-			}
-			if !parsed {
-				level.Warn(logger).Log("msg", "timestamp isn't known format, using current time")
-				timestamp = time.Now()
-			}
-		default:
-			_ = level.Info(logger).Log("msg", fmt.Sprintf("unknown timestamp type: %T", ts))
-			timestamp = time.Now()
+		if resumed.Has(total) {
+			continue
 		}
+		records = append(records, valiplugin.ChunkRecord{Record: record, Timestamp: decodeTimestamp(ts)})
+		indexes = append(indexes, total)
+	}
 
-		err := plugin.SendRecord(record, timestamp)
-		if err != nil {
-			_ = level.Error(logger).Log(
-				"msg", "error sending record, retrying...",
-				"err", err.Error(),
-				"tag", C.GoString(tag),
-			)
-			return output.FLB_RETRY // max retry of the plugin is set to 3, then it shall be discarded by fluent-bit
+	result, err := plugin.SendChunk(records, fluentBitTag)
+	if err != nil {
+		sent := make([]bool, total)
+		for i := range sent {
+			sent[i] = resumed.Has(i)
 		}
-
+		for i, index := range indexes {
+			sent[index] = result.Has(i)
+		}
+		_ = level.Error(logger).Log(
+			"msg", "error sending record, retrying...",
+			"err", err.Error(),
+			"tag", fluentBitTag,
+			"sent", resumed.Len()+result.Len(),
+		)
+		if progress != nil {
+			progress.Failed(fluentBitTag, chunkData, chunk.NewSent(sent))
+		}
+		return output.FLB_RETRY // max retry of the plugin is set to 3, then it shall be discarded by fluent-bit
+	}
+	if progress != nil {
+		progress.Done(fluentBitTag, chunkData)
 	}
 
 	// Return options:
@@ -220,6 +325,31 @@ func FLBPluginFlushCtx(ctx, data unsafe.Pointer, length C.int, tag *C.char) int
 	return output.FLB_OK
 }
 
+// decodeTimestamp returns the time of the fluent-bit record timestamp.
+func decodeTimestamp(ts interface{}) time.Time {
+	switch t := ts.(type) {
+	case output.FLBTime:
+		return t.Time
+	case uint64:
+		return time.Unix(int64(t), 0)
+	case []interface{}:
+		// fluent-bit 2.1.x introduces support for log metadata.
+		// We need to iterate over the slice fields, where one field is the record timestamp
+		// and the other field in the slice is the newly introduced metadata in the form of a map
+		// see https://github.com/fluent/fluent-bit/issues/6666#issuecomment-1380200701
+		for _, v := range t {
+			if flb, ok := v.(output.FLBTime); ok {
+				return flb.Time
+			}
+		}
+		level.Warn(logger).Log("msg", "timestamp isn't known format, using current time")
+		return time.Now()
+	default:
+		_ = level.Info(logger).Log("msg", fmt.Sprintf("unknown timestamp type: %T", ts))
+		return time.Now()
+	}
+}
+
 //export FLBPluginExitCtx
 func FLBPluginExitCtx(ctx unsafe.Pointer) int {
 	plugin := output.FLBPluginGetContext(ctx).(valiplugin.Vali)
@@ -245,6 +375,12 @@ func FLBPluginExit() int {
 	if informerStopChan != nil {
 		close(informerStopChan)
 	}
+	if podInformerStopChan != nil {
+		close(podInformerStopChan)
+	}
+	if namespaceInformerStopChan != nil {
+		close(namespaceInformerStopChan)
+	}
 
 	return output.FLB_OK
 }
@@ -266,76 +402,19 @@ func getInclusterKubernetsClient() (gardenerclientsetversioned.Interface, error)
 
 func main() {}
 
-func dumpConfiguration(_logger log.Logger, conf *config.Config) {
+func dumpConfiguration(_logger log.Logger, conf *config.Config, warnings []string) {
 	_ = level.Info(_logger).Log(
 		"[flb-go]", "Starting fluent-bit-go-vali",
 		"version", version.Get().GitVersion,
 		"revision", version.Get().GitCommit,
 	)
 	paramLogger := log.With(_logger, "[flb-go]", "provided parameter")
-	_ = level.Debug(paramLogger).Log("URL", conf.ClientConfig.CredativValiConfig.URL)
-	_ = level.Debug(paramLogger).Log("TenantID", conf.ClientConfig.CredativValiConfig.TenantID)
-	_ = level.Debug(paramLogger).Log("BatchWait", conf.ClientConfig.CredativValiConfig.BatchWait)
-	_ = level.Debug(paramLogger).Log("BatchSize", conf.ClientConfig.CredativValiConfig.BatchSize)
-	_ = level.Debug(paramLogger).Log("Labels", conf.ClientConfig.CredativValiConfig.ExternalLabels)
-	_ = level.Debug(paramLogger).Log("LogLevel", conf.LogLevel.String())
-	_ = level.Debug(paramLogger).Log("AutoKubernetesLabels", conf.PluginConfig.AutoKubernetesLabels)
-	_ = level.Debug(paramLogger).Log("RemoveKeys", fmt.Sprintf("%+v", conf.PluginConfig.RemoveKeys))
-	_ = level.Debug(paramLogger).Log("LabelKeys", fmt.Sprintf("%+v", conf.PluginConfig.LabelKeys))
-	_ = level.Debug(paramLogger).Log("LineFormat", conf.PluginConfig.LineFormat)
-	_ = level.Debug(paramLogger).Log("DropSingleKey", conf.PluginConfig.DropSingleKey)
-	_ = level.Debug(paramLogger).Log("LabelMapPath", fmt.Sprintf("%+v", conf.PluginConfig.LabelMap))
-	_ = level.Debug(paramLogger).Log("SortByTimestamp", fmt.Sprintf("%+v", conf.ClientConfig.SortByTimestamp))
-	_ = level.Debug(paramLogger).Log("DynamicHostPath", fmt.Sprintf("%+v", conf.PluginConfig.DynamicHostPath))
-	_ = level.Debug(paramLogger).Log("DynamicHostPrefix", fmt.Sprintf("%+v", conf.ControllerConfig.DynamicHostPrefix))
-	_ = level.Debug(paramLogger).Log("DynamicHostSuffix", fmt.Sprintf("%+v", conf.ControllerConfig.DynamicHostSuffix))
-	_ = level.Debug(paramLogger).Log("DynamicHostRegex", fmt.Sprintf("%+v", conf.PluginConfig.DynamicHostRegex))
-	_ = level.Debug(paramLogger).Log("Timeout", fmt.Sprintf("%+v", conf.ClientConfig.CredativValiConfig.Timeout))
-	_ = level.Debug(paramLogger).Log("MinBackoff", fmt.Sprintf("%+v", conf.ClientConfig.CredativValiConfig.BackoffConfig.MinBackoff))
-	_ = level.Debug(paramLogger).Log("MaxBackoff", fmt.Sprintf("%+v", conf.ClientConfig.CredativValiConfig.BackoffConfig.MaxBackoff))
-	_ = level.Debug(paramLogger).Log("MaxRetries", fmt.Sprintf("%+v", conf.ClientConfig.CredativValiConfig.BackoffConfig.MaxRetries))
-	_ = level.Debug(paramLogger).Log("Buffer", fmt.Sprintf("%+v", conf.ClientConfig.BufferConfig.Buffer))
-	_ = level.Debug(paramLogger).Log("BufferType", fmt.Sprintf("%+v", conf.ClientConfig.BufferConfig.BufferType))
-	_ = level.Debug(paramLogger).Log("QueueDir", fmt.Sprintf("%+v", conf.ClientConfig.BufferConfig.DqueConfig.QueueDir))
-	_ = level.Debug(paramLogger).Log("QueueSegmentSize", fmt.Sprintf("%+v", conf.ClientConfig.BufferConfig.DqueConfig.QueueSegmentSize))
-	_ = level.Debug(paramLogger).Log("QueueSync", fmt.Sprintf("%+v", conf.ClientConfig.BufferConfig.DqueConfig.QueueSync))
-	_ = level.Debug(paramLogger).Log("QueueName", fmt.Sprintf("%+v", conf.ClientConfig.BufferConfig.DqueConfig.QueueName))
-	_ = level.Debug(paramLogger).Log("FallbackToTagWhenMetadataIsMissing", fmt.Sprintf("%+v", conf.PluginConfig.KubernetesMetadata.FallbackToTagWhenMetadataIsMissing))
-	_ = level.Debug(paramLogger).Log("TagKey", fmt.Sprintf("%+v", conf.PluginConfig.KubernetesMetadata.TagKey))
-	_ = level.Debug(paramLogger).Log("TagPrefix", fmt.Sprintf("%+v", conf.PluginConfig.KubernetesMetadata.TagPrefix))
-	_ = level.Debug(paramLogger).Log("TagExpression", fmt.Sprintf("%+v", conf.PluginConfig.KubernetesMetadata.TagExpression))
-	_ = level.Debug(paramLogger).Log("DropLogEntryWithoutK8sMetadata", fmt.Sprintf("%+v", conf.PluginConfig.KubernetesMetadata.DropLogEntryWithoutK8sMetadata))
-	_ = level.Debug(paramLogger).Log("NumberOfBatchIDs", fmt.Sprintf("%+v", conf.ClientConfig.NumberOfBatchIDs))
-	_ = level.Debug(paramLogger).Log("IdLabelName", fmt.Sprintf("%+v", conf.ClientConfig.IdLabelName))
-	_ = level.Debug(paramLogger).Log("DeletedClientTimeExpiration", fmt.Sprintf("%+v", conf.ControllerConfig.DeletedClientTimeExpiration))
-	_ = level.Debug(paramLogger).Log("DynamicTenant", fmt.Sprintf("%+v", conf.PluginConfig.DynamicTenant.Tenant))
-	_ = level.Debug(paramLogger).Log("DynamicField", fmt.Sprintf("%+v", conf.PluginConfig.DynamicTenant.Field))
-	_ = level.Debug(paramLogger).Log("DynamicRegex", fmt.Sprintf("%+v", conf.PluginConfig.DynamicTenant.Regex))
-	_ = level.Debug(paramLogger).Log("Pprof", fmt.Sprintf("%+v", conf.Pprof))
-	if conf.PluginConfig.HostnameKey != nil {
-		_ = level.Debug(paramLogger).Log("HostnameKey", fmt.Sprintf("%+v", *conf.PluginConfig.HostnameKey))
-	}
-	if conf.PluginConfig.HostnameValue != nil {
-		_ = level.Debug(paramLogger).Log("HostnameValue", fmt.Sprintf("%+v", *conf.PluginConfig.HostnameValue))
+	for _, item := range config.EffectiveConfig(conf) {
+		_ = level.Debug(paramLogger).Log(item.Key, fmt.Sprintf("%+v", item.Value))
 	}
-	if conf.PluginConfig.PreservedLabels != nil {
-		_ = level.Debug(paramLogger).Log("PreservedLabels", fmt.Sprintf("%+v", conf.PluginConfig.PreservedLabels))
+	for _, warning := range warnings {
+		_ = level.Warn(_logger).Log("[flb-go]", "configuration warning", "msg", warning)
 	}
-	_ = level.Debug(paramLogger).Log("LabelSetInitCapacity", fmt.Sprintf("%+v", conf.PluginConfig.LabelSetInitCapacity))
-	_ = level.Debug(paramLogger).Log("SendLogsToMainClusterWhenIsInCreationState", fmt.Sprintf("%+v", conf.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInCreationState))
-	_ = level.Debug(paramLogger).Log("SendLogsToMainClusterWhenIsInReadyState", fmt.Sprintf("%+v", conf.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInReadyState))
-	_ = level.Debug(paramLogger).Log("SendLogsToMainClusterWhenIsInHibernatingState", fmt.Sprintf("%+v", conf.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInHibernatingState))
-	_ = level.Debug(paramLogger).Log("SendLogsToMainClusterWhenIsInHibernatedState", fmt.Sprintf("%+v", conf.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInHibernatedState))
-	_ = level.Debug(paramLogger).Log("SendLogsToMainClusterWhenIsInDeletionState", fmt.Sprintf("%+v", conf.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInDeletionState))
-	_ = level.Debug(paramLogger).Log("SendLogsToMainClusterWhenIsInRestoreState", fmt.Sprintf("%+v", conf.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInRestoreState))
-	_ = level.Debug(paramLogger).Log("SendLogsToMainClusterWhenIsInMigrationState", fmt.Sprintf("%+v", conf.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInMigrationState))
-	_ = level.Debug(paramLogger).Log("SendLogsToDefaultClientWhenClusterIsInCreationState", fmt.Sprintf("%+v", conf.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInCreationState))
-	_ = level.Debug(paramLogger).Log("SendLogsToDefaultClientWhenClusterIsInReadyState", fmt.Sprintf("%+v", conf.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInReadyState))
-	_ = level.Debug(paramLogger).Log("SendLogsToDefaultClientWhenClusterIsInHibernatingState", fmt.Sprintf("%+v", conf.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInHibernatingState))
-	_ = level.Debug(paramLogger).Log("SendLogsToDefaultClientWhenClusterIsInHibernatedState", fmt.Sprintf("%+v", conf.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInHibernatedState))
-	_ = level.Debug(paramLogger).Log("SendLogsToDefaultClientWhenClusterIsInDeletionState", fmt.Sprintf("%+v", conf.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInDeletionState))
-	_ = level.Debug(paramLogger).Log("SendLogsToDefaultClientWhenClusterIsInRestoreState", fmt.Sprintf("%+v", conf.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInRestoreState))
-	_ = level.Debug(paramLogger).Log("SendLogsToDefaultClientWhenClusterIsInMigrationState", fmt.Sprintf("%+v", conf.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInMigrationState))
 }
 
 func pluginsContains(present valiplugin.Vali) bool {
@@ -355,6 +434,7 @@ func pluginsRemove(plugin valiplugin.Vali) {
 	for i, p := range plugins {
 		if plugin == p {
 			plugins = append(plugins[:i], plugins[i+1:]...)
+			delete(chunkProgress, plugin)
 			return
 		}
 	}
@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+	"k8s.io/component-base/version/verflag"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+const defaultOutputName = "gardenervali"
+
+// NewCommandValiPluginConfig creates a *cobra.Command object with default parameters.
+func NewCommandValiPluginConfig() *cobra.Command {
+	opts := NewOptions()
+
+	cmd := &cobra.Command{
+		Use:   "vali-plugin-config",
+		Short: "Validate a vali plugin configuration",
+		Long: "Parses a fluent-bit [OUTPUT] section or a key list the same way the vali plugin does, " +
+			"runs the cross-field validation and prints the effective configuration as YAML.",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			verflag.PrintAndExitIfRequested()
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			return opts.Run(cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+		SilenceUsage: true,
+	}
+
+	flags := cmd.Flags()
+	verflag.AddFlags(flags)
+	opts.AddFlags(flags)
+
+	return cmd
+}
+
+// Options has all the parameters needed to check a vali plugin configuration.
+type Options struct {
+	// ConfigFile is a fluent-bit configuration or a key list. "-" reads from stdin.
+	ConfigFile string
+	// OutputName selects the [OUTPUT] sections by their Name key.
+	OutputName string
+	// Set overrides single keys in the form Key=Value.
+	Set []string
+}
+
+// NewOptions returns a new Options object.
+func NewOptions() *Options {
+	return &Options{
+		ConfigFile: "-",
+		OutputName: defaultOutputName,
+	}
+}
+
+// AddFlags adds all flags to the given FlagSet.
+func (o *Options) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.ConfigFile, "config", "c", o.ConfigFile, "Fluent-bit configuration or key list, \"-\" reads from stdin")
+	flags.StringVar(&o.OutputName, "output-name", o.OutputName, "Name of the [OUTPUT] sections to check")
+	flags.StringArrayVar(&o.Set, "set", o.Set, "Overrides a configuration key, in the form Key=Value")
+}
+
+// Validate validates all the required options.
+func (o *Options) Validate() error {
+	if o.ConfigFile == "" {
+		return errors.New("config must not be empty")
+	}
+	if o.OutputName == "" {
+		return errors.New("output-name must not be empty")
+	}
+	for _, s := range o.Set {
+		if key, _, ok := strings.Cut(s, "="); !ok || key == "" {
+			return fmt.Errorf("invalid set %q, expected Key=Value", s)
+		}
+	}
+	return nil
+}
+
+// Run parses every selected section and writes the result to out.
+// It fails if any of the sections is not a valid configuration.
+func (o *Options) Run(in io.Reader, out io.Writer) error {
+	if o.ConfigFile != "-" {
+		f, err := os.Open(o.ConfigFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	sections, err := parseSections(in, o.OutputName)
+	if err != nil {
+		return err
+	}
+
+	var failed bool
+	for i, section := range sections {
+		for _, s := range o.Set {
+			key, value, _ := strings.Cut(s, "=")
+			section.set(key, value)
+		}
+
+		result := check(section)
+		if result.err != nil {
+			failed = true
+		}
+
+		b, err := yaml.Marshal(result.report())
+		if err != nil {
+			return fmt.Errorf("failed to marshal the result: %v", err)
+		}
+		if i > 0 {
+			if _, err := io.WriteString(out, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := out.Write(b); err != nil {
+			return err
+		}
+	}
+
+	if failed {
+		return errors.New("invalid configuration")
+	}
+	return nil
+}
+
+type result struct {
+	conf     *config.Config
+	warnings []string
+	err      error
+}
+
+func check(section section) result {
+	conf, err := config.ParseConfig(section)
+	if err != nil {
+		return result{err: err}
+	}
+	// ParseConfig already rejected the invalid combinations, only the warnings are left
+	warnings, _ := config.Validate(section, conf)
+	return result{conf: conf, warnings: warnings}
+}
+
+func (r result) report() yaml.MapSlice {
+	if r.err != nil {
+		return yaml.MapSlice{{Key: "error", Value: r.err.Error()}}
+	}
+	res := yaml.MapSlice{}
+	if len(r.warnings) > 0 {
+		res = append(res, yaml.MapItem{Key: "warnings", Value: r.warnings})
+	}
+	return append(res, yaml.MapItem{Key: "config", Value: config.EffectiveConfig(r.conf)})
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Vali plugin config", func() {
+	const fluentBitConfig = `[SERVICE]
+    Flush 30
+@INCLUDE input.conf
+
+[OUTPUT]
+    Name  stdout
+    Match *
+
+[OUTPUT]
+    name  gardenervali
+    Match kubernetes.*
+    Url   http://localhost:3100/vali/api/v1/push
+    LineFormat json
+    DynamicHostPrefix http://vali.
+`
+
+	DescribeTable("#parseSections",
+		func(input string, expected []section, expectedErr bool) {
+			sections, err := parseSections(strings.NewReader(input), defaultOutputName)
+			if expectedErr {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sections).To(Equal(expected))
+		},
+		Entry("fluent-bit configuration", fluentBitConfig, []section{{
+			"name":              "gardenervali",
+			"match":             "kubernetes.*",
+			"url":               "http://localhost:3100/vali/api/v1/push",
+			"lineformat":        "json",
+			"dynamichostprefix": "http://vali.",
+		}}, false),
+		Entry("key list",
+			"Url http://localhost:3100/vali/api/v1/push\n# comment\nDynamicTenant user  kubernetes  user-exposed.*\n",
+			[]section{{
+				"url":           "http://localhost:3100/vali/api/v1/push",
+				"dynamictenant": "user  kubernetes  user-exposed.*",
+			}}, false),
+		Entry("no matching output", "[OUTPUT]\n    Name stdout\n", nil, true),
+		Entry("empty input", "# nothing\n", nil, true),
+	)
+
+	Describe("#Run", func() {
+		It("should print the effective configuration with the warnings", func() {
+			opts := NewOptions()
+			out := &bytes.Buffer{}
+			Expect(opts.Run(strings.NewReader(fluentBitConfig), out)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("- DynamicHostPrefix is ignored because DynamicHostPath is not set\n"))
+			Expect(out.String()).To(ContainSubstring("  URL: http://localhost:3100/vali/api/v1/push\n"))
+			Expect(out.String()).To(ContainSubstring("  LineFormat: json\n"))
+		})
+
+		It("should fail and print the error for an invalid configuration", func() {
+			opts := NewOptions()
+			opts.Set = []string{`DynamicHostPath={"kubernetes": {"namespace_name": "namespace"}}`}
+			out := &bytes.Buffer{}
+			Expect(opts.Run(strings.NewReader(fluentBitConfig), out)).ToNot(Succeed())
+			Expect(out.String()).To(HavePrefix("error: "))
+			Expect(out.String()).To(ContainSubstring("invalid DynamicHostRegex"))
+		})
+	})
+
+	DescribeTable("#Validate",
+		func(opts *Options, expectedErr bool) {
+			if expectedErr {
+				Expect(opts.Validate()).ToNot(Succeed())
+			} else {
+				Expect(opts.Validate()).To(Succeed())
+			}
+		},
+		Entry("defaults", NewOptions(), false),
+		Entry("set without value", &Options{ConfigFile: "-", OutputName: defaultOutputName, Set: []string{"LineFormat"}}, true),
+		Entry("empty output name", &Options{ConfigFile: "-"}, true),
+	)
+})
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// section holds the keys of one [OUTPUT] section.
+// Fluent-bit matches the keys case-insensitively, so they are stored in lower case.
+type section map[string]string
+
+// Get implements config.Getter
+func (s section) Get(key string) string {
+	return s[strings.ToLower(key)]
+}
+
+func (s section) set(key, value string) {
+	s[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+}
+
+// parseSections reads a fluent-bit classic configuration and returns the [OUTPUT]
+// sections with the given Name. Input without any section header is a plain key list.
+func parseSections(r io.Reader, name string) ([]section, error) {
+	var (
+		sections []section
+		current  section
+		outputs  []section
+		headers  bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, "@"):
+			continue
+		case strings.HasPrefix(line, "["):
+			headers = true
+			current = nil
+			if strings.EqualFold(line, "[OUTPUT]") {
+				current = section{}
+				outputs = append(outputs, current)
+			}
+			continue
+		}
+
+		if headers && current == nil {
+			continue
+		}
+		if current == nil {
+			current = section{}
+			outputs = append(outputs, current)
+		}
+		key, value := line, ""
+		if i := strings.IndexAny(line, " \t"); i > 0 {
+			key, value = line[:i], line[i:]
+		}
+		current.set(key, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !headers {
+		if len(outputs) == 0 {
+			return nil, fmt.Errorf("no configuration keys found")
+		}
+		// a plain key list does not need to carry the output name
+		return outputs, nil
+	}
+	for _, s := range outputs {
+		if strings.EqualFold(s.Get("Name"), name) {
+			sections = append(sections, s)
+		}
+	}
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("no [OUTPUT] section with Name %s found", name)
+	}
+	return sections, nil
+}
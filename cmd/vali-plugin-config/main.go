@@ -0,0 +1,17 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+
+	"github.com/gardener/logging/cmd/vali-plugin-config/app"
+)
+
+func main() {
+	if err := app.NewCommandValiPluginConfig().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,164 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package chunk
+
+import (
+	"crypto/sha256"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+// Defaults of the chunk progress
+const (
+	// DefaultMaxChunks is the maximum number of failed chunks which are remembered.
+	DefaultMaxChunks = 1024
+	// DefaultTTL is the time after which a failed chunk is forgotten. Fluent-bit gives up retrying it before.
+	DefaultTTL = time.Hour
+)
+
+// Fingerprint identifies a chunk by its tag and the sha256 of its content.
+type Fingerprint [sha256.Size]byte
+
+// NewFingerprint returns the fingerprint of the chunk.
+func NewFingerprint(tag string, data []byte) Fingerprint {
+	h := sha256.New()
+	h.Write([]byte(tag))
+	h.Write([]byte{0})
+	h.Write(data)
+	var res Fingerprint
+	copy(res[:], h.Sum(nil))
+	return res
+}
+
+// Sent are the sent records of a chunk: the first Prefix records and the records at the ascending
+// indexes of After, which the parallel workers sent after an earlier record of the chunk failed.
+type Sent struct {
+	Prefix int
+	After  []int
+}
+
+// NewSent returns the sent records of a chunk with the sent flags of its records.
+func NewSent(sent []bool) Sent {
+	var res Sent
+	for res.Prefix < len(sent) && sent[res.Prefix] {
+		res.Prefix++
+	}
+	for i := res.Prefix + 1; i < len(sent); i++ {
+		if sent[i] {
+			res.After = append(res.After, i)
+		}
+	}
+	return res
+}
+
+// Has reports if the record at the index was sent.
+func (s Sent) Has(index int) bool {
+	if index < s.Prefix {
+		return true
+	}
+	i := sort.SearchInts(s.After, index)
+	return i < len(s.After) && s.After[i] == index
+}
+
+// Len returns the number of sent records.
+func (s Sent) Len() int {
+	return s.Prefix + len(s.After)
+}
+
+// Progress remembers which records of the failed chunks were already sent, so a retry of
+// the chunk by fluent-bit resumes after the last sent record instead of sending them twice.
+type Progress struct {
+	mu        sync.Mutex
+	maxChunks int
+	ttl       time.Duration
+	chunks    map[Fingerprint]progressEntry
+	now       func() time.Time
+}
+
+type progressEntry struct {
+	sent    Sent
+	updated time.Time
+}
+
+// NewProgress returns a progress remembering at most maxChunks failed chunks for the ttl.
+func NewProgress(maxChunks int, ttl time.Duration) *Progress {
+	return &Progress{
+		maxChunks: maxChunks,
+		ttl:       ttl,
+		chunks:    map[Fingerprint]progressEntry{},
+		now:       time.Now,
+	}
+}
+
+// Resume returns the records of the chunk which were sent by the previous attempts.
+// The chunk is only hashed when a failed chunk is remembered.
+func (p *Progress) Resume(tag string, data []byte) Sent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.chunks) == 0 {
+		return Sent{}
+	}
+
+	f := NewFingerprint(tag, data)
+	e, ok := p.chunks[f]
+	if !ok {
+		return Sent{}
+	}
+	if p.now().Sub(e.updated) > p.ttl {
+		delete(p.chunks, f)
+		return Sent{}
+	}
+	metrics.ResumedChunks.Inc()
+	metrics.AvoidedDuplicates.Add(float64(e.sent.Len()))
+	return e.sent
+}
+
+// Failed remembers the records of the chunk which were sent before it failed.
+func (p *Progress) Failed(tag string, data []byte, sent Sent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f := NewFingerprint(tag, data)
+	if sent.Len() == 0 {
+		delete(p.chunks, f)
+		return
+	}
+	now := p.now()
+	if _, ok := p.chunks[f]; !ok && len(p.chunks) >= p.maxChunks {
+		p.evict(now)
+	}
+	p.chunks[f] = progressEntry{sent: sent, updated: now}
+}
+
+// Done forgets the chunk after it was sent completely.
+func (p *Progress) Done(tag string, data []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.chunks) == 0 {
+		return
+	}
+	delete(p.chunks, NewFingerprint(tag, data))
+}
+
+// evict removes the expired chunks, or else the least recently failed one.
+func (p *Progress) evict(now time.Time) {
+	var oldest Fingerprint
+	var oldestUpdated time.Time
+	for f, e := range p.chunks {
+		if now.Sub(e.updated) > p.ttl {
+			delete(p.chunks, f)
+			continue
+		}
+		if oldestUpdated.IsZero() || e.updated.Before(oldestUpdated) {
+			oldest, oldestUpdated = f, e.updated
+		}
+	}
+	if len(p.chunks) >= p.maxChunks {
+		delete(p.chunks, oldest)
+	}
+}
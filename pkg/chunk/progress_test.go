@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package chunk
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+var _ = Describe("Progress", func() {
+	var (
+		p   *Progress
+		now time.Time
+	)
+	chunk := []byte("\x92\xd7\x00\x65\x8f\x2a\x00\x00\x00\x00\x00\x81\xa3log\xa5hello")
+
+	BeforeEach(func() {
+		p = NewProgress(2, time.Hour)
+		now = time.Now()
+		p.now = func() time.Time { return now }
+	})
+
+	counter := func(c prometheus.Counter) float64 {
+		m := &dto.Metric{}
+		Expect(c.Write(m)).To(Succeed())
+		return m.GetCounter().GetValue()
+	}
+
+	It("should resume a failed chunk after its sent records", func() {
+		resumed, avoided := counter(metrics.ResumedChunks), counter(metrics.AvoidedDuplicates)
+		Expect(p.Resume("kubernetes.var.log", chunk)).To(Equal(Sent{}))
+
+		p.Failed("kubernetes.var.log", chunk, Sent{Prefix: 3})
+		Expect(p.Resume("kubernetes.var.log", chunk)).To(Equal(Sent{Prefix: 3}))
+		Expect(counter(metrics.ResumedChunks)).To(Equal(resumed + 1))
+		Expect(counter(metrics.AvoidedDuplicates)).To(Equal(avoided + 3))
+
+		p.Failed("kubernetes.var.log", chunk, Sent{Prefix: 5})
+		Expect(p.Resume("kubernetes.var.log", chunk)).To(Equal(Sent{Prefix: 5}))
+		p.Done("kubernetes.var.log", chunk)
+		Expect(p.Resume("kubernetes.var.log", chunk)).To(Equal(Sent{}))
+		Expect(counter(metrics.ResumedChunks)).To(Equal(resumed + 2))
+	})
+
+	It("should resume a chunk with records sent after a failed one", func() {
+		sent := NewSent([]bool{true, true, false, true, false, true})
+		Expect(sent).To(Equal(Sent{Prefix: 2, After: []int{3, 5}}))
+		Expect(sent.Len()).To(Equal(4))
+		Expect([]bool{sent.Has(1), sent.Has(2), sent.Has(3), sent.Has(4), sent.Has(5), sent.Has(6)}).To(Equal([]bool{true, false, true, false, true, false}))
+
+		avoided := counter(metrics.AvoidedDuplicates)
+		p.Failed("kubernetes.var.log", chunk, sent)
+		Expect(p.Resume("kubernetes.var.log", chunk)).To(Equal(sent))
+		Expect(counter(metrics.AvoidedDuplicates)).To(Equal(avoided + 4))
+	})
+
+	It("should tell the chunks apart by tag and content", func() {
+		p.Failed("kubernetes.var.log", chunk, Sent{Prefix: 3})
+		Expect(p.Resume("journald.kubelet", chunk)).To(Equal(Sent{}))
+		Expect(p.Resume("kubernetes.var.log", append([]byte{}, chunk[:len(chunk)-1]...))).To(Equal(Sent{}))
+		Expect(NewFingerprint("a", []byte("bc"))).ToNot(Equal(NewFingerprint("ab", []byte("c"))))
+	})
+
+	It("should not remember a chunk without sent records", func() {
+		p.Failed("kubernetes.var.log", chunk, Sent{})
+		Expect(p.chunks).To(BeEmpty())
+	})
+
+	It("should forget the expired chunks", func() {
+		p.Failed("kubernetes.var.log", chunk, Sent{Prefix: 3})
+		now = now.Add(2 * time.Hour)
+		Expect(p.Resume("kubernetes.var.log", chunk)).To(Equal(Sent{}))
+		Expect(p.chunks).To(BeEmpty())
+	})
+
+	It("should evict the least recently failed chunk when full", func() {
+		p.Failed("a", chunk, Sent{Prefix: 1})
+		now = now.Add(time.Second)
+		p.Failed("b", chunk, Sent{Prefix: 2})
+		now = now.Add(time.Second)
+		p.Failed("c", chunk, Sent{Prefix: 3})
+		Expect(p.chunks).To(HaveLen(2))
+		Expect(p.Resume("a", chunk)).To(Equal(Sent{}))
+		Expect(p.Resume("b", chunk)).To(Equal(Sent{Prefix: 2}))
+		Expect(p.Resume("c", chunk)).To(Equal(Sent{Prefix: 3}))
+	})
+})
@@ -54,14 +54,10 @@ var _ = g.Describe("Client", func() {
 			},
 		},
 		PluginConfig: config.PluginConfig{
-			LabelKeys:     []string{"foo", "bar"},
-			RemoveKeys:    []string{"buzz", "fuzz"},
-			DropSingleKey: false,
-			DynamicHostPath: map[string]interface{}{
-				"kubernetes": map[string]interface{}{
-					"namespace_name": "namespace",
-				},
-			},
+			LabelKeys:        []string{"foo", "bar"},
+			RemoveKeys:       []string{"buzz", "fuzz"},
+			DropSingleKey:    false,
+			DynamicHostPath:  []string{"kubernetes.namespace_name"},
 			DynamicHostRegex: "shoot--",
 			LineFormat:       config.KvPairFormat,
 		},
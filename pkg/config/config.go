@@ -9,6 +9,7 @@ package config
 
 import (
 	"fmt"
+	"os"
 	"strconv"
 
 	"github.com/cortexproject/cortex/pkg/util/flagext"
@@ -32,11 +33,27 @@ type Getter interface {
 // Format is the log line format
 type Format int
 
+// String returns the LineFormat value of the format.
+func (f Format) String() string {
+	switch f {
+	case JSONFormat:
+		return "json"
+	case KvPairFormat:
+		return "key_value"
+	case TemplateFormat:
+		return "template"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
 const (
 	// JSONFormat represents json format for log line
 	JSONFormat Format = iota
 	// KvPairFormat represents key-value format for log line
 	KvPairFormat
+	// TemplateFormat represents a log line rendered from the LineTemplate
+	TemplateFormat
 	// DefaultKubernetesMetadataTagExpression for extracting the kubernetes metadata from tag
 	DefaultKubernetesMetadataTagExpression = "\\.([^_]+)_([^_]+)_(.+)-([a-z0-9]{64})\\.log$"
 
@@ -88,6 +105,27 @@ func ParseConfig(cfg Getter) (*Config, error) {
 	if err := initPluginConfig(cfg, res); err != nil {
 		return nil, err
 	}
+	if _, err := Validate(cfg, res); err != nil {
+		return nil, err
+	}
 
 	return res, nil
 }
+
+// readFileOrInline returns the content of the file at value, or value itself when it is not a file.
+func readFileOrInline(key, value string) ([]byte, error) {
+	if !isFile(value) {
+		return []byte(value), nil
+	}
+	content, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s file: %s", key, err)
+	}
+	return content, nil
+}
+
+// isFile reports if value is the path of an existing file.
+func isFile(value string) bool {
+	_, err := os.Stat(value)
+	return err == nil
+}
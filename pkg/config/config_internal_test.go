@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("#readFileOrInline", func() {
+	It("should read the file at the value", func() {
+		file := filepath.Join(GinkgoT().TempDir(), "rules.json")
+		Expect(os.WriteFile(file, []byte(`{"a": "b"}`), 0600)).To(Succeed())
+
+		content, err := readFileOrInline("Rules", file)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(Equal(`{"a": "b"}`))
+	})
+
+	It("should return the inline value", func() {
+		content, err := readFileOrInline("Rules", `{"a": "b"}`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(Equal(`{"a": "b"}`))
+	})
+
+	It("should return an inline value too long to be a file name", func() {
+		value := `{"` + strings.Repeat("a", 300) + `": "b"}`
+		content, err := readFileOrInline("Rules", value)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(Equal(value))
+
+		labelMap, filePath, err := parseLabelMap(value)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(filePath).To(BeEmpty())
+		Expect(labelMap).To(HaveKeyWithValue(strings.Repeat("a", 300), "b"))
+	})
+
+	It("should fail to read a directory", func() {
+		_, err := readFileOrInline("Rules", GinkgoT().TempDir())
+		Expect(err).To(MatchError(ContainSubstring("failed to open Rules file")))
+	})
+})
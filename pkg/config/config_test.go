@@ -133,9 +133,11 @@ var (
 
 	defaultControllerConfig = ControllerConfig{
 		CtlSyncTimeout:                defaultCtlSyncTimeout,
+		DynamicTargetSource:           TargetSourceCluster,
 		DeletedClientTimeExpiration:   defaultDeletedClientTimeExpiration,
 		MainControllerClientConfig:    defaultMainControllerClientConfig,
 		DefaultControllerClientConfig: defaultControllerClientConfig,
+		RoutingPolicy:                 NewRoutingPolicy(defaultMainControllerClientConfig, defaultControllerClientConfig),
 	}
 
 	defaultURL = parseURL("http://localhost:3100/vali/api/v1/push")
@@ -154,6 +156,7 @@ var _ = Describe("Config", func() {
 	_ = warnLogLevel.Set("warn")
 	_ = infoLogLevel.Set("info")
 	somewhereURL := parseURL("http://somewhere.com:3100/vali/api/v1/push")
+	labelMapFile := createTempLabelMap()
 
 	DescribeTable("Test Config",
 		func(args testArgs) {
@@ -243,7 +246,7 @@ var _ = Describe("Config", func() {
 				"RemoveKeys":    "buzz,fuzz",
 				"LabelKeys":     "foo,bar",
 				"DropSingleKey": "false",
-				"LabelMapPath":  createTempLabelMap(),
+				"LabelMapPath":  labelMapFile,
 			},
 			&Config{
 				PluginConfig: PluginConfig{
@@ -264,6 +267,7 @@ var _ = Describe("Config", func() {
 						},
 						"stream": "stream",
 					},
+					LabelMapPath:         labelMapFile,
 					DynamicHostRegex:     defaultDynamicHostRegex,
 					KubernetesMetadata:   defaultKubernetesMetadata,
 					LabelSetInitCapacity: defaultLabelSetInitCapacity,
@@ -306,15 +310,11 @@ var _ = Describe("Config", func() {
 			},
 			&Config{
 				PluginConfig: PluginConfig{
-					LineFormat:    KvPairFormat,
-					LabelKeys:     []string{"foo", "bar"},
-					RemoveKeys:    []string{"buzz", "fuzz"},
-					DropSingleKey: false,
-					DynamicHostPath: map[string]interface{}{
-						"kubernetes": map[string]interface{}{
-							"namespace_name": "namespace",
-						},
-					},
+					LineFormat:           KvPairFormat,
+					LabelKeys:            []string{"foo", "bar"},
+					RemoveKeys:           []string{"buzz", "fuzz"},
+					DropSingleKey:        false,
+					DynamicHostPath:      []string{"kubernetes.namespace_name"},
 					DynamicHostRegex:     "shoot--",
 					KubernetesMetadata:   defaultKubernetesMetadata,
 					LabelSetInitCapacity: defaultLabelSetInitCapacity,
@@ -338,9 +338,11 @@ var _ = Describe("Config", func() {
 					DynamicHostPrefix:             "http://vali.",
 					DynamicHostSuffix:             ".svc:3100/vali/api/v1/push",
 					CtlSyncTimeout:                defaultCtlSyncTimeout,
+					DynamicTargetSource:           TargetSourceCluster,
 					DeletedClientTimeExpiration:   defaultDeletedClientTimeExpiration,
 					MainControllerClientConfig:    defaultMainControllerClientConfig,
 					DefaultControllerClientConfig: defaultControllerClientConfig,
+					RoutingPolicy:                 NewRoutingPolicy(defaultMainControllerClientConfig, defaultControllerClientConfig),
 				},
 				LogLevel: warnLogLevel,
 			},
@@ -562,9 +564,8 @@ var _ = Describe("Config", func() {
 					DropSingleKey:      defaultDropSingleKey,
 					DynamicHostRegex:   defaultDynamicHostRegex,
 					DynamicTenant: DynamicTenant{
-						Tenant:                                "user",
-						Field:                                 "tag",
-						Regex:                                 "user-exposed.kubernetes.*",
+						Rules:                                 []DynamicTenantRule{{Tenant: "user", Field: "tag", Regex: "user-exposed.kubernetes.*"}},
+						Match:                                 DynamicTenantMatchFirst,
 						RemoveTenantIdWhenSendingToDefaultURL: true,
 					},
 					LabelSetInitCapacity: defaultLabelSetInitCapacity,
@@ -587,9 +588,8 @@ var _ = Describe("Config", func() {
 					DropSingleKey:      defaultDropSingleKey,
 					DynamicHostRegex:   defaultDynamicHostRegex,
 					DynamicTenant: DynamicTenant{
-						Tenant:                                "user",
-						Field:                                 "tag",
-						Regex:                                 "user-exposed.kubernetes.*",
+						Rules:                                 []DynamicTenantRule{{Tenant: "user", Field: "tag", Regex: "user-exposed.kubernetes.*"}},
+						Match:                                 DynamicTenantMatchFirst,
 						RemoveTenantIdWhenSendingToDefaultURL: true,
 					},
 					LabelSetInitCapacity: defaultLabelSetInitCapacity,
@@ -612,9 +612,8 @@ var _ = Describe("Config", func() {
 					DropSingleKey:      defaultDropSingleKey,
 					DynamicHostRegex:   defaultDynamicHostRegex,
 					DynamicTenant: DynamicTenant{
-						Tenant:                                "user",
-						Field:                                 "tag",
-						Regex:                                 "regex with spaces",
+						Rules:                                 []DynamicTenantRule{{Tenant: "user", Field: "tag", Regex: "regex with spaces"}},
+						Match:                                 DynamicTenantMatchFirst,
 						RemoveTenantIdWhenSendingToDefaultURL: true,
 					},
 					LabelSetInitCapacity: defaultLabelSetInitCapacity,
@@ -626,6 +625,35 @@ var _ = Describe("Config", func() {
 			},
 			expectNoError},
 		),
+		Entry("With dynamic tenant rules", testArgs{
+			map[string]string{
+				"DynamicTenantRules": `[{"field": "kubernetes.namespace_name", "regex": "^team-(.+)$", "template": "team-${1}"},
+					{"field": "tag", "regex": "user-exposed", "tenant": "user"}]`,
+				"DynamicTenantMatch":                    "all",
+				"RemoveTenantIdWhenSendingToDefaultURL": "false",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:         defaultJSONFormat,
+					KubernetesMetadata: defaultKubernetesMetadata,
+					DropSingleKey:      defaultDropSingleKey,
+					DynamicHostRegex:   defaultDynamicHostRegex,
+					DynamicTenant: DynamicTenant{
+						Rules: []DynamicTenantRule{
+							{Field: "kubernetes.namespace_name", Regex: "^team-(.+)$", Template: "team-${1}"},
+							{Field: "tag", Regex: "user-exposed", Tenant: "user"},
+						},
+						Match: DynamicTenantMatchAll,
+					},
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
 		Entry("With one field HostnameKeyValue values", testArgs{
 			map[string]string{
 				"HostnameKeyValue": "hostname",
@@ -667,6 +695,612 @@ var _ = Describe("Config", func() {
 			},
 			expectNoError},
 		),
+		Entry("with processors", testArgs{
+			map[string]string{
+				"Processors": `[{"type": "parse-json", "field": "log", "removeSource": true}, {"type": "set-label", "label": "level", "field": "level"}]`,
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					Processors: []ProcessorConfig{
+						{Type: ProcessorParseJSON, Field: "log", RemoveSource: true},
+						{Type: ProcessorSetLabel, Label: "level", Field: "level"},
+					},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with redaction", testArgs{
+			map[string]string{
+				"Redaction": `{"rules": [{"detector": "jwt"}, {"name": "password", "literal": "hunter2", "action": "hash"}], "namespaces": {"garden": [{"name": "ipv4", "regex": "\\d+\\.\\d+\\.\\d+\\.\\d+"}]}}`,
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					Redaction: &RedactionConfig{
+						Rules: []RedactionRule{
+							{Name: DetectorJWT, Detector: DetectorJWT},
+							{Name: "password", Literal: "hunter2", Action: RedactionHash},
+						},
+						Namespaces: map[string][]RedactionRule{
+							"garden": {{Name: "ipv4", Regex: `\d+\.\d+\.\d+\.\d+`}},
+						},
+					},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with multiline preset", testArgs{
+			map[string]string{
+				"MultilinePreset":       "java",
+				"MultilineStartPattern": `^\d{4}-`,
+				"MultilineFlushTimeout": "5s",
+				"MultilineMaxLines":     "100",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					Multiline: &MultilineConfig{
+						Preset: MultilinePresetJava,
+						Pattern: MultilinePattern{
+							Start:        `^\d{4}-`,
+							Continuation: MultilinePresets[MultilinePresetJava].Continuation,
+						},
+						Key:          DefaultMultilineKey,
+						FlushTimeout: 5 * time.Second,
+						MaxLines:     100,
+						MaxBytes:     DefaultMultilineMaxBytes,
+					},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with level detection", testArgs{
+			map[string]string{
+				"DetectLevel":     "true",
+				"LevelKeys":       "level, loglevel",
+				"LevelThresholds": `{"garden": "warn", "*": "info"}`,
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					Severity: &SeverityConfig{
+						Label:      DefaultLevelLabel,
+						Keys:       []string{"level", "loglevel"},
+						MessageKey: DefaultLevelMessageKey,
+						Thresholds: map[string]string{"garden": LevelWarn, AllNamespaces: LevelInfo},
+					},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with line template", testArgs{
+			map[string]string{
+				"LineFormat":   "template",
+				"LineTemplate": `{{.severity | default "info"}} {{.log}}`,
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           TemplateFormat,
+					LineTemplate:         `{{.severity | default "info"}} {{.log}}`,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with timestamp extraction", testArgs{
+			map[string]string{
+				"TimestampKey":      "time",
+				"TimestampFormat":   "unix_ms",
+				"TimestampMaxSkew":  "1h",
+				"TimestampFallback": "now",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					Timestamp:            &TimestampConfig{Key: "time", Format: TimestampUnixMs, MaxSkew: time.Hour, Fallback: TimestampFallbackNow},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with timestamp layout", testArgs{
+			map[string]string{
+				"TimestampKey":    "time",
+				"TimestampFormat": "2006-01-02 15:04:05.000",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					Timestamp:            &TimestampConfig{Key: "time", Format: "2006-01-02 15:04:05.000", Fallback: TimestampFallbackEventTime},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with DropIf and KeepIf rules", testArgs{
+			map[string]string{
+				"DropIf": `{"coredns": "{namespace_name=\"kube-system\",container_name=\"coredns\"} |= \"NOERROR\""}`,
+				"KeepIf": `{"all": "{namespace_name=~\".+\"}"}`,
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					DropIf:               FilterRules{"coredns": `{namespace_name="kube-system",container_name="coredns"} |= "NOERROR"`},
+					KeepIf:               FilterRules{"all": `{namespace_name=~".+"}`},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with pod metadata enrichment", testArgs{
+			map[string]string{
+				"EnrichKubernetesMetadata": "true",
+				"EnrichNodeName":           "node-1",
+				"EnrichAnnotations":        "checksum/config,gardener.cloud/role",
+				"EnrichCacheSize":          "200",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					PodMetadata: &PodMetadataConfig{
+						NodeName:    "node-1",
+						Annotations: []string{"checksum/config", "gardener.cloud/role"},
+						CacheTTL:    DefaultEnrichCacheTTL,
+						CacheSize:   200,
+					},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with pod annotations and enrichment", testArgs{
+			map[string]string{
+				"PodAnnotations":           "drop,logging.gardener.cloud/sample-rate",
+				"PodAnnotationNamespaces":  "garden, kube-system",
+				"EnrichKubernetesMetadata": "true",
+				"EnrichNodeName":           "node-1",
+				"EnrichAnnotations":        "logging.gardener.cloud/drop",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					PodMetadata: &PodMetadataConfig{
+						NodeName:    "node-1",
+						Annotations: []string{"logging.gardener.cloud/drop", "logging.gardener.cloud/sample-rate"},
+						CacheTTL:    DefaultEnrichCacheTTL,
+						CacheSize:   DefaultEnrichCacheSize,
+					},
+					PodAnnotations: &PodAnnotationsConfig{
+						Annotations: []string{PodAnnotationDrop, PodAnnotationSampleRate},
+						Namespaces:  []string{"garden", "kube-system"},
+					},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with dynamic host path candidates and url template", testArgs{
+			map[string]string{
+				"DynamicHostPath":  `["kubernetes.labels.shoot", "kubernetes.namespace_name"]`,
+				"DynamicHostRegex": "^shoot--",
+				"DynamicHostURL":   "http://logging.{{.Cluster}}.svc:3100/vali/api/v1/push",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostPath:      []string{"kubernetes.labels.shoot", "kubernetes.namespace_name"},
+					DynamicHostRegex:     "^shoot--",
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+				},
+				ClientConfig: defaultClientConfig,
+				ControllerConfig: ControllerConfig{
+					DynamicHostURL:                "http://logging.{{.Cluster}}.svc:3100/vali/api/v1/push",
+					CtlSyncTimeout:                defaultCtlSyncTimeout,
+					DynamicTargetSource:           TargetSourceCluster,
+					DeletedClientTimeExpiration:   defaultDeletedClientTimeExpiration,
+					MainControllerClientConfig:    defaultMainControllerClientConfig,
+					DefaultControllerClientConfig: defaultControllerClientConfig,
+					RoutingPolicy:                 NewRoutingPolicy(defaultMainControllerClientConfig, defaultControllerClientConfig),
+				},
+				LogLevel: infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with dynamic host path object in key order", testArgs{
+			map[string]string{
+				"DynamicHostPath":  `{"kubernetes": {"namespace_name": "namespace", "labels": {"shoot": "shoot"}}}`,
+				"DynamicHostRegex": "^shoot--",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostPath:      []string{"kubernetes.labels.shoot", "kubernetes.namespace_name"},
+					DynamicHostRegex:     "^shoot--",
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with tag routing", testArgs{
+			map[string]string{
+				"TagRouting": `[{"match": "journald.*", "labels": {"origin": "journald"}, "tenant": "operator"},
+					{"match": "node.*", "host": "shoot--dev--test"}]`,
+				"InjectTag": "true",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					TagRouting: []TagRoute{
+						{Match: "journald.*", Labels: model.LabelSet{"origin": "journald"}, Tenant: "operator"},
+						{Match: "node.*", Host: "shoot--dev--test"},
+					},
+					InjectTag: true,
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with log metrics", testArgs{
+			map[string]string{
+				"LogMetrics": `[{"name": "etcd_leader_changes_total", "type": "counter", "selector": "{container_name=\"etcd\"}",
+					"regex": "elected leader (?P<member>[0-9a-f]+)", "labels": ["namespace_name", "member"]},
+					{"name": "etcd_db_size_bytes", "type": "gauge", "regex": "db size: (?P<size>\\d+)", "value": "size"}]`,
+				"LogMetricsIdleTimeout": "10m",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					LogMetrics: &LogMetricsConfig{
+						Metrics: []LogMetric{
+							{Name: "etcd_leader_changes_total", Type: LogMetricCounter, Selector: `{container_name="etcd"}`,
+								Regex: "elected leader (?P<member>[0-9a-f]+)", Action: LogMetricInc, Labels: []string{"namespace_name", "member"}},
+							{Name: "etcd_db_size_bytes", Type: LogMetricGauge, Regex: `db size: (?P<size>\d+)`, Action: LogMetricSet, Value: "size"},
+						},
+						MaxSeries:   DefaultLogMetricsMaxSeries,
+						IdleTimeout: 10 * time.Minute,
+					},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with top talkers", testArgs{
+			map[string]string{
+				"TopTalkers":       "20",
+				"TopTalkersWindow": "15m",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					TopTalkers:           &TopTalkersConfig{Size: 20, Window: 15 * time.Minute},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with worker pool", testArgs{
+			map[string]string{
+				"Workers":          "4",
+				"WorkerQueueSize":  "100",
+				"DynamicHostPath":  `["kubernetes.namespace_name"]`,
+				"DynamicHostRegex": "^shoot-",
+				"WorkerShardKey":   "host",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     "^shoot-",
+					DynamicHostPath:      []string{"kubernetes.namespace_name"},
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					WorkerPool:           &WorkerPoolConfig{Workers: 4, QueueSize: 100, ShardKey: WorkerShardHost},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("without workers", testArgs{
+			map[string]string{
+				"Workers": "0",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with conversion", testArgs{
+			map[string]string{
+				"BinaryEncoding":  "base64",
+				"StringifyKeys":   "true",
+				"EventTimeFormat": "unix_ms",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					Conversion:           &ConversionConfig{BinaryEncoding: BinaryEncodingBase64, StringifyKeys: true, EventTimeFormat: EventTimeUnixMs},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with conversion defaults", testArgs{
+			map[string]string{
+				"StringifyKeys": "false",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					Conversion:           &ConversionConfig{BinaryEncoding: BinaryEncodingString, EventTimeFormat: EventTimeRFC3339Nano},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with structured metadata", testArgs{
+			map[string]string{
+				"StructuredMetadata":         "trace_id, request_id,pod_uid=kubernetes.pod_id",
+				"StructuredMetadataEncoding": "json",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					StructuredMetadata: &StructuredMetadataConfig{
+						Attributes: []StructuredMetadataAttribute{
+							{Name: "trace_id", Field: "trace_id"},
+							{Name: "request_id", Field: "request_id"},
+							{Name: "pod_uid", Field: "kubernetes.pod_id"},
+						},
+						Encoding: StructuredMetadataJSON,
+					},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with file target source", testArgs{
+			map[string]string{
+				"DynamicTargetSource": "file",
+				"DynamicTargetFile":   "/etc/fluent-bit/targets.json",
+			},
+			&Config{
+				PluginConfig: defaultPluginConfig,
+				ClientConfig: defaultClientConfig,
+				ControllerConfig: ControllerConfig{
+					CtlSyncTimeout:                defaultCtlSyncTimeout,
+					DynamicTargetSource:           TargetSourceFile,
+					DynamicTargetFile:             "/etc/fluent-bit/targets.json",
+					DeletedClientTimeExpiration:   defaultDeletedClientTimeExpiration,
+					MainControllerClientConfig:    defaultMainControllerClientConfig,
+					DefaultControllerClientConfig: defaultControllerClientConfig,
+					RoutingPolicy:                 NewRoutingPolicy(defaultMainControllerClientConfig, defaultControllerClientConfig),
+				},
+				LogLevel: infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with shoot annotations", testArgs{
+			map[string]string{
+				"ShootAnnotations": "send-to-default, logging.gardener.cloud/extra-labels",
+			},
+			&Config{
+				PluginConfig: defaultPluginConfig,
+				ClientConfig: defaultClientConfig,
+				ControllerConfig: ControllerConfig{
+					CtlSyncTimeout:                defaultCtlSyncTimeout,
+					DynamicTargetSource:           TargetSourceCluster,
+					DeletedClientTimeExpiration:   defaultDeletedClientTimeExpiration,
+					MainControllerClientConfig:    defaultMainControllerClientConfig,
+					DefaultControllerClientConfig: defaultControllerClientConfig,
+					RoutingPolicy:                 NewRoutingPolicy(defaultMainControllerClientConfig, defaultControllerClientConfig),
+					ShootAnnotations:              []string{ShootAnnotationSendToDefault, ShootAnnotationExtraLabels},
+				},
+				LogLevel: infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with embedded log parsing", testArgs{
+			map[string]string{
+				"ParseLog":       "auto",
+				"ParseLogPrefix": "app_",
+			},
+			&Config{
+				PluginConfig: PluginConfig{
+					LineFormat:           defaultJSONFormat,
+					KubernetesMetadata:   defaultKubernetesMetadata,
+					DropSingleKey:        defaultDropSingleKey,
+					DynamicHostRegex:     defaultDynamicHostRegex,
+					LabelSetInitCapacity: defaultLabelSetInitCapacity,
+					PreservedLabels:      model.LabelSet{},
+					ParseLog:             &ParseLogConfig{Format: ParseLogAuto, Key: DefaultParseLogKey, Prefix: "app_"},
+				},
+				ClientConfig:     defaultClientConfig,
+				ControllerConfig: defaultControllerConfig,
+				LogLevel:         infoLogLevel,
+			},
+			expectNoError},
+		),
+		Entry("with routing policy", testArgs{
+			map[string]string{
+				"SendLogsToDefaultClientWhenClusterIsInWakingState": "true",
+				"RoutingTargets": `{"audit": "http://audit.svc:3100/vali/api/v1/push"}`,
+				"RoutingPolicy":  `{"ready": {"targets": ["main", "audit"], "labels": {"state": "ready"}}, "hibernated": {"targets": []}}`,
+			},
+			&Config{
+				PluginConfig: defaultPluginConfig,
+				ClientConfig: defaultClientConfig,
+				ControllerConfig: ControllerConfig{
+					CtlSyncTimeout:              defaultCtlSyncTimeout,
+					DynamicTargetSource:         TargetSourceCluster,
+					DeletedClientTimeExpiration: defaultDeletedClientTimeExpiration,
+					MainControllerClientConfig:  defaultMainControllerClientConfig,
+					DefaultControllerClientConfig: ControllerClientConfiguration{
+						SendLogsWhenIsInCreationState:    defaultAllow,
+						SendLogsWhenIsInReadyState:       defaultDeny,
+						SendLogsWhenIsInHibernatingState: defaultDeny,
+						SendLogsWhenIsInHibernatedState:  defaultDeny,
+						SendLogsWhenIsInWakingState:      defaultAllow,
+						SendLogsWhenIsInDeletionState:    defaultAllow,
+						SendLogsWhenIsInDeletedState:     defaultAllow,
+						SendLogsWhenIsInRestoreState:     defaultAllow,
+						SendLogsWhenIsInMigrationState:   defaultAllow,
+					},
+					RoutingPolicy: RoutingPolicy{
+						ClusterStateCreation:    {Targets: []string{MainRoutingTarget, DefaultRoutingTarget}},
+						ClusterStateReady:       {Targets: []string{MainRoutingTarget, "audit"}, Labels: model.LabelSet{"state": "ready"}},
+						ClusterStateHibernating: {Targets: []string{}},
+						ClusterStateHibernated:  {Targets: []string{}},
+						ClusterStateWaking:      {Targets: []string{MainRoutingTarget, DefaultRoutingTarget}},
+						ClusterStateDeletion:    {Targets: []string{MainRoutingTarget, DefaultRoutingTarget}},
+						ClusterStateDeleted:     {Targets: []string{MainRoutingTarget, DefaultRoutingTarget}},
+						ClusterStateRestore:     {Targets: []string{MainRoutingTarget, DefaultRoutingTarget}},
+						ClusterStateMigration:   {Targets: []string{MainRoutingTarget, DefaultRoutingTarget}},
+					},
+					RoutingTargets: map[string]string{"audit": "http://audit.svc:3100/vali/api/v1/push"},
+				},
+				LogLevel: infoLogLevel,
+			},
+			expectNoError},
+		),
 		Entry("bad url", testArgs{map[string]string{"URL": "::doh.com"}, nil, true}),
 		Entry("bad BatchWait", testArgs{map[string]string{"BatchWait": "a"}, nil, true}),
 		Entry("bad BatchSize", testArgs{map[string]string{"BatchSize": "a"}, nil, true}),
@@ -685,6 +1319,100 @@ var _ = Describe("Config", func() {
 		Entry("bad QueueSync", testArgs{map[string]string{"QueueSegmentSize": "test"}, nil, true}),
 		Entry("bad FallbackToTagWhenMetadataIsMissing value", testArgs{map[string]string{"FallbackToTagWhenMetadataIsMissing": "a"}, nil, true}),
 		Entry("bad DropLogEntryWithoutK8sMetadata value", testArgs{map[string]string{"DropLogEntryWithoutK8sMetadata": "a"}, nil, true}),
+		Entry("bad SendLogsToMainClusterWhenIsInWakingState value", testArgs{map[string]string{"SendLogsToMainClusterWhenIsInWakingState": "a"}, nil, true}),
+		Entry("DynamicHostPath with default DynamicHostRegex", testArgs{map[string]string{"DynamicHostPath": `{"namespace": "namespace"}`}, nil, true}),
+		Entry("bad TagExpression", testArgs{map[string]string{"FallbackToTagWhenMetadataIsMissing": "true", "TagExpression": "(("}, nil, true}),
+		Entry("bad DynamicTenant regex", testArgs{map[string]string{"DynamicTenant": "user tag ((("}, nil, true}),
+		Entry("bad RoutingPolicy", testArgs{map[string]string{"RoutingPolicy": "a"}, nil, true}),
+		Entry("unknown state in RoutingPolicy", testArgs{map[string]string{"RoutingPolicy": `{"sleeping": {"targets": ["main"]}}`}, nil, true}),
+		Entry("unknown target in RoutingPolicy", testArgs{map[string]string{"RoutingPolicy": `{"ready": {"targets": ["audit"]}}`}, nil, true}),
+		Entry("bad Processors", testArgs{map[string]string{"Processors": "a"}, nil, true}),
+		Entry("unknown processor type", testArgs{map[string]string{"Processors": `[{"type": "lua"}]`}, nil, true}),
+		Entry("bad processor regex", testArgs{map[string]string{"Processors": `[{"type": "regex-extract", "field": "log", "regex": "(?P<a>"}]`}, nil, true}),
+		Entry("bad Redaction", testArgs{map[string]string{"Redaction": "a"}, nil, true}),
+		Entry("unknown redaction detector", testArgs{map[string]string{"Redaction": `{"rules": [{"detector": "ssn"}]}`}, nil, true}),
+		Entry("redaction rule without name", testArgs{map[string]string{"Redaction": `{"rules": [{"literal": "hunter2"}]}`}, nil, true}),
+		Entry("redaction rule with regex and literal", testArgs{map[string]string{"Redaction": `{"rules": [{"name": "a", "regex": "a", "literal": "a"}]}`}, nil, true}),
+		Entry("duplicated redaction rule", testArgs{map[string]string{"Redaction": `{"rules": [{"detector": "jwt"}, {"name": "jwt", "regex": "eyJ"}]}`}, nil, true}),
+		Entry("unknown MultilinePreset", testArgs{map[string]string{"MultilinePreset": "ruby"}, nil, true}),
+		Entry("bad MultilineStartPattern", testArgs{map[string]string{"MultilineStartPattern": "(("}, nil, true}),
+		Entry("bad MultilineFlushTimeout", testArgs{map[string]string{"MultilinePreset": "go", "MultilineFlushTimeout": "0s"}, nil, true}),
+		Entry("bad MultilineMaxBytes", testArgs{map[string]string{"MultilinePreset": "go", "MultilineMaxBytes": "-1"}, nil, true}),
+		Entry("bad DetectLevel", testArgs{map[string]string{"DetectLevel": "a"}, nil, true}),
+		Entry("bad LevelLabel", testArgs{map[string]string{"DetectLevel": "true", "LevelLabel": "log-level"}, nil, true}),
+		Entry("unknown level in LevelThresholds", testArgs{map[string]string{"DetectLevel": "true", "LevelThresholds": `{"garden": "unknown"}`}, nil, true}),
+		Entry("bad ParseLog", testArgs{map[string]string{"ParseLog": "xml"}, nil, true}),
+		Entry("bad ParseLogKeepOriginal", testArgs{map[string]string{"ParseLog": "json", "ParseLogKeepOriginal": "a"}, nil, true}),
+		Entry("template LineFormat without LineTemplate", testArgs{map[string]string{"LineFormat": "template"}, nil, true}),
+		Entry("bad LineTemplate", testArgs{map[string]string{"LineFormat": "template", "LineTemplate": "{{.log"}, nil, true}),
+		Entry("unknown function in LineTemplate", testArgs{map[string]string{"LineFormat": "template", "LineTemplate": "{{sha .log}}"}, nil, true}),
+		Entry("bad TimestampFormat", testArgs{map[string]string{"TimestampKey": "time", "TimestampFormat": "iso"}, nil, true}),
+		Entry("bad TimestampMaxSkew", testArgs{map[string]string{"TimestampKey": "time", "TimestampMaxSkew": "-1m"}, nil, true}),
+		Entry("bad TimestampFallback", testArgs{map[string]string{"TimestampKey": "time", "TimestampFallback": "zero"}, nil, true}),
+		Entry("bad DropIf json", testArgs{map[string]string{"DropIf": `["{a=\"b\"}"]`}, nil, true}),
+		Entry("bad DropIf selector", testArgs{map[string]string{"DropIf": `{"noise": "namespace_name=\"kube-system\""}`}, nil, true}),
+		Entry("reserved DropIf rule name", testArgs{map[string]string{"DropIf": `{"KeepIf": "{a=\"b\"}"}`}, nil, true}),
+		Entry("metric query in KeepIf", testArgs{map[string]string{"KeepIf": `{"rate": "rate({a=\"b\"}[1m])"}`}, nil, true}),
+		Entry("bad EnrichKubernetesMetadata", testArgs{map[string]string{"EnrichKubernetesMetadata": "yes"}, nil, true}),
+		Entry("EnrichKubernetesMetadata without EnrichNodeName", testArgs{map[string]string{"EnrichKubernetesMetadata": "true"}, nil, true}),
+		Entry("bad EnrichCacheTTL", testArgs{map[string]string{"EnrichKubernetesMetadata": "true", "EnrichNodeName": "node-1", "EnrichCacheTTL": "0s"}, nil, true}),
+		Entry("bad EnrichCacheSize", testArgs{map[string]string{"EnrichKubernetesMetadata": "true", "EnrichNodeName": "node-1", "EnrichCacheSize": "a"}, nil, true}),
+		Entry("unknown annotation in PodAnnotations", testArgs{map[string]string{"PodAnnotations": "tenant", "PodAnnotationNamespaces": "*"}, nil, true}),
+		Entry("PodAnnotations without PodAnnotationNamespaces", testArgs{map[string]string{"PodAnnotations": "drop"}, nil, true}),
+		Entry("DynamicTenant and DynamicTenantRules", testArgs{map[string]string{"DynamicTenant": "user tag user", "DynamicTenantRules": `[{"field": "tag", "regex": "user", "tenant": "user"}]`}, nil, true}),
+		Entry("DynamicTenantRules rule without tenant", testArgs{map[string]string{"DynamicTenantRules": `[{"field": "tag", "regex": "user"}]`}, nil, true}),
+		Entry("DynamicTenantRules rule with bad regex", testArgs{map[string]string{"DynamicTenantRules": `[{"field": "tag", "regex": "(((", "tenant": "user"}]`}, nil, true}),
+		Entry("unknown DynamicTenantMatch", testArgs{map[string]string{"DynamicTenant": "user tag user", "DynamicTenantMatch": "any"}, nil, true}),
+		Entry("DynamicHostPath with a value which is no path", testArgs{map[string]string{"DynamicHostPath": `{"kubernetes": {"namespace_name": 1}}`, "DynamicHostRegex": "^shoot-"}, nil, true}),
+		Entry("DynamicHostPath with an empty path", testArgs{map[string]string{"DynamicHostPath": `["kubernetes.namespace_name", ""]`, "DynamicHostRegex": "^shoot-"}, nil, true}),
+		Entry("bad DynamicHostURL template", testArgs{map[string]string{"DynamicHostURL": "http://{{.Cluster"}, nil, true}),
+		Entry("TagRouting with host and target", testArgs{map[string]string{"TagRouting": `[{"match": "journald.*", "host": "shoot--dev--test", "target": "audit"}]`}, nil, true}),
+		Entry("TagRouting with bad glob", testArgs{map[string]string{"TagRouting": `[{"match": "journald.[", "tenant": "operator"}]`}, nil, true}),
+		Entry("TagRouting route without effect", testArgs{map[string]string{"TagRouting": `[{"match": "journald.*"}]`}, nil, true}),
+		Entry("TagRouting with invalid label", testArgs{map[string]string{"TagRouting": `[{"match": "journald.*", "labels": {"1-origin": "journald"}}]`}, nil, true}),
+		Entry("TagRouting target without DynamicHostPath", testArgs{map[string]string{
+			"TagRouting":     `[{"match": "journald.*", "target": "audit"}]`,
+			"RoutingTargets": `{"audit": "http://audit:3100/vali/api/v1/push"}`,
+		}, nil, true}),
+		Entry("TagRouting with unknown target", testArgs{map[string]string{
+			"TagRouting":       `[{"match": "journald.*", "target": "unknown"}]`,
+			"DynamicHostPath":  `["kubernetes.namespace_name"]`,
+			"DynamicHostRegex": "^shoot-",
+		}, nil, true}),
+		Entry("bad InjectTag", testArgs{map[string]string{"InjectTag": "maybe"}, nil, true}),
+		Entry("LogMetrics with unknown type", testArgs{map[string]string{"LogMetrics": `[{"name": "lines_total", "type": "summary"}]`}, nil, true}),
+		Entry("LogMetrics with invalid name", testArgs{map[string]string{"LogMetrics": `[{"name": "lines-total", "type": "counter"}]`}, nil, true}),
+		Entry("LogMetrics without value capture", testArgs{map[string]string{"LogMetrics": `[{"name": "size", "type": "gauge", "regex": "size: \\d+"}]`}, nil, true}),
+		Entry("LogMetrics with bad selector", testArgs{map[string]string{"LogMetrics": `[{"name": "lines_total", "type": "counter", "selector": "{container_name}"}]`}, nil, true}),
+		Entry("LogMetrics with buckets on a counter", testArgs{map[string]string{"LogMetrics": `[{"name": "lines_total", "type": "counter", "buckets": [1, 2]}]`}, nil, true}),
+		Entry("LogMetrics with duplicated name", testArgs{map[string]string{
+			"LogMetrics": `[{"name": "lines_total", "type": "counter"}, {"name": "lines_total", "type": "counter", "regex": "error"}]`,
+		}, nil, true}),
+		Entry("LogMetrics with non positive LogMetricsMaxSeries", testArgs{map[string]string{
+			"LogMetrics":          `[{"name": "lines_total", "type": "counter"}]`,
+			"LogMetricsMaxSeries": "0",
+		}, nil, true}),
+		Entry("bad TopTalkers", testArgs{map[string]string{"TopTalkers": "-1"}, nil, true}),
+		Entry("TopTalkersWindow below a minute", testArgs{map[string]string{"TopTalkers": "10", "TopTalkersWindow": "30s"}, nil, true}),
+		Entry("TopTalkersWindow which is not a whole number of minutes", testArgs{map[string]string{"TopTalkers": "10", "TopTalkersWindow": "90s"}, nil, true}),
+		Entry("bad Workers", testArgs{map[string]string{"Workers": "many"}, nil, true}),
+		Entry("bad WorkerQueueSize", testArgs{map[string]string{"Workers": "2", "WorkerQueueSize": "0"}, nil, true}),
+		Entry("bad WorkerShardKey", testArgs{map[string]string{"Workers": "2", "WorkerShardKey": "tag"}, nil, true}),
+		Entry("WorkerShardKey host without DynamicHostPath", testArgs{map[string]string{"Workers": "2", "WorkerShardKey": "host"}, nil, true}),
+		Entry("bad BinaryEncoding", testArgs{map[string]string{"BinaryEncoding": "base32"}, nil, true}),
+		Entry("bad StringifyKeys", testArgs{map[string]string{"StringifyKeys": "maybe"}, nil, true}),
+		Entry("bad EventTimeFormat", testArgs{map[string]string{"EventTimeFormat": "rfc1123"}, nil, true}),
+		Entry("bad StructuredMetadata name", testArgs{map[string]string{"StructuredMetadata": "kubernetes.pod_id"}, nil, true}),
+		Entry("bad StructuredMetadata field", testArgs{map[string]string{"StructuredMetadata": "pod_uid=kubernetes..pod_id"}, nil, true}),
+		Entry("duplicated StructuredMetadata attribute", testArgs{map[string]string{"StructuredMetadata": "trace_id,trace_id=span.trace_id"}, nil, true}),
+		Entry("StructuredMetadata key which is a label", testArgs{map[string]string{"LabelKeys": "trace_id", "StructuredMetadata": "trace_id"}, nil, true}),
+		Entry("bad StructuredMetadataEncoding", testArgs{map[string]string{"StructuredMetadata": "trace_id", "StructuredMetadataEncoding": "yaml"}, nil, true}),
+		Entry("bad DynamicTargetSource", testArgs{map[string]string{"DynamicTargetSource": "secret"}, nil, true}),
+		Entry("file DynamicTargetSource without DynamicTargetFile", testArgs{map[string]string{"DynamicTargetSource": "file"}, nil, true}),
+		Entry("bad ShootAnnotations", testArgs{map[string]string{"ShootAnnotations": "send-to-main,drop"}, nil, true}),
+		Entry("bad RelabelConfigs", testArgs{map[string]string{"RelabelConfigs": `[{"action": "rename"}]`}, nil, true}),
+		Entry("bad ControllerRelabelConfigs", testArgs{map[string]string{"ControllerRelabelConfigs": `[{"action": "replace", "source_labels": ["a"]}]`}, nil, true}),
+		Entry("reserved name in RoutingTargets", testArgs{map[string]string{"RoutingTargets": `{"main": "http://audit.svc:3100"}`}, nil, true}),
 	)
 })
 
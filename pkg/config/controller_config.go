@@ -8,9 +8,13 @@ Modifications Copyright SAP SE or an SAP affiliate company and Gardener contribu
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/prometheus/common/model"
 )
 
 // ControllerConfig hold the configuration fot the Vali client controller
@@ -21,6 +25,12 @@ type ControllerConfig struct {
 	DynamicHostPrefix string
 	// DynamicHostSuffix is the suffix of the dynamic host endpoint
 	DynamicHostSuffix string
+	// DynamicHostURL is a template of the dynamic host endpoint. It replaces the DynamicHostPrefix and DynamicHostSuffix.
+	DynamicHostURL string
+	// DynamicTargetSource is the source of the dynamic targets: Gardener clusters, annotated namespaces or a file.
+	DynamicTargetSource string
+	// DynamicTargetFile is the json file mapping the names of the dynamic targets to their endpoints.
+	DynamicTargetFile string
 	// DeletedClientTimeExpiration is the time after a client for
 	// deleted shoot should be cosidered for removal
 	DeletedClientTimeExpiration time.Duration
@@ -30,6 +40,15 @@ type ControllerConfig struct {
 	// DefaultControllerClientConfig configure to whether to send or not the log to the shoot
 	// Vali for a particular shoot state.
 	DefaultControllerClientConfig ControllerClientConfiguration
+	// RoutingPolicy maps every cluster state to the targets which receive the logs.
+	// It is built from the per state flags above and the RoutingPolicy overrides.
+	RoutingPolicy RoutingPolicy
+	// RoutingTargets are additional named Vali endpoints which can be used in the RoutingPolicy.
+	RoutingTargets map[string]string
+	// RelabelConfigs are applied by the controller clients to the label set of each entry.
+	RelabelConfigs RelabelConfigs
+	// ShootAnnotations are the allowed shoot annotations, without the ShootAnnotationPrefix, which override the routing of a shoot.
+	ShootAnnotations []string
 }
 
 // ControllerClientConfiguration contains flags which
@@ -46,6 +65,16 @@ type ControllerClientConfiguration struct {
 	SendLogsWhenIsInMigrationState   bool
 }
 
+// Sources of the dynamic targets of the controller
+const (
+	// TargetSourceCluster watches the Gardener Cluster resources of a seed.
+	TargetSourceCluster = "cluster"
+	// TargetSourceNamespace watches the namespaces with the endpoint annotation.
+	TargetSourceNamespace = "namespace"
+	// TargetSourceFile watches a json file with the endpoints of the targets.
+	TargetSourceFile = "file"
+)
+
 // DefaultControllerClientConfig is the default controller client configuration
 var DefaultControllerClientConfig = ControllerClientConfiguration{
 	SendLogsWhenIsInCreationState:    true,
@@ -86,6 +115,26 @@ func initControllerConfig(cfg Getter, res *Config) error {
 
 	res.ControllerConfig.DynamicHostPrefix = cfg.Get("DynamicHostPrefix")
 	res.ControllerConfig.DynamicHostSuffix = cfg.Get("DynamicHostSuffix")
+	res.ControllerConfig.DynamicHostURL = cfg.Get("DynamicHostURL")
+	if res.ControllerConfig.DynamicHostURL != "" {
+		if _, err = ParseTemplate("DynamicHostURL", res.ControllerConfig.DynamicHostURL); err != nil {
+			return fmt.Errorf("failed to parse DynamicHostURL: %v", err)
+		}
+	}
+
+	res.ControllerConfig.DynamicTargetSource = TargetSourceCluster
+	if targetSource := cfg.Get("DynamicTargetSource"); targetSource != "" {
+		switch targetSource {
+		case TargetSourceCluster, TargetSourceNamespace, TargetSourceFile:
+			res.ControllerConfig.DynamicTargetSource = targetSource
+		default:
+			return fmt.Errorf("invalid DynamicTargetSource: %s, expected one of %s, %s or %s", targetSource, TargetSourceCluster, TargetSourceNamespace, TargetSourceFile)
+		}
+	}
+	res.ControllerConfig.DynamicTargetFile = cfg.Get("DynamicTargetFile")
+	if res.ControllerConfig.DynamicTargetSource == TargetSourceFile && res.ControllerConfig.DynamicTargetFile == "" {
+		return fmt.Errorf("DynamicTargetSource %s needs DynamicTargetFile", TargetSourceFile)
+	}
 
 	deletedClientTimeExpiration := cfg.Get("DeletedClientTimeExpiration")
 	if deletedClientTimeExpiration != "" {
@@ -97,142 +146,199 @@ func initControllerConfig(cfg Getter, res *Config) error {
 		res.ControllerConfig.DeletedClientTimeExpiration = time.Hour
 	}
 
+	if err = initShootAnnotationsConfig(cfg, res); err != nil {
+		return err
+	}
+
 	return initControllerClientConfig(cfg, res)
 }
 
-func initControllerClientConfig(cfg Getter, res *Config) error {
-	var err error
+// Names of the routing targets which are always present.
+const (
+	// MainRoutingTarget is the Vali instance of the cluster itself.
+	MainRoutingTarget = "main"
+	// DefaultRoutingTarget is the default (seed) Vali instance.
+	DefaultRoutingTarget = "default"
+)
 
-	res.ControllerConfig.MainControllerClientConfig = MainControllerClientConfig
-	res.ControllerConfig.DefaultControllerClientConfig = DefaultControllerClientConfig
+// Cluster states for which a routing policy can be defined.
+const (
+	ClusterStateCreation    = "creation"
+	ClusterStateReady       = "ready"
+	ClusterStateHibernating = "hibernating"
+	ClusterStateHibernated  = "hibernated"
+	ClusterStateWaking      = "waking"
+	ClusterStateDeletion    = "deletion"
+	ClusterStateDeleted     = "deleted"
+	ClusterStateMigration   = "migration"
+	ClusterStateRestore     = "restore"
+)
 
-	sendLogsToMainClusterWhenIsInCreationState := cfg.Get("SendLogsToMainClusterWhenIsInCreationState")
-	if sendLogsToMainClusterWhenIsInCreationState != "" {
-		res.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInCreationState, err = strconv.ParseBool(sendLogsToMainClusterWhenIsInCreationState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToMainClusterWhenIsInCreationState, error: %v", err)
-		}
-	}
+// StateRoute describes where the logs of a cluster in a given state are sent.
+type StateRoute struct {
+	// Targets is the set of targets which receive the logs.
+	// A target is either "main", "default" or the name of a RoutingTargets entry.
+	Targets []string `json:"targets" yaml:"targets"`
+	// Labels are added to every log entry sent while the cluster is in this state.
+	Labels model.LabelSet `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
 
-	sendLogsToMainClusterWhenIsInReadyState := cfg.Get("SendLogsToMainClusterWhenIsInReadyState")
-	if sendLogsToMainClusterWhenIsInReadyState != "" {
-		res.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInReadyState, err = strconv.ParseBool(sendLogsToMainClusterWhenIsInReadyState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToMainClusterWhenIsInReadyState, error: %v", err)
+// SendsTo returns true if the route contains the given target.
+func (r StateRoute) SendsTo(target string) bool {
+	for _, t := range r.Targets {
+		if t == target {
+			return true
 		}
 	}
+	return false
+}
 
-	sendLogsToMainClusterWhenIsInHibernatingState := cfg.Get("SendLogsToMainClusterWhenIsInHibernatingState")
-	if sendLogsToMainClusterWhenIsInHibernatingState != "" {
-		res.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInHibernatingState, err = strconv.ParseBool(sendLogsToMainClusterWhenIsInHibernatingState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToMainClusterWhenIsInHibernatingState, error: %v", err)
-		}
-	}
+// RoutingPolicy maps a cluster state to the route of its logs.
+type RoutingPolicy map[string]StateRoute
 
-	sendLogsToMainClusterWhenIsInHibernatedState := cfg.Get("SendLogsToMainClusterWhenIsInHibernatedState")
-	if sendLogsToMainClusterWhenIsInHibernatedState != "" {
-		res.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInHibernatedState, err = strconv.ParseBool(sendLogsToMainClusterWhenIsInHibernatedState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToMainClusterWhenIsInHibernatedState, error: %v", err)
-		}
-	}
+// Route returns the route for the given cluster state.
+func (p RoutingPolicy) Route(state string) (StateRoute, bool) {
+	r, ok := p[state]
+	return r, ok
+}
 
-	sendLogsToMainClusterWhenIsInDeletionState := cfg.Get("SendLogsToMainClusterWhenIsInDeletionState")
-	if sendLogsToMainClusterWhenIsInDeletionState != "" {
-		res.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInDeletionState, err = strconv.ParseBool(sendLogsToMainClusterWhenIsInDeletionState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToMainClusterWhenIsInDeletionState, error: %v", err)
+// Validate checks that the policy refers only to known cluster states and targets.
+func (p RoutingPolicy) Validate(targets map[string]string) error {
+	for state, route := range p {
+		if !isKnownClusterState(state) {
+			return fmt.Errorf("unknown cluster state %q in RoutingPolicy", state)
 		}
-	}
-
-	sendLogsToMainClusterWhenIsInDeletedState := cfg.Get("SendLogsToMainClusterWhenIsInDeletedState")
-	if sendLogsToMainClusterWhenIsInDeletedState != "" {
-		res.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInDeletedState, err = strconv.ParseBool(sendLogsToMainClusterWhenIsInDeletedState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToMainClusterWhenIsInDeletedState, error: %v", err)
+		for _, t := range route.Targets {
+			if t == MainRoutingTarget || t == DefaultRoutingTarget {
+				continue
+			}
+			if _, ok := targets[t]; !ok {
+				return fmt.Errorf("unknown target %q for cluster state %q in RoutingPolicy", t, state)
+			}
 		}
 	}
+	return nil
+}
 
-	sendLogsToMainClusterWhenIsInRestoreState := cfg.Get("SendLogsToMainClusterWhenIsInRestoreState")
-	if sendLogsToMainClusterWhenIsInRestoreState != "" {
-		res.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInRestoreState, err = strconv.ParseBool(sendLogsToMainClusterWhenIsInRestoreState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToMainClusterWhenIsInRestoreState, error: %v", err)
+// NewRoutingPolicy builds a routing policy out of the per state main and default client flags.
+func NewRoutingPolicy(mainConf, defaultConf ControllerClientConfiguration) RoutingPolicy {
+	policy := make(RoutingPolicy, len(legacyRoutingKeys))
+	for _, k := range legacyRoutingKeys {
+		route := StateRoute{Targets: []string{}}
+		if *k.flag(&mainConf) {
+			route.Targets = append(route.Targets, MainRoutingTarget)
 		}
-	}
-
-	sendLogsToMainClusterWhenIsInMigrationState := cfg.Get("SendLogsToMainClusterWhenIsInMigrationState")
-	if sendLogsToMainClusterWhenIsInMigrationState != "" {
-		res.ControllerConfig.MainControllerClientConfig.SendLogsWhenIsInMigrationState, err = strconv.ParseBool(sendLogsToMainClusterWhenIsInMigrationState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToMainClusterWhenIsInMigrationState, error: %v", err)
+		if *k.flag(&defaultConf) {
+			route.Targets = append(route.Targets, DefaultRoutingTarget)
 		}
+		policy[k.state] = route
 	}
+	return policy
+}
 
-	sendLogsToDefaultClientWhenClusterIsInCreationState := cfg.Get("SendLogsToDefaultClientWhenClusterIsInCreationState")
-	if sendLogsToDefaultClientWhenClusterIsInCreationState != "" {
-		res.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInCreationState, err = strconv.ParseBool(sendLogsToDefaultClientWhenClusterIsInCreationState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToDefaultClientWhenClusterIsInCreationState, error: %v", err)
-		}
-	}
+// legacyRoutingKeys holds for every cluster state the configuration keys
+// which mute or unmute the main and the default client.
+var legacyRoutingKeys = []struct {
+	state      string
+	mainKey    string
+	defaultKey string
+	flag       func(*ControllerClientConfiguration) *bool
+}{
+	{ClusterStateCreation, "SendLogsToMainClusterWhenIsInCreationState", "SendLogsToDefaultClientWhenClusterIsInCreationState",
+		func(c *ControllerClientConfiguration) *bool { return &c.SendLogsWhenIsInCreationState }},
+	{ClusterStateReady, "SendLogsToMainClusterWhenIsInReadyState", "SendLogsToDefaultClientWhenClusterIsInReadyState",
+		func(c *ControllerClientConfiguration) *bool { return &c.SendLogsWhenIsInReadyState }},
+	{ClusterStateHibernating, "SendLogsToMainClusterWhenIsInHibernatingState", "SendLogsToDefaultClientWhenClusterIsInHibernatingState",
+		func(c *ControllerClientConfiguration) *bool { return &c.SendLogsWhenIsInHibernatingState }},
+	{ClusterStateHibernated, "SendLogsToMainClusterWhenIsInHibernatedState", "SendLogsToDefaultClientWhenClusterIsInHibernatedState",
+		func(c *ControllerClientConfiguration) *bool { return &c.SendLogsWhenIsInHibernatedState }},
+	{ClusterStateWaking, "SendLogsToMainClusterWhenIsInWakingState", "SendLogsToDefaultClientWhenClusterIsInWakingState",
+		func(c *ControllerClientConfiguration) *bool { return &c.SendLogsWhenIsInWakingState }},
+	{ClusterStateDeletion, "SendLogsToMainClusterWhenIsInDeletionState", "SendLogsToDefaultClientWhenClusterIsInDeletionState",
+		func(c *ControllerClientConfiguration) *bool { return &c.SendLogsWhenIsInDeletionState }},
+	{ClusterStateDeleted, "SendLogsToMainClusterWhenIsInDeletedState", "SendLogsToDefaultClientWhenClusterIsInDeletedState",
+		func(c *ControllerClientConfiguration) *bool { return &c.SendLogsWhenIsInDeletedState }},
+	{ClusterStateRestore, "SendLogsToMainClusterWhenIsInRestoreState", "SendLogsToDefaultClientWhenClusterIsInRestoreState",
+		func(c *ControllerClientConfiguration) *bool { return &c.SendLogsWhenIsInRestoreState }},
+	{ClusterStateMigration, "SendLogsToMainClusterWhenIsInMigrationState", "SendLogsToDefaultClientWhenClusterIsInMigrationState",
+		func(c *ControllerClientConfiguration) *bool { return &c.SendLogsWhenIsInMigrationState }},
+}
 
-	sendLogsToDefaultClientWhenClusterIsInReadyState := cfg.Get("SendLogsToDefaultClientWhenClusterIsInReadyState")
-	if sendLogsToDefaultClientWhenClusterIsInReadyState != "" {
-		res.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInReadyState, err = strconv.ParseBool(sendLogsToDefaultClientWhenClusterIsInReadyState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToDefaultClientWhenClusterIsInReadyState, error: %v", err)
+func isKnownClusterState(state string) bool {
+	for _, k := range legacyRoutingKeys {
+		if k.state == state {
+			return true
 		}
 	}
+	return false
+}
 
-	sendLogsToDefaultClientWhenClusterIsInHibernatingState := cfg.Get("SendLogsToDefaultClientWhenClusterIsInHibernatingState")
-	if sendLogsToDefaultClientWhenClusterIsInHibernatingState != "" {
-		res.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInHibernatingState, err = strconv.ParseBool(sendLogsToDefaultClientWhenClusterIsInHibernatingState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToDefaultClientWhenClusterIsInHibernatingState, error: %v", err)
-		}
-	}
+func initControllerClientConfig(cfg Getter, res *Config) error {
+	var err error
 
-	sendLogsToDefaultClientWhenClusterIsInHibernatedState := cfg.Get("SendLogsToDefaultClientWhenClusterIsInHibernatedState")
-	if sendLogsToDefaultClientWhenClusterIsInHibernatedState != "" {
-		res.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInHibernatedState, err = strconv.ParseBool(sendLogsToDefaultClientWhenClusterIsInHibernatedState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToDefaultClientWhenClusterIsInHibernatedState, error: %v", err)
-		}
-	}
+	res.ControllerConfig.MainControllerClientConfig = MainControllerClientConfig
+	res.ControllerConfig.DefaultControllerClientConfig = DefaultControllerClientConfig
 
-	sendLogsToDefaultClientWhenClusterIsInDeletionState := cfg.Get("SendLogsToDefaultClientWhenClusterIsInDeletionState")
-	if sendLogsToDefaultClientWhenClusterIsInDeletionState != "" {
-		res.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInDeletionState, err = strconv.ParseBool(sendLogsToDefaultClientWhenClusterIsInDeletionState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToDefaultClientWhenClusterIsInDeletionState, error: %v", err)
+	// The per state flags are still supported and build the base of the routing policy.
+	for _, k := range legacyRoutingKeys {
+		if value := cfg.Get(k.mainKey); value != "" {
+			if *k.flag(&res.ControllerConfig.MainControllerClientConfig), err = strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("invalid value for %s, error: %v", k.mainKey, err)
+			}
+		}
+		if value := cfg.Get(k.defaultKey); value != "" {
+			if *k.flag(&res.ControllerConfig.DefaultControllerClientConfig), err = strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("invalid value for %s, error: %v", k.defaultKey, err)
+			}
 		}
 	}
 
-	sendLogsToDefaultClientWhenClusterIsInDeletedState := cfg.Get("SendLogsToDefaultClientWhenClusterIsInDeletedState")
-	if sendLogsToDefaultClientWhenClusterIsInDeletedState != "" {
-		res.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInDeletedState, err = strconv.ParseBool(sendLogsToDefaultClientWhenClusterIsInDeletedState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToDefaultClientWhenClusterIsInDeletedState, error: %v", err)
+	routingTargets := cfg.Get("RoutingTargets")
+	if routingTargets != "" {
+		if err := json.Unmarshal([]byte(routingTargets), &res.ControllerConfig.RoutingTargets); err != nil {
+			return fmt.Errorf("failed to Unmarshal RoutingTargets json: %s", err)
+		}
+		for name, url := range res.ControllerConfig.RoutingTargets {
+			if name == MainRoutingTarget || name == DefaultRoutingTarget {
+				return fmt.Errorf("RoutingTargets can't redefine the reserved target %q", name)
+			}
+			var targetURL flagext.URLValue
+			if err := targetURL.Set(url); err != nil {
+				return fmt.Errorf("failed to parse URL %q of routing target %q: %v", url, name, err)
+			}
 		}
 	}
 
-	sendLogsToDefaultClientWhenClusterIsInRestoreState := cfg.Get("SendLogsToDefaultClientWhenClusterIsInRestoreState")
-	if sendLogsToDefaultClientWhenClusterIsInRestoreState != "" {
-		res.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInRestoreState, err = strconv.ParseBool(sendLogsToDefaultClientWhenClusterIsInRestoreState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToDefaultClientWhenClusterIsInRestoreState, error: %v", err)
+	controllerRelabelConfigs := cfg.Get("ControllerRelabelConfigs")
+	if controllerRelabelConfigs != "" {
+		if res.ControllerConfig.RelabelConfigs, err = parseRelabelConfigs("ControllerRelabelConfigs", controllerRelabelConfigs); err != nil {
+			return err
 		}
 	}
 
-	sendLogsToDefaultClientWhenClusterIsInMigrationState := cfg.Get("SendLogsToDefaultClientWhenClusterIsInMigrationState")
-	if sendLogsToDefaultClientWhenClusterIsInMigrationState != "" {
-		res.ControllerConfig.DefaultControllerClientConfig.SendLogsWhenIsInMigrationState, err = strconv.ParseBool(sendLogsToDefaultClientWhenClusterIsInMigrationState)
-		if err != nil {
-			return fmt.Errorf("invalid value for SendLogsToDefaultClientWhenClusterIsInMigrationState, error: %v", err)
+	res.ControllerConfig.RoutingPolicy, err = newRoutingPolicy(res.ControllerConfig, cfg.Get("RoutingPolicy"))
+	return err
+}
+
+// newRoutingPolicy builds the routing policy out of the per state flags and applies the
+// json encoded routing policy on top of it. Routes set in the json take precedence.
+func newRoutingPolicy(conf ControllerConfig, routingPolicy string) (RoutingPolicy, error) {
+	policy := NewRoutingPolicy(conf.MainControllerClientConfig, conf.DefaultControllerClientConfig)
+	if routingPolicy != "" {
+		var overrides RoutingPolicy
+		if err := json.Unmarshal([]byte(routingPolicy), &overrides); err != nil {
+			return nil, fmt.Errorf("failed to Unmarshal RoutingPolicy json: %s", err)
+		}
+		for state, route := range overrides {
+			if route.Targets == nil {
+				route.Targets = []string{}
+			}
+			policy[state] = route
 		}
 	}
 
-	return nil
+	if err := policy.Validate(conf.RoutingTargets); err != nil {
+		return nil, err
+	}
+	return policy, nil
 }
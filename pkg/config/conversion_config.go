@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Encodings of the byte values which are not valid UTF-8
+const (
+	BinaryEncodingString = "string"
+	BinaryEncodingBase64 = "base64"
+	BinaryEncodingHex    = "hex"
+)
+
+// Formats of the time values in the records
+const (
+	EventTimeRFC3339Nano = "rfc3339nano"
+	EventTimeUnix        = "unix"
+	EventTimeUnixMs      = "unix_ms"
+	EventTimeUnixNs      = "unix_ns"
+)
+
+// ConversionConfig holds the configuration of the conversion of the msgpack values of the records.
+type ConversionConfig struct {
+	// BinaryEncoding is the encoding of the byte values which are not valid UTF-8.
+	BinaryEncoding string
+	// StringifyKeys keeps the non string keys as strings instead of dropping them.
+	StringifyKeys bool
+	// EventTimeFormat is the format of the time values, like the fluent-bit event time, in the records.
+	EventTimeFormat string
+}
+
+// initConversionConfig parses the conversion keys. Without them the default conversion applies.
+func initConversionConfig(cfg Getter, res *Config) error {
+	binaryEncoding, stringifyKeys, eventTimeFormat := cfg.Get("BinaryEncoding"), cfg.Get("StringifyKeys"), cfg.Get("EventTimeFormat")
+	if binaryEncoding == "" && stringifyKeys == "" && eventTimeFormat == "" {
+		return nil
+	}
+
+	conf := &ConversionConfig{BinaryEncoding: BinaryEncodingString, EventTimeFormat: EventTimeRFC3339Nano}
+	if binaryEncoding != "" {
+		switch binaryEncoding {
+		case BinaryEncodingString, BinaryEncodingBase64, BinaryEncodingHex:
+			conf.BinaryEncoding = binaryEncoding
+		default:
+			return fmt.Errorf("invalid BinaryEncoding: %s, expected one of %s, %s or %s", binaryEncoding, BinaryEncodingString, BinaryEncodingBase64, BinaryEncodingHex)
+		}
+	}
+	if stringifyKeys != "" {
+		var err error
+		if conf.StringifyKeys, err = strconv.ParseBool(stringifyKeys); err != nil {
+			return fmt.Errorf("invalid value for StringifyKeys, error: %v", err)
+		}
+	}
+	if eventTimeFormat != "" {
+		switch eventTimeFormat {
+		case EventTimeRFC3339Nano, EventTimeUnix, EventTimeUnixMs, EventTimeUnixNs:
+			conf.EventTimeFormat = eventTimeFormat
+		default:
+			return fmt.Errorf("invalid EventTimeFormat: %s, expected one of %s, %s, %s or %s", eventTimeFormat, EventTimeRFC3339Nano, EventTimeUnix, EventTimeUnixMs, EventTimeUnixNs)
+		}
+	}
+
+	res.PluginConfig.Conversion = conf
+	return nil
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Policies for records which match more than one DynamicTenant rule
+const (
+	// DynamicTenantMatchFirst takes the tenant of the first matching rule.
+	DynamicTenantMatchFirst = "first"
+	// DynamicTenantMatchAll sends the record to the tenants of all matching rules.
+	DynamicTenantMatchAll = "all"
+)
+
+// DynamicTenantRule sets the tenant of the records whose field matches the regex.
+type DynamicTenantRule struct {
+	// Field is the path of the record value, nested keys are separated by dots.
+	Field string `json:"field" yaml:"field"`
+	// Regex is matched against the field value.
+	Regex string `json:"regex" yaml:"regex"`
+	// Tenant is the tenant of the matching records.
+	Tenant string `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+	// Template is expanded with the capture groups of the regex, e.g. "team-${1}". It replaces the Tenant.
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+}
+
+// Validate checks that the rule has a field, a valid regex and exactly one of tenant and template.
+func (r DynamicTenantRule) Validate() error {
+	if r.Field == "" {
+		return fmt.Errorf("rule without field")
+	}
+	if _, err := regexp.Compile(r.Regex); err != nil {
+		return fmt.Errorf("rule for field %s has invalid regex %q: %v", r.Field, r.Regex, err)
+	}
+	if (r.Tenant == "") == (r.Template == "") {
+		return fmt.Errorf("rule for field %s needs exactly one of tenant and template", r.Field)
+	}
+	return nil
+}
+
+// initDynamicTenantConfig parses either the single DynamicTenant rule or the DynamicTenantRules.
+func initDynamicTenantConfig(cfg Getter, res *Config) error {
+	var err error
+	conf := &res.PluginConfig.DynamicTenant
+
+	dynamicTenant := strings.Trim(cfg.Get("DynamicTenant"), " ")
+	rules := cfg.Get("DynamicTenantRules")
+	switch {
+	case dynamicTenant != "" && rules != "":
+		return fmt.Errorf("DynamicTenant and DynamicTenantRules can not be set together")
+	case dynamicTenant != "":
+		dynamicTenantValues := strings.SplitN(dynamicTenant, " ", 3)
+		if len(dynamicTenantValues) != 3 {
+			return fmt.Errorf("failed to parse DynamicTenant. Should consist of <tenant-name>\" \"<field-for-regex>\" \"<regex>. Found %d elements", len(dynamicTenantValues))
+		}
+		rule := DynamicTenantRule{Tenant: dynamicTenantValues[0], Field: dynamicTenantValues[1], Regex: dynamicTenantValues[2]}
+		if err = rule.Validate(); err != nil {
+			return fmt.Errorf("invalid DynamicTenant: %v", err)
+		}
+		conf.Rules = []DynamicTenantRule{rule}
+	case rules != "":
+		if conf.Rules, err = parseDynamicTenantRules(rules); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	conf.Match = DynamicTenantMatchFirst
+	if match := cfg.Get("DynamicTenantMatch"); match != "" {
+		switch match {
+		case DynamicTenantMatchFirst, DynamicTenantMatchAll:
+			conf.Match = match
+		default:
+			return fmt.Errorf("invalid DynamicTenantMatch: %s, expected %s or %s", match, DynamicTenantMatchFirst, DynamicTenantMatchAll)
+		}
+	}
+
+	conf.RemoveTenantIdWhenSendingToDefaultURL = true
+	if removeTenantIdWhenSendingToDefaultURL := cfg.Get("RemoveTenantIdWhenSendingToDefaultURL"); removeTenantIdWhenSendingToDefaultURL != "" {
+		conf.RemoveTenantIdWhenSendingToDefaultURL, err = strconv.ParseBool(removeTenantIdWhenSendingToDefaultURL)
+		if err != nil {
+			return fmt.Errorf("invalid value for RemoveTenantIdWhenSendingToDefaultURL, error: %v", err)
+		}
+	}
+	return nil
+}
+
+// parseDynamicTenantRules parses the rules either from the file at rules or from the inline json.
+func parseDynamicTenantRules(rules string) ([]DynamicTenantRule, error) {
+	content, err := readFileOrInline("DynamicTenantRules", rules)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []DynamicTenantRule
+	if err := json.Unmarshal(content, &res); err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal DynamicTenantRules json: %s", err)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("DynamicTenantRules has no rules")
+	}
+	for i, rule := range res {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid DynamicTenantRules rule %d: %v", i, err)
+		}
+	}
+	return res, nil
+}
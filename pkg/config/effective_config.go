@@ -0,0 +1,252 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"gopkg.in/yaml.v2"
+)
+
+// EffectiveConfig returns the parsed configuration as ordered key/value pairs
+// named after the fluent-bit configuration keys.
+func EffectiveConfig(conf *Config) yaml.MapSlice {
+	valiConfig := conf.ClientConfig.CredativValiConfig
+	res := yaml.MapSlice{
+		{Key: "URL", Value: valiConfig.URL.String()},
+		{Key: "TenantID", Value: valiConfig.TenantID},
+		{Key: "BatchWait", Value: valiConfig.BatchWait.String()},
+		{Key: "BatchSize", Value: valiConfig.BatchSize},
+		{Key: "Labels", Value: valiConfig.ExternalLabels.String()},
+		{Key: "LogLevel", Value: conf.LogLevel.String()},
+		{Key: "AutoKubernetesLabels", Value: conf.PluginConfig.AutoKubernetesLabels},
+		{Key: "RemoveKeys", Value: conf.PluginConfig.RemoveKeys},
+		{Key: "LabelKeys", Value: conf.PluginConfig.LabelKeys},
+		{Key: "LineFormat", Value: conf.PluginConfig.LineFormat.String()},
+		{Key: "LineTemplate", Value: conf.PluginConfig.LineTemplate},
+		{Key: "DropSingleKey", Value: conf.PluginConfig.DropSingleKey},
+		{Key: "LabelMapPath", Value: conf.PluginConfig.LabelMapPath},
+		{Key: "LabelMap", Value: conf.PluginConfig.LabelMap},
+		{Key: "SortByTimestamp", Value: conf.ClientConfig.SortByTimestamp},
+		{Key: "DynamicHostPath", Value: conf.PluginConfig.DynamicHostPath},
+		{Key: "DynamicHostPrefix", Value: conf.ControllerConfig.DynamicHostPrefix},
+		{Key: "DynamicHostSuffix", Value: conf.ControllerConfig.DynamicHostSuffix},
+		{Key: "DynamicHostURL", Value: conf.ControllerConfig.DynamicHostURL},
+		{Key: "DynamicTargetSource", Value: conf.ControllerConfig.DynamicTargetSource},
+		{Key: "DynamicTargetFile", Value: conf.ControllerConfig.DynamicTargetFile},
+		{Key: "DynamicHostRegex", Value: conf.PluginConfig.DynamicHostRegex},
+		{Key: "Timeout", Value: valiConfig.Timeout.String()},
+		{Key: "MinBackoff", Value: valiConfig.BackoffConfig.MinBackoff.String()},
+		{Key: "MaxBackoff", Value: valiConfig.BackoffConfig.MaxBackoff.String()},
+		{Key: "MaxRetries", Value: valiConfig.BackoffConfig.MaxRetries},
+		{Key: "Buffer", Value: conf.ClientConfig.BufferConfig.Buffer},
+		{Key: "BufferType", Value: conf.ClientConfig.BufferConfig.BufferType},
+		{Key: "QueueDir", Value: conf.ClientConfig.BufferConfig.DqueConfig.QueueDir},
+		{Key: "QueueSegmentSize", Value: conf.ClientConfig.BufferConfig.DqueConfig.QueueSegmentSize},
+		{Key: "QueueSync", Value: conf.ClientConfig.BufferConfig.DqueConfig.QueueSync},
+		{Key: "QueueName", Value: conf.ClientConfig.BufferConfig.DqueConfig.QueueName},
+		{Key: "FallbackToTagWhenMetadataIsMissing", Value: conf.PluginConfig.KubernetesMetadata.FallbackToTagWhenMetadataIsMissing},
+		{Key: "TagKey", Value: conf.PluginConfig.KubernetesMetadata.TagKey},
+		{Key: "TagPrefix", Value: conf.PluginConfig.KubernetesMetadata.TagPrefix},
+		{Key: "TagExpression", Value: conf.PluginConfig.KubernetesMetadata.TagExpression},
+		{Key: "DropLogEntryWithoutK8sMetadata", Value: conf.PluginConfig.KubernetesMetadata.DropLogEntryWithoutK8sMetadata},
+		{Key: "EnrichKubernetesMetadata", Value: podMetadataSlice(conf.PluginConfig.PodMetadata)},
+		{Key: "PodAnnotations", Value: podAnnotationsSlice(conf.PluginConfig.PodAnnotations)},
+		{Key: "ControllerSyncTimeout", Value: conf.ControllerConfig.CtlSyncTimeout.String()},
+		{Key: "NumberOfBatchIDs", Value: conf.ClientConfig.NumberOfBatchIDs},
+		{Key: "IdLabelName", Value: string(conf.ClientConfig.IdLabelName)},
+		{Key: "DeletedClientTimeExpiration", Value: conf.ControllerConfig.DeletedClientTimeExpiration.String()},
+		{Key: "DynamicTenant", Value: dynamicTenantSlice(conf.PluginConfig.DynamicTenant)},
+		{Key: "RemoveTenantIdWhenSendingToDefaultURL", Value: conf.PluginConfig.DynamicTenant.RemoveTenantIdWhenSendingToDefaultURL},
+		{Key: "TagRouting", Value: conf.PluginConfig.TagRouting},
+		{Key: "InjectTag", Value: conf.PluginConfig.InjectTag},
+		{Key: "HostnameKeyValue", Value: hostnameKeyValueString(conf.PluginConfig)},
+		{Key: "Pprof", Value: conf.Pprof},
+		{Key: "LabelSetInitCapacity", Value: conf.PluginConfig.LabelSetInitCapacity},
+		{Key: "PreservedLabels", Value: conf.PluginConfig.PreservedLabels},
+		{Key: "EnableMultiTenancy", Value: conf.PluginConfig.EnableMultiTenancy},
+		{Key: "HotReload", Value: conf.PluginConfig.HotReload},
+		{Key: "ReloadConfigPath", Value: conf.PluginConfig.ReloadConfigPath},
+		{Key: "RelabelConfigs", Value: conf.PluginConfig.RelabelConfigs},
+		{Key: "DropIf", Value: conf.PluginConfig.DropIf},
+		{Key: "KeepIf", Value: conf.PluginConfig.KeepIf},
+		{Key: "ControllerRelabelConfigs", Value: conf.ControllerConfig.RelabelConfigs},
+		{Key: "RoutingTargets", Value: conf.ControllerConfig.RoutingTargets},
+		{Key: "ShootAnnotations", Value: conf.ControllerConfig.ShootAnnotations},
+		{Key: "Processors", Value: conf.PluginConfig.Processors},
+		{Key: "Redaction", Value: conf.PluginConfig.Redaction},
+		{Key: "Multiline", Value: multilineSlice(conf.PluginConfig.Multiline)},
+		{Key: "ParseLog", Value: parseLogSlice(conf.PluginConfig.ParseLog)},
+		{Key: "Severity", Value: severitySlice(conf.PluginConfig.Severity)},
+		{Key: "Timestamp", Value: timestampSlice(conf.PluginConfig.Timestamp)},
+		{Key: "LogMetrics", Value: logMetricsSlice(conf.PluginConfig.LogMetrics)},
+		{Key: "TopTalkers", Value: topTalkersSlice(conf.PluginConfig.TopTalkers)},
+		{Key: "WorkerPool", Value: workerPoolSlice(conf.PluginConfig.WorkerPool)},
+		{Key: "Conversion", Value: conversionSlice(conf.PluginConfig.Conversion)},
+		{Key: "StructuredMetadata", Value: structuredMetadataSlice(conf.PluginConfig.StructuredMetadata)},
+		{Key: "RoutingPolicy", Value: routingPolicySlice(conf.ControllerConfig.RoutingPolicy)},
+	}
+	return res
+}
+
+func dynamicTenantSlice(t DynamicTenant) yaml.MapSlice {
+	if len(t.Rules) == 0 {
+		return nil
+	}
+	return yaml.MapSlice{
+		{Key: "DynamicTenantRules", Value: t.Rules},
+		{Key: "DynamicTenantMatch", Value: t.Match},
+	}
+}
+
+func hostnameKeyValueString(c PluginConfig) string {
+	switch {
+	case c.HostnameKey == nil:
+		return ""
+	case c.HostnameValue == nil:
+		return *c.HostnameKey
+	default:
+		return *c.HostnameKey + " " + *c.HostnameValue
+	}
+}
+
+// routingPolicySlice orders the routing policy by the cluster states.
+func routingPolicySlice(policy RoutingPolicy) yaml.MapSlice {
+	res := yaml.MapSlice{}
+	for _, k := range legacyRoutingKeys {
+		if route, ok := policy[k.state]; ok {
+			res = append(res, yaml.MapItem{Key: k.state, Value: route})
+		}
+	}
+	return res
+}
+
+func multilineSlice(conf *MultilineConfig) yaml.MapSlice {
+	if conf == nil {
+		return nil
+	}
+	return yaml.MapSlice{
+		{Key: "MultilinePreset", Value: conf.Preset},
+		{Key: "MultilineStartPattern", Value: conf.Pattern.Start},
+		{Key: "MultilineContinuationPattern", Value: conf.Pattern.Continuation},
+		{Key: "MultilineKey", Value: conf.Key},
+		{Key: "MultilineFlushTimeout", Value: conf.FlushTimeout.String()},
+		{Key: "MultilineMaxLines", Value: conf.MaxLines},
+		{Key: "MultilineMaxBytes", Value: conf.MaxBytes},
+	}
+}
+
+func severitySlice(conf *SeverityConfig) yaml.MapSlice {
+	if conf == nil {
+		return nil
+	}
+	return yaml.MapSlice{
+		{Key: "LevelLabel", Value: conf.Label},
+		{Key: "LevelKeys", Value: conf.Keys},
+		{Key: "LevelMessageKey", Value: conf.MessageKey},
+		{Key: "LevelThresholds", Value: conf.Thresholds},
+	}
+}
+
+func parseLogSlice(conf *ParseLogConfig) yaml.MapSlice {
+	if conf == nil {
+		return nil
+	}
+	return yaml.MapSlice{
+		{Key: "ParseLog", Value: conf.Format},
+		{Key: "ParseLogKey", Value: conf.Key},
+		{Key: "ParseLogPrefix", Value: conf.Prefix},
+		{Key: "ParseLogKeepOriginal", Value: conf.KeepOriginal},
+	}
+}
+
+func timestampSlice(conf *TimestampConfig) yaml.MapSlice {
+	if conf == nil {
+		return nil
+	}
+	return yaml.MapSlice{
+		{Key: "TimestampKey", Value: conf.Key},
+		{Key: "TimestampFormat", Value: conf.Format},
+		{Key: "TimestampMaxSkew", Value: conf.MaxSkew.String()},
+		{Key: "TimestampFallback", Value: conf.Fallback},
+	}
+}
+
+func logMetricsSlice(conf *LogMetricsConfig) yaml.MapSlice {
+	if conf == nil {
+		return nil
+	}
+	return yaml.MapSlice{
+		{Key: "LogMetrics", Value: conf.Metrics},
+		{Key: "LogMetricsMaxSeries", Value: conf.MaxSeries},
+		{Key: "LogMetricsIdleTimeout", Value: conf.IdleTimeout.String()},
+	}
+}
+
+func topTalkersSlice(conf *TopTalkersConfig) yaml.MapSlice {
+	if conf == nil {
+		return nil
+	}
+	return yaml.MapSlice{
+		{Key: "TopTalkers", Value: conf.Size},
+		{Key: "TopTalkersWindow", Value: conf.Window.String()},
+	}
+}
+
+func workerPoolSlice(conf *WorkerPoolConfig) yaml.MapSlice {
+	if conf == nil {
+		return nil
+	}
+	return yaml.MapSlice{
+		{Key: "Workers", Value: conf.Workers},
+		{Key: "WorkerQueueSize", Value: conf.QueueSize},
+		{Key: "WorkerShardKey", Value: conf.ShardKey},
+	}
+}
+
+func conversionSlice(conf *ConversionConfig) yaml.MapSlice {
+	if conf == nil {
+		return nil
+	}
+	return yaml.MapSlice{
+		{Key: "BinaryEncoding", Value: conf.BinaryEncoding},
+		{Key: "StringifyKeys", Value: conf.StringifyKeys},
+		{Key: "EventTimeFormat", Value: conf.EventTimeFormat},
+	}
+}
+
+func structuredMetadataSlice(conf *StructuredMetadataConfig) yaml.MapSlice {
+	if conf == nil {
+		return nil
+	}
+	attributes := make(yaml.MapSlice, 0, len(conf.Attributes))
+	for _, a := range conf.Attributes {
+		attributes = append(attributes, yaml.MapItem{Key: string(a.Name), Value: a.Field})
+	}
+	return yaml.MapSlice{
+		{Key: "Attributes", Value: attributes},
+		{Key: "Encoding", Value: conf.Encoding},
+	}
+}
+
+func podMetadataSlice(conf *PodMetadataConfig) yaml.MapSlice {
+	if conf == nil {
+		return nil
+	}
+	return yaml.MapSlice{
+		{Key: "EnrichNodeName", Value: conf.NodeName},
+		{Key: "EnrichAnnotations", Value: conf.Annotations},
+		{Key: "EnrichCacheTTL", Value: conf.CacheTTL.String()},
+		{Key: "EnrichCacheSize", Value: conf.CacheSize},
+	}
+}
+
+func podAnnotationsSlice(conf *PodAnnotationsConfig) yaml.MapSlice {
+	if conf == nil {
+		return nil
+	}
+	return yaml.MapSlice{
+		{Key: "PodAnnotations", Value: conf.Annotations},
+		{Key: "PodAnnotationNamespaces", Value: conf.Namespaces},
+	}
+}
@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/credativ/vali/pkg/logql"
+)
+
+// KeepIfRule is the rule by which the entries matching no KeepIf rule are counted. No rule can be named like it.
+const KeepIfRule = "KeepIf"
+
+// FilterRules maps rule names to LogQL log selectors, e.g. `{namespace="kube-system"} |= "NOERROR"`.
+type FilterRules map[string]string
+
+// parseFilterRules parses the rules either from the file at rules or from the inline json
+// and validates the selectors.
+func parseFilterRules(key, rules string) (FilterRules, error) {
+	content, err := readFileOrInline(key, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	var res FilterRules
+	if err := json.Unmarshal(content, &res); err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal %s json: %s", key, err)
+	}
+	for name, selector := range res {
+		if name == "" {
+			return nil, fmt.Errorf("empty rule name in %s", key)
+		}
+		if name == KeepIfRule {
+			return nil, fmt.Errorf("invalid %s rule name %s, it is reserved", key, name)
+		}
+		if _, err := logql.ParseLogSelector(selector); err != nil {
+			return nil, fmt.Errorf("invalid %s rule %s: %v", key, name, err)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/credativ/vali/pkg/logql"
+	"github.com/prometheus/common/model"
+)
+
+// Types of the log metrics
+const (
+	LogMetricCounter   = "counter"
+	LogMetricGauge     = "gauge"
+	LogMetricHistogram = "histogram"
+)
+
+// Actions of the log metrics
+const (
+	LogMetricInc     = "inc"
+	LogMetricAdd     = "add"
+	LogMetricDec     = "dec"
+	LogMetricSub     = "sub"
+	LogMetricSet     = "set"
+	LogMetricObserve = "observe"
+)
+
+// Defaults of the log metrics
+const (
+	DefaultLogMetricsMaxSeries   = 1000
+	DefaultLogMetricsIdleTimeout = 5 * time.Minute
+)
+
+// logMetricActions are the actions of each metric type. The first one is the default.
+var logMetricActions = map[string][]string{
+	LogMetricCounter:   {LogMetricInc, LogMetricAdd},
+	LogMetricGauge:     {LogMetricSet, LogMetricInc, LogMetricDec, LogMetricAdd, LogMetricSub},
+	LogMetricHistogram: {LogMetricObserve},
+}
+
+// LogMetric turns the matching log lines into a Prometheus metric.
+type LogMetric struct {
+	// Name of the metric, which is exposed with the fluentbit_vali_gardener_log_ prefix.
+	Name string `json:"name" yaml:"name"`
+	// Help of the metric.
+	Help string `json:"help,omitempty" yaml:"help,omitempty"`
+	// Type is counter, gauge or histogram.
+	Type string `json:"type" yaml:"type"`
+	// Selector is a LogQL stream selector on the labels of the entry, e.g. {container_name="etcd"}.
+	Selector string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	// Regex is matched against the line. Its named captures can be used as labels and value.
+	Regex string `json:"regex,omitempty" yaml:"regex,omitempty"`
+	// Action is applied to the metric on a matching line. It defaults to inc, set or observe.
+	Action string `json:"action,omitempty" yaml:"action,omitempty"`
+	// Value is the capture which holds the value of the add, sub, set and observe actions.
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	// Labels are the labels of the metric. Their values are taken from the captures or else from the stream labels.
+	Labels []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Buckets are the upper bounds of the histogram buckets.
+	Buckets []float64 `json:"buckets,omitempty" yaml:"buckets,omitempty"`
+}
+
+// LogMetricsConfig holds the configuration of the log metrics.
+type LogMetricsConfig struct {
+	// Metrics are the log metrics.
+	Metrics []LogMetric
+	// MaxSeries is the maximum number of label sets per metric. Further label sets are dropped.
+	MaxSeries int
+	// IdleTimeout is the time after which a label set without matching lines is removed.
+	IdleTimeout time.Duration
+}
+
+// Validate checks the metric and sets the default action.
+func (m *LogMetric) Validate() error {
+	if !model.IsValidMetricName(model.LabelValue(m.Name)) {
+		return fmt.Errorf("invalid metric name %q", m.Name)
+	}
+	actions, ok := logMetricActions[m.Type]
+	if !ok {
+		return fmt.Errorf("metric %s has unknown type %q", m.Name, m.Type)
+	}
+	if m.Action == "" {
+		m.Action = actions[0]
+	}
+	if !contains(actions, m.Action) {
+		return fmt.Errorf("metric %s of type %s has unknown action %q", m.Name, m.Type, m.Action)
+	}
+	if m.Selector != "" {
+		if _, err := logql.ParseMatchers(m.Selector); err != nil {
+			return fmt.Errorf("metric %s has invalid selector: %v", m.Name, err)
+		}
+	}
+
+	regex, err := regexp.Compile(m.Regex)
+	if err != nil {
+		return fmt.Errorf("metric %s has invalid regex: %v", m.Name, err)
+	}
+	needsValue := m.Action != LogMetricInc && m.Action != LogMetricDec
+	switch {
+	case needsValue && m.Value == "":
+		return fmt.Errorf("metric %s needs a value for the %s action", m.Name, m.Action)
+	case needsValue && regex.SubexpIndex(m.Value) < 0:
+		return fmt.Errorf("metric %s has no capture %s for the value", m.Name, m.Value)
+	case !needsValue && m.Value != "":
+		return fmt.Errorf("metric %s has a value, which is not used by the %s action", m.Name, m.Action)
+	}
+
+	for _, l := range m.Labels {
+		if !model.LabelName(l).IsValid() {
+			return fmt.Errorf("metric %s has invalid label %q", m.Name, l)
+		}
+	}
+	if m.Type != LogMetricHistogram && len(m.Buckets) > 0 {
+		return fmt.Errorf("metric %s has buckets, which are only used by histograms", m.Name)
+	}
+	if !sort.Float64sAreSorted(m.Buckets) {
+		return fmt.Errorf("metric %s has buckets which are not sorted", m.Name)
+	}
+	return nil
+}
+
+// initLogMetricsConfig parses the LogMetrics keys. The log metrics are enabled by LogMetrics.
+func initLogMetricsConfig(cfg Getter, res *Config) error {
+	logMetrics := cfg.Get("LogMetrics")
+	if logMetrics == "" {
+		return nil
+	}
+
+	conf := &LogMetricsConfig{
+		MaxSeries:   DefaultLogMetricsMaxSeries,
+		IdleTimeout: DefaultLogMetricsIdleTimeout,
+	}
+	var err error
+	if conf.Metrics, err = parseLogMetrics(logMetrics); err != nil {
+		return err
+	}
+
+	if maxSeries := cfg.Get("LogMetricsMaxSeries"); maxSeries != "" {
+		if conf.MaxSeries, err = strconv.Atoi(maxSeries); err != nil || conf.MaxSeries <= 0 {
+			return fmt.Errorf("invalid LogMetricsMaxSeries: %s, expected a positive number", maxSeries)
+		}
+	}
+	if idleTimeout := cfg.Get("LogMetricsIdleTimeout"); idleTimeout != "" {
+		if conf.IdleTimeout, err = time.ParseDuration(idleTimeout); err != nil {
+			return fmt.Errorf("failed to parse LogMetricsIdleTimeout: %s : %v", idleTimeout, err)
+		}
+		if conf.IdleTimeout <= 0 {
+			return fmt.Errorf("LogMetricsIdleTimeout has to be positive: %s", idleTimeout)
+		}
+	}
+
+	res.PluginConfig.LogMetrics = conf
+	return nil
+}
+
+// parseLogMetrics parses the metrics either from the file at logMetrics or from the inline json.
+func parseLogMetrics(logMetrics string) ([]LogMetric, error) {
+	content, err := readFileOrInline("LogMetrics", logMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []LogMetric
+	if err := json.Unmarshal(content, &res); err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal LogMetrics json: %s", err)
+	}
+	names := make(map[string]bool, len(res))
+	for i := range res {
+		if err := res[i].Validate(); err != nil {
+			return nil, fmt.Errorf("invalid LogMetrics: %v", err)
+		}
+		if names[res[i].Name] {
+			return nil, fmt.Errorf("invalid LogMetrics: duplicated metric %s", res[i].Name)
+		}
+		names[res[i].Name] = true
+	}
+	return res, nil
+}
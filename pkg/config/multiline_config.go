@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Multiline presets
+const (
+	MultilinePresetGo     = "go"
+	MultilinePresetJava   = "java"
+	MultilinePresetPython = "python"
+)
+
+// Multiline defaults
+const (
+	DefaultMultilineKey          = "log"
+	DefaultMultilineFlushTimeout = 2 * time.Second
+	DefaultMultilineMaxLines     = 500
+	DefaultMultilineMaxBytes     = 256 * 1024
+)
+
+// MultilinePattern decides which lines are joined into one entry.
+// A line matching Start begins a new entry. A line matching Continuation is appended to the pending entry.
+// Without Continuation every line which does not match Start is a continuation.
+type MultilinePattern struct {
+	Start        string `json:"start,omitempty" yaml:"start,omitempty"`
+	Continuation string `json:"continuation,omitempty" yaml:"continuation,omitempty"`
+}
+
+// MultilinePresets are the built-in patterns for stack traces.
+var MultilinePresets = map[string]MultilinePattern{
+	MultilinePresetGo: {
+		Start:        `^(panic: |fatal error: )`,
+		Continuation: `^(\s|$|goroutine \d+ \[|\[signal |created by |exit status |[\w./*()\[\]{}-]+\(.*\)$)`,
+	},
+	MultilinePresetJava: {
+		Start:        `^(Exception in thread "|([\w$]+\.)+[\w$]*(Exception|Error|Throwable)\b)`,
+		Continuation: `^(\s+at |\s+\.\.\. \d+ (more|common frames omitted)|\s*(Caused by|Suppressed): )`,
+	},
+	MultilinePresetPython: {
+		Start:        `^Traceback \(most recent call last\):`,
+		Continuation: `^(\s|$|[A-Za-z_][\w.]*(Error|Exception|Exit|Interrupt|Warning)\b|During handling of the above exception|The above exception was the direct cause)`,
+	},
+}
+
+// MultilineConfig holds the configuration of the multiline reassembly.
+type MultilineConfig struct {
+	// Preset is the name of a built-in pattern, overridden by the StartPattern and ContinuationPattern.
+	Preset string
+	// Pattern is the effective pattern.
+	Pattern MultilinePattern
+	// Key is the record key holding the log line.
+	Key string
+	// FlushTimeout is the time after the last line until a pending entry is sent.
+	FlushTimeout time.Duration
+	// MaxLines is the maximum number of lines joined into one entry.
+	MaxLines int
+	// MaxBytes is the maximum size of the lines joined into one entry.
+	MaxBytes int
+}
+
+// Validate checks that the patterns compile.
+func (p MultilinePattern) Validate() error {
+	if p.Start == "" && p.Continuation == "" {
+		return fmt.Errorf("multiline needs a start or a continuation pattern")
+	}
+	for _, expression := range []string{p.Start, p.Continuation} {
+		if _, err := regexp.Compile(expression); err != nil {
+			return fmt.Errorf("invalid multiline pattern %q: %v", expression, err)
+		}
+	}
+	return nil
+}
+
+// initMultilineConfig parses the Multiline keys. The multiline reassembly is enabled
+// by MultilinePreset, MultilineStartPattern or MultilineContinuationPattern.
+func initMultilineConfig(cfg Getter, res *Config) error {
+	var err error
+	preset := cfg.Get("MultilinePreset")
+	start := cfg.Get("MultilineStartPattern")
+	continuation := cfg.Get("MultilineContinuationPattern")
+	if preset == "" && start == "" && continuation == "" {
+		return nil
+	}
+
+	conf := &MultilineConfig{
+		Preset:       preset,
+		Key:          DefaultMultilineKey,
+		FlushTimeout: DefaultMultilineFlushTimeout,
+		MaxLines:     DefaultMultilineMaxLines,
+		MaxBytes:     DefaultMultilineMaxBytes,
+	}
+	if preset != "" {
+		var ok bool
+		if conf.Pattern, ok = MultilinePresets[preset]; !ok {
+			return fmt.Errorf("unknown MultilinePreset: %s", preset)
+		}
+	}
+	if start != "" {
+		conf.Pattern.Start = start
+	}
+	if continuation != "" {
+		conf.Pattern.Continuation = continuation
+	}
+	if err = conf.Pattern.Validate(); err != nil {
+		return err
+	}
+
+	if key := cfg.Get("MultilineKey"); key != "" {
+		conf.Key = key
+	}
+
+	flushTimeout := cfg.Get("MultilineFlushTimeout")
+	if flushTimeout != "" {
+		conf.FlushTimeout, err = time.ParseDuration(flushTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to parse MultilineFlushTimeout: %s : %v", flushTimeout, err)
+		}
+		if conf.FlushTimeout <= 0 {
+			return fmt.Errorf("MultilineFlushTimeout must be positive: %s", flushTimeout)
+		}
+	}
+
+	maxLines := cfg.Get("MultilineMaxLines")
+	if maxLines != "" {
+		if conf.MaxLines, err = strconv.Atoi(maxLines); err != nil || conf.MaxLines <= 0 {
+			return fmt.Errorf("invalid MultilineMaxLines: %s", maxLines)
+		}
+	}
+
+	maxBytes := cfg.Get("MultilineMaxBytes")
+	if maxBytes != "" {
+		if conf.MaxBytes, err = strconv.Atoi(maxBytes); err != nil || conf.MaxBytes <= 0 {
+			return fmt.Errorf("invalid MultilineMaxBytes: %s", maxBytes)
+		}
+	}
+
+	res.PluginConfig.Multiline = conf
+	return nil
+}
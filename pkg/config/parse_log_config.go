@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Formats of the embedded log payload
+const (
+	ParseLogJSON   = "json"
+	ParseLogLogfmt = "logfmt"
+	ParseLogAuto   = "auto"
+)
+
+// DefaultParseLogKey is the record key holding the embedded payload.
+const DefaultParseLogKey = "log"
+
+// ParseLogConfig holds the configuration for parsing the embedded json or logfmt payload of the log field.
+type ParseLogConfig struct {
+	// Format is json, logfmt or auto, which detects the format per line.
+	Format string
+	// Key is the record key holding the payload.
+	Key string
+	// Prefix is prepended to the parsed keys before they are merged into the record.
+	Prefix string
+	// KeepOriginal keeps the Key in the record after it was parsed.
+	KeepOriginal bool
+}
+
+// initParseLogConfig parses the ParseLog keys. The parsing is enabled by ParseLog.
+func initParseLogConfig(cfg Getter, res *Config) error {
+	format := cfg.Get("ParseLog")
+	if format == "" {
+		return nil
+	}
+	switch format {
+	case ParseLogJSON, ParseLogLogfmt, ParseLogAuto:
+	default:
+		return fmt.Errorf("invalid ParseLog: %s, expected one of %s, %s or %s", format, ParseLogJSON, ParseLogLogfmt, ParseLogAuto)
+	}
+
+	conf := &ParseLogConfig{
+		Format: format,
+		Key:    DefaultParseLogKey,
+		Prefix: cfg.Get("ParseLogPrefix"),
+	}
+	if key := cfg.Get("ParseLogKey"); key != "" {
+		conf.Key = key
+	}
+	keepOriginal := cfg.Get("ParseLogKeepOriginal")
+	if keepOriginal != "" {
+		var err error
+		if conf.KeepOriginal, err = strconv.ParseBool(keepOriginal); err != nil {
+			return fmt.Errorf("invalid boolean ParseLogKeepOriginal: %v", keepOriginal)
+		}
+	}
+
+	res.PluginConfig.ParseLog = conf
+	return nil
+}
@@ -9,10 +9,9 @@ package config
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -29,20 +28,39 @@ type PluginConfig struct {
 	LabelKeys []string
 	// LineFormat is the format to use when flattening the record to a log line.
 	LineFormat Format
+	// LineTemplate is the Go template which renders the record when the LineFormat is template.
+	LineTemplate string
 	// DropSingleKey if set to true and after extracting label_keys a record only
 	// has a single key remaining, the log line sent to Vali will just be
 	// the value of the record key.
 	DropSingleKey bool
 	// LabelMap is path to a json file defining how to transform nested records.
 	LabelMap map[string]interface{}
-	// DynamicHostPath is jsonpath in the log labels to the dynamic host.
-	DynamicHostPath map[string]interface{}
+	// LabelMapPath is the path of the LabelMap file. It is empty when the LabelMap is set inline.
+	LabelMapPath string
+	// DynamicHostPath are the record paths to the dynamic host, nested keys are separated by dots.
+	// The first path which is present in the record is used.
+	DynamicHostPath []string
 	// DynamicHostRegex is regex to check if the dynamic host is valid.
 	DynamicHostRegex string
 	// KubernetesMetadata holds the configurations for retrieving the meta data from a tag.
 	KubernetesMetadata KubernetesMetadataExtraction
 	//DynamicTenant holds the configurations for retrieving the tenant from a record key.
 	DynamicTenant DynamicTenant
+	// TagRouting are the routes of the records by their fluent-bit tag. The first matching route applies.
+	TagRouting []TagRoute
+	// InjectTag adds the fluent-bit tag under the TagKey to the records without it.
+	InjectTag bool
+	// LogMetrics turns matching log lines into Prometheus metrics. Nil disables them.
+	LogMetrics *LogMetricsConfig
+	// TopTalkers accounts the throughput by namespace, pod and container. Nil disables it.
+	TopTalkers *TopTalkersConfig
+	// WorkerPool processes the records of a chunk in parallel. Nil processes them on the flush thread.
+	WorkerPool *WorkerPoolConfig
+	// Conversion configures the conversion of the msgpack values. Nil keeps the default conversion.
+	Conversion *ConversionConfig
+	// StructuredMetadata configures the attributes which are sent with the entries without being indexed.
+	StructuredMetadata *StructuredMetadataConfig
 	//LabelSetInitCapacity the initial capacity of the labelset stream.
 	LabelSetInitCapacity int
 	//HostnameKey is the key name of the hostname key/value pair.
@@ -53,6 +71,32 @@ type PluginConfig struct {
 	PreservedLabels model.LabelSet
 	//EnableMultiTenancy switch on and off the parsing of __gardener_multitenancy_id__ label
 	EnableMultiTenancy bool
+	// HotReload enables watching the LabelMapPath and ReloadConfigPath files for changes.
+	HotReload bool
+	// ReloadConfigPath is the path to a file holding reloadable configuration keys.
+	ReloadConfigPath string
+	// Processors is the ordered chain of stages applied to every record before the labels are extracted.
+	Processors []ProcessorConfig
+	// Redaction holds the rules which scrub secrets from the records and labels before they are sent.
+	Redaction *RedactionConfig
+	// Multiline holds the configuration for joining stack traces into one entry. It is nil when disabled.
+	Multiline *MultilineConfig
+	// Severity holds the configuration of the level detection. It is nil when disabled.
+	Severity *SeverityConfig
+	// ParseLog holds the configuration for parsing the embedded payload of the log field. It is nil when disabled.
+	ParseLog *ParseLogConfig
+	// Timestamp holds the configuration for taking the entry timestamp from a record key. It is nil when disabled.
+	Timestamp *TimestampConfig
+	// PodMetadata holds the configuration for enriching records from the pods of the node. It is nil when disabled.
+	PodMetadata *PodMetadataConfig
+	// PodAnnotations holds the pod annotations which configure the log shipping of a workload. It is nil when disabled.
+	PodAnnotations *PodAnnotationsConfig
+	// DropIf are the rules whose matching entries are dropped.
+	DropIf FilterRules
+	// KeepIf are the rules of which an entry has to match at least one to be sent.
+	KeepIf FilterRules
+	// RelabelConfigs are applied to the label set of each entry right before it is sent.
+	RelabelConfigs RelabelConfigs
 }
 
 // KubernetesMetadataExtraction holds the configurations for retrieving the meta data from a tag
@@ -66,9 +110,10 @@ type KubernetesMetadataExtraction struct {
 
 // DynamicTenant contains specs for the valiplugin dynamic functionality
 type DynamicTenant struct {
-	Tenant                                string
-	Field                                 string
-	Regex                                 string
+	// Rules are evaluated in order against the records of the dynamic hosts.
+	Rules []DynamicTenantRule
+	// Match is either DynamicTenantMatchFirst or DynamicTenantMatchAll.
+	Match                                 string
 	RemoveTenantIdWhenSendingToDefaultURL bool
 }
 
@@ -108,31 +153,34 @@ func initPluginConfig(cfg Getter, res *Config) error {
 		res.PluginConfig.LineFormat = JSONFormat
 	case "key_value":
 		res.PluginConfig.LineFormat = KvPairFormat
+	case "template":
+		res.PluginConfig.LineFormat = TemplateFormat
 	default:
 		return fmt.Errorf("invalid format: %s", lineFormat)
 	}
 
+	res.PluginConfig.LineTemplate = cfg.Get("LineTemplate")
+	if res.PluginConfig.LineFormat == TemplateFormat {
+		if res.PluginConfig.LineTemplate == "" {
+			return fmt.Errorf("LineTemplate is required for the template LineFormat")
+		}
+		if _, err := ParseTemplate("LineTemplate", res.PluginConfig.LineTemplate); err != nil {
+			return fmt.Errorf("failed to parse LineTemplate: %v", err)
+		}
+	}
+
 	labelMapPath := cfg.Get("LabelMapPath")
 	if labelMapPath != "" {
-		var content []byte
-		if _, err := os.Stat(labelMapPath); err == nil {
-			content, err = ioutil.ReadFile(labelMapPath)
-			if err != nil {
-				return fmt.Errorf("failed to open LabelMap file: %s", err)
-			}
-		} else if errors.Is(err, os.ErrNotExist) {
-			content = []byte(labelMapPath)
-		}
-		if err := json.Unmarshal(content, &res.PluginConfig.LabelMap); err != nil {
-			return fmt.Errorf("failed to Unmarshal LabelMap file: %s", err)
+		if res.PluginConfig.LabelMap, res.PluginConfig.LabelMapPath, err = parseLabelMap(labelMapPath); err != nil {
+			return err
 		}
 		res.PluginConfig.LabelKeys = nil
 	}
 
 	dynamicHostPath := cfg.Get("DynamicHostPath")
 	if dynamicHostPath != "" {
-		if err := json.Unmarshal([]byte(dynamicHostPath), &res.PluginConfig.DynamicHostPath); err != nil {
-			return fmt.Errorf("failed to Unmarshal DynamicHostPath json: %s", err)
+		if res.PluginConfig.DynamicHostPath, err = parseDynamicHostPath(dynamicHostPath); err != nil {
+			return err
 		}
 	}
 
@@ -178,27 +226,6 @@ func initPluginConfig(cfg Getter, res *Config) error {
 		}
 	}
 
-	dynamicTenant := cfg.Get("DynamicTenant")
-	dynamicTenant = strings.Trim(dynamicTenant, " ")
-	if dynamicTenant != "" {
-		dynamicTenantValues := strings.SplitN(dynamicTenant, " ", 3)
-		if len(dynamicTenantValues) != 3 {
-			return fmt.Errorf("failed to parse DynamicTenant. Should consist of <tenant-name>\" \"<field-for-regex>\" \"<regex>. Found %d elements", len(dynamicTenantValues))
-		}
-		res.PluginConfig.DynamicTenant.Tenant = dynamicTenantValues[0]
-		res.PluginConfig.DynamicTenant.Field = dynamicTenantValues[1]
-		res.PluginConfig.DynamicTenant.Regex = dynamicTenantValues[2]
-		removeTenantIdWhenSendingToDefaultURL := cfg.Get("RemoveTenantIdWhenSendingToDefaultURL")
-		if removeTenantIdWhenSendingToDefaultURL != "" {
-			res.PluginConfig.DynamicTenant.RemoveTenantIdWhenSendingToDefaultURL, err = strconv.ParseBool(removeTenantIdWhenSendingToDefaultURL)
-			if err != nil {
-				return fmt.Errorf("invalid value for RemoveTenantIdWhenSendingToDefaultURL, error: %v", err)
-			}
-		} else {
-			res.PluginConfig.DynamicTenant.RemoveTenantIdWhenSendingToDefaultURL = true
-		}
-	}
-
 	labelSetInitCapacity := cfg.Get("LabelSetInitCapacity")
 	if labelSetInitCapacity != "" {
 		labelSetInitCapacityValue, err := strconv.Atoi(labelSetInitCapacity)
@@ -244,5 +271,162 @@ func initPluginConfig(cfg Getter, res *Config) error {
 		}
 	}
 
-	return nil
+	hotReload := cfg.Get("HotReload")
+	if hotReload != "" {
+		res.PluginConfig.HotReload, err = strconv.ParseBool(hotReload)
+		if err != nil {
+			return fmt.Errorf("invalid boolean HotReload: %v", hotReload)
+		}
+	}
+
+	res.PluginConfig.ReloadConfigPath = cfg.Get("ReloadConfigPath")
+	if res.PluginConfig.ReloadConfigPath != "" {
+		if _, err := os.Stat(res.PluginConfig.ReloadConfigPath); err != nil {
+			return fmt.Errorf("failed to open ReloadConfigPath file: %s", err)
+		}
+	}
+
+	processors := cfg.Get("Processors")
+	if processors != "" {
+		if res.PluginConfig.Processors, err = parseProcessors(processors); err != nil {
+			return err
+		}
+	}
+
+	redaction := cfg.Get("Redaction")
+	if redaction != "" {
+		if res.PluginConfig.Redaction, err = parseRedaction(redaction); err != nil {
+			return err
+		}
+	}
+
+	if err = initMultilineConfig(cfg, res); err != nil {
+		return err
+	}
+
+	relabelConfigs := cfg.Get("RelabelConfigs")
+	if relabelConfigs != "" {
+		if res.PluginConfig.RelabelConfigs, err = parseRelabelConfigs("RelabelConfigs", relabelConfigs); err != nil {
+			return err
+		}
+	}
+
+	dropIf := cfg.Get("DropIf")
+	if dropIf != "" {
+		if res.PluginConfig.DropIf, err = parseFilterRules("DropIf", dropIf); err != nil {
+			return err
+		}
+	}
+
+	keepIf := cfg.Get("KeepIf")
+	if keepIf != "" {
+		if res.PluginConfig.KeepIf, err = parseFilterRules("KeepIf", keepIf); err != nil {
+			return err
+		}
+	}
+
+	if err = initSeverityConfig(cfg, res); err != nil {
+		return err
+	}
+
+	if err = initParseLogConfig(cfg, res); err != nil {
+		return err
+	}
+
+	if err = initTimestampConfig(cfg, res); err != nil {
+		return err
+	}
+
+	if err = initPodMetadataConfig(cfg, res); err != nil {
+		return err
+	}
+
+	if err = initPodAnnotationsConfig(cfg, res); err != nil {
+		return err
+	}
+	if err = initDynamicTenantConfig(cfg, res); err != nil {
+		return err
+	}
+	if err = initTagRoutingConfig(cfg, res); err != nil {
+		return err
+	}
+	if err = initLogMetricsConfig(cfg, res); err != nil {
+		return err
+	}
+	if err = initTopTalkersConfig(cfg, res); err != nil {
+		return err
+	}
+	if err = initWorkerPoolConfig(cfg, res); err != nil {
+		return err
+	}
+	if err = initConversionConfig(cfg, res); err != nil {
+		return err
+	}
+	return initStructuredMetadataConfig(cfg, res)
+}
+
+// parseLabelMap parses the LabelMap either from the file at labelMapPath or from the
+// inline json. The returned path is empty when the LabelMap is inline.
+func parseLabelMap(labelMapPath string) (map[string]interface{}, string, error) {
+	var filePath string
+	if isFile(labelMapPath) {
+		filePath = labelMapPath
+	}
+	content, err := readFileOrInline("LabelMap", labelMapPath)
+	if err != nil {
+		return nil, "", err
+	}
+	var labelMap map[string]interface{}
+	if err := json.Unmarshal(content, &labelMap); err != nil {
+		return nil, "", fmt.Errorf("failed to Unmarshal LabelMap file: %s", err)
+	}
+	return labelMap, filePath, nil
+}
+
+// parseDynamicHostPath parses either a json list of record paths or a single json object,
+// like {"kubernetes": {"namespace_name": "namespace"}}, whose leafs are the paths.
+func parseDynamicHostPath(dynamicHostPath string) ([]string, error) {
+	var paths []string
+	if err := json.Unmarshal([]byte(dynamicHostPath), &paths); err == nil {
+		for _, p := range paths {
+			if p == "" {
+				return nil, fmt.Errorf("empty path in DynamicHostPath")
+			}
+		}
+		return paths, nil
+	}
+
+	var mapping map[string]interface{}
+	if err := json.Unmarshal([]byte(dynamicHostPath), &mapping); err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal DynamicHostPath json: %s", err)
+	}
+	return flattenDynamicHostPath("", mapping)
+}
+
+// flattenDynamicHostPath returns the paths of the mapping. The keys of a level are sorted,
+// so the paths are tried in a stable order.
+func flattenDynamicHostPath(prefix string, mapping map[string]interface{}) ([]string, error) {
+	keys := make([]string, 0, len(mapping))
+	for k := range mapping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var res []string
+	for _, k := range keys {
+		path := prefix + k
+		switch next := mapping[k].(type) {
+		case map[string]interface{}:
+			paths, err := flattenDynamicHostPath(path+".", next)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, paths...)
+		case string:
+			res = append(res, path)
+		default:
+			return nil, fmt.Errorf("invalid DynamicHostPath value for %s: %v", path, next)
+		}
+	}
+	return res, nil
 }
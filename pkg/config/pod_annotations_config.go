@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// PodAnnotationPrefix is the prefix of the pod annotations which configure the log shipping of a workload.
+const PodAnnotationPrefix = "logging.gardener.cloud/"
+
+// Pod annotations, without the PodAnnotationPrefix, which can be allowed in PodAnnotations
+const (
+	PodAnnotationDrop            = "drop"
+	PodAnnotationExtraLabels     = "extra-labels"
+	PodAnnotationMultilinePreset = "multiline-preset"
+	PodAnnotationSampleRate      = "sample-rate"
+)
+
+// PodAnnotationNames are the supported pod annotations.
+var PodAnnotationNames = []string{PodAnnotationDrop, PodAnnotationExtraLabels, PodAnnotationMultilinePreset, PodAnnotationSampleRate}
+
+// PodAnnotationsConfig holds the pod annotations which are respected and the namespaces which may use them.
+type PodAnnotationsConfig struct {
+	// Annotations are the allowed annotations without the PodAnnotationPrefix.
+	Annotations []string
+	// Namespaces are the namespaces whose pods may use the annotations. AllNamespaces allows all.
+	Namespaces []string
+}
+
+// Allows reports if the annotation is respected. It is safe to call on a nil config.
+func (c *PodAnnotationsConfig) Allows(annotation string) bool {
+	return c != nil && contains(c.Annotations, annotation)
+}
+
+// AllowsNamespace reports if the pods of the namespace may use the annotations.
+func (c *PodAnnotationsConfig) AllowsNamespace(namespace string) bool {
+	return contains(c.Namespaces, namespace) || contains(c.Namespaces, AllNamespaces)
+}
+
+// initPodAnnotationsConfig parses the PodAnnotations keys. The annotations are enabled by PodAnnotations.
+func initPodAnnotationsConfig(cfg Getter, res *Config) error {
+	annotations := cfg.Get("PodAnnotations")
+	if annotations == "" {
+		return nil
+	}
+
+	conf := &PodAnnotationsConfig{}
+	for _, a := range strings.Split(annotations, ",") {
+		a = strings.TrimPrefix(strings.TrimSpace(a), PodAnnotationPrefix)
+		if !contains(PodAnnotationNames, a) {
+			return fmt.Errorf("invalid PodAnnotations: unknown annotation %s, expected %s", a, strings.Join(PodAnnotationNames, ", "))
+		}
+		conf.Annotations = append(conf.Annotations, a)
+	}
+
+	namespaces := cfg.Get("PodAnnotationNamespaces")
+	if namespaces == "" {
+		return fmt.Errorf("PodAnnotationNamespaces is required when PodAnnotations is set")
+	}
+	for _, n := range strings.Split(namespaces, ",") {
+		conf.Namespaces = append(conf.Namespaces, strings.TrimSpace(n))
+	}
+
+	// The annotations are taken from the pod informer as well, when it is enabled.
+	if res.PluginConfig.PodMetadata != nil {
+		for _, a := range conf.Annotations {
+			if !contains(res.PluginConfig.PodMetadata.Annotations, PodAnnotationPrefix+a) {
+				res.PluginConfig.PodMetadata.Annotations = append(res.PluginConfig.PodMetadata.Annotations, PodAnnotationPrefix+a)
+			}
+		}
+	}
+
+	res.PluginConfig.PodAnnotations = conf
+	return nil
+}
+
+// ParseExtraLabels parses the labels of an extra-labels annotation, e.g. "team=logging,tier=backend".
+// The reserved labels, whose names start with "__", are rejected, because they select the tenant and the client of the logs.
+func ParseExtraLabels(value string) (model.LabelSet, error) {
+	labels := model.LabelSet{}
+	for _, pair := range strings.Split(value, ",") {
+		name, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+		ln, lv := model.LabelName(name), model.LabelValue(val)
+		if !found || !ln.IsValid() || !lv.IsValid() {
+			return nil, fmt.Errorf("invalid label %q", pair)
+		}
+		if strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return nil, fmt.Errorf("reserved label %q", name)
+		}
+		labels[ln] = lv
+	}
+	return labels, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
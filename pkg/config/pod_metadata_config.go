@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of the pod metadata cache
+const (
+	DefaultEnrichCacheTTL  = 5 * time.Minute
+	DefaultEnrichCacheSize = 1000
+)
+
+// PodMetadataConfig holds the configuration for enriching records from the pods of the node.
+type PodMetadataConfig struct {
+	// NodeName scopes the pod informer to the pods of this node.
+	NodeName string
+	// Annotations are the pod annotations copied into the record.
+	Annotations []string
+	// CacheTTL is the time the metadata of a pod is reused before it is looked up again.
+	CacheTTL time.Duration
+	// CacheSize is the maximum number of pods in the cache.
+	CacheSize int
+}
+
+// initPodMetadataConfig parses the Enrich keys. The enrichment is enabled by EnrichKubernetesMetadata.
+func initPodMetadataConfig(cfg Getter, res *Config) error {
+	enrich := cfg.Get("EnrichKubernetesMetadata")
+	if enrich == "" {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(enrich)
+	if err != nil {
+		return fmt.Errorf("invalid boolean EnrichKubernetesMetadata: %v", enrich)
+	}
+	if !enabled {
+		return nil
+	}
+
+	conf := &PodMetadataConfig{
+		NodeName:  cfg.Get("EnrichNodeName"),
+		CacheTTL:  DefaultEnrichCacheTTL,
+		CacheSize: DefaultEnrichCacheSize,
+	}
+	if conf.NodeName == "" {
+		return fmt.Errorf("EnrichNodeName is required when EnrichKubernetesMetadata is enabled")
+	}
+	if annotations := cfg.Get("EnrichAnnotations"); annotations != "" {
+		conf.Annotations = strings.Split(annotations, ",")
+	}
+
+	cacheTTL := cfg.Get("EnrichCacheTTL")
+	if cacheTTL != "" {
+		if conf.CacheTTL, err = time.ParseDuration(cacheTTL); err != nil {
+			return fmt.Errorf("failed to parse EnrichCacheTTL: %s : %v", cacheTTL, err)
+		}
+		if conf.CacheTTL <= 0 {
+			return fmt.Errorf("EnrichCacheTTL has to be positive: %s", cacheTTL)
+		}
+	}
+
+	cacheSize := cfg.Get("EnrichCacheSize")
+	if cacheSize != "" {
+		if conf.CacheSize, err = strconv.Atoi(cacheSize); err != nil {
+			return fmt.Errorf("failed to parse EnrichCacheSize: %s", cacheSize)
+		}
+		if conf.CacheSize <= 0 {
+			return fmt.Errorf("EnrichCacheSize has to be positive: %s", cacheSize)
+		}
+	}
+
+	res.PluginConfig.PodMetadata = conf
+	return nil
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/prometheus/common/model"
+)
+
+// Processor types which can be used in the Processors chain
+const (
+	ProcessorRename       = "rename"
+	ProcessorCopy         = "copy"
+	ProcessorDropField    = "drop-field"
+	ProcessorSetLabel     = "set-label"
+	ProcessorTemplate     = "template"
+	ProcessorParseJSON    = "parse-json"
+	ProcessorRegexExtract = "regex-extract"
+)
+
+// ProcessorConfig configures one stage of the record processor chain.
+// Fields are addressed with dot separated paths into the nested record, e.g. "kubernetes.pod_name".
+type ProcessorConfig struct {
+	// Type is one of the Processor* constants.
+	Type string `json:"type" yaml:"type"`
+	// Field is the source field of the stage.
+	Field string `json:"field,omitempty" yaml:"field,omitempty"`
+	// Target is the field written by the stage.
+	Target string `json:"target,omitempty" yaml:"target,omitempty"`
+	// Fields are the fields removed by the drop-field stage.
+	Fields []string `json:"fields,omitempty" yaml:"fields,omitempty"`
+	// Label is the label name set by the set-label stage.
+	Label string `json:"label,omitempty" yaml:"label,omitempty"`
+	// Value is the static label value of the set-label stage.
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	// Template is the Go template rendered by the template stage.
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+	// Regex is the expression with named groups used by the regex-extract stage.
+	Regex string `json:"regex,omitempty" yaml:"regex,omitempty"`
+	// RemoveSource removes Field after the parse-json and regex-extract stages succeeded.
+	RemoveSource bool `json:"removeSource,omitempty" yaml:"removeSource,omitempty"`
+}
+
+// Validate checks that the stage has the settings its type requires.
+func (p ProcessorConfig) Validate() error {
+	switch p.Type {
+	case ProcessorRename, ProcessorCopy:
+		if p.Field == "" || p.Target == "" {
+			return fmt.Errorf("%s processor needs field and target", p.Type)
+		}
+	case ProcessorDropField:
+		if p.Field == "" && len(p.Fields) == 0 {
+			return fmt.Errorf("%s processor needs field or fields", p.Type)
+		}
+	case ProcessorSetLabel:
+		if !model.LabelName(p.Label).IsValid() {
+			return fmt.Errorf("%s processor has invalid label name %q", p.Type, p.Label)
+		}
+		if (p.Field == "") == (p.Value == "") {
+			return fmt.Errorf("%s processor needs either field or value", p.Type)
+		}
+	case ProcessorTemplate:
+		if p.Target == "" {
+			return fmt.Errorf("%s processor needs target", p.Type)
+		}
+		if _, err := ParseTemplate(p.Type, p.Template); err != nil {
+			return fmt.Errorf("%s processor has invalid template: %v", p.Type, err)
+		}
+	case ProcessorParseJSON:
+		if p.Field == "" {
+			return fmt.Errorf("%s processor needs field", p.Type)
+		}
+	case ProcessorRegexExtract:
+		if p.Field == "" {
+			return fmt.Errorf("%s processor needs field", p.Type)
+		}
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return fmt.Errorf("%s processor has invalid regex: %v", p.Type, err)
+		}
+		if re.NumSubexp() == 0 {
+			return fmt.Errorf("%s processor regex %q has no named groups", p.Type, p.Regex)
+		}
+		for _, name := range re.SubexpNames()[1:] {
+			if name == "" {
+				return fmt.Errorf("%s processor regex %q has unnamed groups", p.Type, p.Regex)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown processor type %q", p.Type)
+	}
+	return nil
+}
+
+// parseProcessors parses the Processors chain either from the file at processors or from the inline json.
+func parseProcessors(processors string) ([]ProcessorConfig, error) {
+	content, err := readFileOrInline("Processors", processors)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []ProcessorConfig
+	if err := json.Unmarshal(content, &res); err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal Processors json: %s", err)
+	}
+	for i, p := range res {
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid processor %d: %v", i, err)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// Built-in redaction detectors
+const (
+	DetectorBearerToken  = "bearer-token"
+	DetectorJWT          = "jwt"
+	DetectorPrivateKey   = "private-key"
+	DetectorBasicAuthURL = "basic-auth-url"
+)
+
+// Redaction actions
+const (
+	RedactionMask = "mask"
+	RedactionHash = "hash"
+)
+
+// RedactionSecretGroup is the name of the regex group which is redacted instead of the whole match.
+const RedactionSecretGroup = "secret"
+
+// DefaultRedactionMask replaces the redacted text when a rule has no mask.
+const DefaultRedactionMask = "[REDACTED]"
+
+// Detectors are the expressions of the built-in redaction detectors.
+var Detectors = map[string]string{
+	DetectorBearerToken:  `(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]+=*)`,
+	DetectorJWT:          `\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`,
+	DetectorPrivateKey:   `(?s)-----BEGIN[A-Z ]*PRIVATE KEY-----.*?-----END[A-Z ]*PRIVATE KEY-----`,
+	DetectorBasicAuthURL: `\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@]+:(?P<secret>[^\s/@]+)@`,
+}
+
+// RedactionConfig holds the rules which scrub secrets from the records and labels.
+type RedactionConfig struct {
+	// Rules are applied to all records.
+	Rules []RedactionRule `json:"rules,omitempty" yaml:"rules,omitempty"`
+	// Namespaces holds additional rules for the records of a namespace.
+	Namespaces map[string][]RedactionRule `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+}
+
+// RedactionRule finds one kind of secret.
+// Exactly one of Detector, Regex and Literal has to be set.
+type RedactionRule struct {
+	// Name identifies the rule in the metrics. It defaults to the Detector.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Detector is the name of a built-in detector.
+	Detector string `json:"detector,omitempty" yaml:"detector,omitempty"`
+	// Regex is redacted as a whole, or only its "secret" group if present.
+	Regex string `json:"regex,omitempty" yaml:"regex,omitempty"`
+	// Literal is redacted wherever it occurs.
+	Literal string `json:"literal,omitempty" yaml:"literal,omitempty"`
+	// Action is either "mask" (default) or "hash".
+	Action string `json:"action,omitempty" yaml:"action,omitempty"`
+	// Mask replaces the secret for the mask action.
+	Mask string `json:"mask,omitempty" yaml:"mask,omitempty"`
+}
+
+// Expression returns the regex of the rule. It is empty for literal rules.
+func (r RedactionRule) Expression() string {
+	if r.Detector != "" {
+		return Detectors[r.Detector]
+	}
+	return r.Regex
+}
+
+// Validate checks that the rule has exactly one matcher and a known action.
+func (r RedactionRule) Validate() error {
+	set := 0
+	for _, s := range []string{r.Detector, r.Regex, r.Literal} {
+		if s != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("rule %q needs exactly one of detector, regex and literal", r.Name)
+	}
+	if r.Name == "" {
+		return fmt.Errorf("rule without detector needs a name")
+	}
+	if r.Detector != "" {
+		if _, ok := Detectors[r.Detector]; !ok {
+			return fmt.Errorf("rule %q has unknown detector %q", r.Name, r.Detector)
+		}
+	}
+	if r.Regex != "" {
+		if _, err := regexp.Compile(r.Regex); err != nil {
+			return fmt.Errorf("rule %q has invalid regex: %v", r.Name, err)
+		}
+	}
+	switch r.Action {
+	case "", RedactionMask, RedactionHash:
+	default:
+		return fmt.Errorf("rule %q has unknown action %q", r.Name, r.Action)
+	}
+	return nil
+}
+
+// parseRedaction parses the Redaction rules either from the file at redaction or from the inline json.
+func parseRedaction(redaction string) (*RedactionConfig, error) {
+	content, err := readFileOrInline("Redaction", redaction)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &RedactionConfig{}
+	if err := json.Unmarshal(content, res); err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal Redaction json: %s", err)
+	}
+
+	validate := func(rules []RedactionRule) error {
+		names := make(map[string]bool, len(rules))
+		for i := range rules {
+			if rules[i].Name == "" {
+				rules[i].Name = rules[i].Detector
+			}
+			if err := rules[i].Validate(); err != nil {
+				return fmt.Errorf("invalid Redaction: %v", err)
+			}
+			if names[rules[i].Name] {
+				return fmt.Errorf("invalid Redaction: duplicated rule name %q", rules[i].Name)
+			}
+			names[rules[i].Name] = true
+		}
+		return nil
+	}
+	if err := validate(res.Rules); err != nil {
+		return nil, err
+	}
+	for _, rules := range res.Namespaces {
+		if err := validate(rules); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
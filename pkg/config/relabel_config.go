@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"gopkg.in/yaml.v2"
+)
+
+// RelabelConfigs are prometheus relabel_configs rules applied in order to a label set.
+type RelabelConfigs []*relabel.Config
+
+// Process applies the rules to the label set. It returns nil if a rule drops the entry.
+func (r RelabelConfigs) Process(ls model.LabelSet) model.LabelSet {
+	if len(r) == 0 {
+		return ls
+	}
+	lbls := make(labels.Labels, 0, len(ls))
+	for name, value := range ls {
+		lbls = append(lbls, labels.Label{Name: string(name), Value: string(value)})
+	}
+	// relabel.Process expects the labels sorted by name
+	lbls = labels.New(lbls...)
+
+	lbls = relabel.Process(lbls, r...)
+	if lbls == nil {
+		return nil
+	}
+	res := make(model.LabelSet, len(lbls))
+	for _, l := range lbls {
+		res[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+	}
+	return res
+}
+
+// parseRelabelConfigs parses the rules either from the file at relabelConfigs or from the inline yaml or json.
+func parseRelabelConfigs(key, relabelConfigs string) (RelabelConfigs, error) {
+	content, err := readFileOrInline(key, relabelConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	var res RelabelConfigs
+	if err := yaml.UnmarshalStrict(content, &res); err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal %s: %s", key, err)
+	}
+	for i, c := range res {
+		if c == nil {
+			return nil, fmt.Errorf("empty rule %d in %s", i, key)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/model"
+)
+
+var _ = Describe("RelabelConfigs", func() {
+	ls := func() model.LabelSet {
+		return model.LabelSet{
+			"namespace":      "shoot--dev--test",
+			"pod_name":       "kube-apiserver-0",
+			"label_app":      "kubernetes",
+			"label_role":     "apiserver",
+			"__internal__":   "x",
+			"container_name": "kube-apiserver",
+		}
+	}
+
+	DescribeTable("#Process",
+		func(rules string, expected model.LabelSet) {
+			relabelConfigs, err := parseRelabelConfigs("RelabelConfigs", rules)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(relabelConfigs.Process(ls())).To(Equal(expected))
+		},
+		Entry("replace", `[{"source_labels": ["namespace", "pod_name"], "separator": "/", "target_label": "instance"}]`, model.LabelSet{
+			"namespace": "shoot--dev--test", "pod_name": "kube-apiserver-0", "label_app": "kubernetes", "label_role": "apiserver",
+			"__internal__": "x", "container_name": "kube-apiserver", "instance": "shoot--dev--test/kube-apiserver-0",
+		}),
+		Entry("replace with capture group", `
+- source_labels: [namespace]
+  regex: shoot--(.+)--(.+)
+  target_label: shoot
+  replacement: $1-$2
+- action: labelkeep
+  regex: shoot`, model.LabelSet{"shoot": "dev-test"}),
+		Entry("keep a matching entry", `[{"action": "keep", "source_labels": ["container_name"], "regex": "kube-.*"}]`, ls()),
+		Entry("keep drops other entries", `[{"action": "keep", "source_labels": ["container_name"], "regex": "etcd"}]`, nil),
+		Entry("drop", `[{"action": "drop", "source_labels": ["label_role"], "regex": "apiserver"}]`, nil),
+		Entry("labelmap", `
+- action: labelmap
+  regex: label_(.+)
+- action: labeldrop
+  regex: label_.+|__.+__`, model.LabelSet{
+			"namespace": "shoot--dev--test", "pod_name": "kube-apiserver-0", "container_name": "kube-apiserver",
+			"app": "kubernetes", "role": "apiserver",
+		}),
+		Entry("hashmod", `
+- action: hashmod
+  source_labels: [pod_name]
+  modulus: 4
+  target_label: shard
+- action: labelkeep
+  regex: shard`, model.LabelSet{"shard": "2"}),
+		Entry("no rules", `[]`, ls()),
+	)
+
+	DescribeTable("#parseRelabelConfigs errors",
+		func(rules string) {
+			_, err := parseRelabelConfigs("RelabelConfigs", rules)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("unknown action", `[{"action": "rename"}]`),
+		Entry("unknown field", `[{"source": ["a"]}]`),
+		Entry("replace without target", `[{"action": "replace", "source_labels": ["a"]}]`),
+		Entry("hashmod without modulus", `[{"action": "hashmod", "source_labels": ["a"], "target_label": "b"}]`),
+		Entry("bad regex", `[{"action": "drop", "regex": "(("}]`),
+		Entry("empty rule", `[null]`),
+	)
+})
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReloadableKeys are the configuration keys which can be changed without restarting fluent-bit.
+var ReloadableKeys = []string{"LabelMapPath", "DynamicHostPath", "RoutingPolicy"}
+
+// MapGetter is a Getter backed by a map.
+type MapGetter map[string]string
+
+// Get returns the value of the key or an empty string.
+func (m MapGetter) Get(key string) string {
+	return m[key]
+}
+
+// NewFileGetter reads a file with one "Key Value" pair per line as used in the
+// fluent-bit configuration. Empty lines and lines starting with '#' are skipped.
+func NewFileGetter(path string) (MapGetter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res := MapGetter{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		if key == "" {
+			return nil, fmt.Errorf("invalid line %d in %s: %q", lineNumber, path, line)
+		}
+		res[key] = strings.TrimSpace(value)
+	}
+	return res, scanner.Err()
+}
+
+// Reload returns a copy of base with the reloadable keys taken from cfg.
+// Keys missing in cfg keep their values, but the LabelMap file is always read again.
+// The base configuration is not modified.
+func Reload(base *Config, cfg MapGetter) (*Config, error) {
+	var err error
+	for key := range cfg {
+		if !isReloadableKey(key) {
+			return nil, fmt.Errorf("%s can't be changed without a restart", key)
+		}
+	}
+
+	res := *base
+
+	labelMapPath := cfg.Get("LabelMapPath")
+	if labelMapPath == "" {
+		labelMapPath = base.PluginConfig.LabelMapPath
+	}
+	if labelMapPath != "" {
+		if res.PluginConfig.LabelMap, res.PluginConfig.LabelMapPath, err = parseLabelMap(labelMapPath); err != nil {
+			return nil, err
+		}
+		res.PluginConfig.LabelKeys = nil
+	}
+
+	dynamicHostPath := cfg.Get("DynamicHostPath")
+	if dynamicHostPath != "" {
+		if len(base.PluginConfig.DynamicHostPath) == 0 {
+			return nil, fmt.Errorf("DynamicHostPath can't be enabled without a restart")
+		}
+		if res.PluginConfig.DynamicHostPath, err = parseDynamicHostPath(dynamicHostPath); err != nil {
+			return nil, err
+		}
+	}
+
+	routingPolicy := cfg.Get("RoutingPolicy")
+	if routingPolicy != "" {
+		if res.ControllerConfig.RoutingPolicy, err = newRoutingPolicy(base.ControllerConfig, routingPolicy); err != nil {
+			return nil, err
+		}
+	}
+
+	return &res, nil
+}
+
+func isReloadableKey(key string) bool {
+	for _, k := range ReloadableKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("Reload", func() {
+	var (
+		dir  string
+		base *Config
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "reload")
+		Expect(err).ToNot(HaveOccurred())
+		base, err = ParseConfig(MapGetter{
+			"LabelKeys":        "app",
+			"DynamicHostPath":  `{"kubernetes": {"namespace_name": "namespace"}}`,
+			"DynamicHostRegex": "^shoot-",
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("Should read the reloadable keys from a file", func() {
+		file := filepath.Join(dir, "reload.conf")
+		Expect(os.WriteFile(file, []byte(`
+# reloadable keys
+LabelMapPath    {"app": "application"}
+RoutingPolicy   {"ready": {"targets": ["default"]}}
+`), 0644)).To(Succeed())
+
+		getter, err := NewFileGetter(file)
+		Expect(err).ToNot(HaveOccurred())
+
+		cfg, err := Reload(base, getter)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.PluginConfig.LabelMap).To(Equal(map[string]interface{}{"app": "application"}))
+		Expect(cfg.PluginConfig.LabelKeys).To(BeNil())
+		Expect(cfg.ControllerConfig.RoutingPolicy[ClusterStateReady].Targets).To(Equal([]string{DefaultRoutingTarget}))
+		Expect(cfg.ControllerConfig.RoutingPolicy[ClusterStateCreation]).To(Equal(base.ControllerConfig.RoutingPolicy[ClusterStateCreation]))
+
+		By("not modifying the base configuration")
+		Expect(base.PluginConfig.LabelKeys).To(Equal([]string{"app"}))
+		Expect(base.ControllerConfig.RoutingPolicy[ClusterStateReady].Targets).To(Equal([]string{MainRoutingTarget}))
+	})
+
+	It("Should reject keys which require a restart", func() {
+		_, err := Reload(base, MapGetter{"URL": "http://localhost"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should reject an invalid routing policy", func() {
+		_, err := Reload(base, MapGetter{"RoutingPolicy": `{"ready": {"targets": ["unknown"]}}`})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should not enable the dynamic host path", func() {
+		base.PluginConfig.DynamicHostPath = nil
+		_, err := Reload(base, MapGetter{"DynamicHostPath": `{"namespace": "namespace"}`})
+		Expect(err).To(HaveOccurred())
+	})
+})
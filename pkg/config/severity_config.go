@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// Normalized severity levels, from the lowest to the highest
+const (
+	LevelDebug   = "debug"
+	LevelInfo    = "info"
+	LevelWarn    = "warn"
+	LevelError   = "error"
+	LevelFatal   = "fatal"
+	LevelUnknown = "unknown"
+)
+
+// Levels are the normalized severity levels which can be used as threshold, from the lowest to the highest.
+var Levels = []string{LevelDebug, LevelInfo, LevelWarn, LevelError, LevelFatal}
+
+// DefaultLevelKeys are the record keys which are checked for the severity.
+var DefaultLevelKeys = []string{"level", "severity", "lvl"}
+
+// Severity defaults
+const (
+	DefaultLevelLabel      = "level"
+	DefaultLevelMessageKey = "log"
+	// AllNamespaces is the LevelThresholds key of the threshold for namespaces without an own entry.
+	AllNamespaces = "*"
+)
+
+// SeverityConfig holds the configuration of the severity detection.
+type SeverityConfig struct {
+	// Label is the name of the label holding the normalized level.
+	Label string
+	// Keys are the record keys checked for the severity, before the message is parsed.
+	Keys []string
+	// MessageKey is the record key of the message which is checked for klog, json and logfmt levels.
+	MessageKey string
+	// Thresholds maps a namespace to the lowest level which is sent.
+	Thresholds map[string]string
+}
+
+// LevelIndex returns the position of the level in Levels or -1 if it is not a normalized level.
+func LevelIndex(level string) int {
+	for i, l := range Levels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// initSeverityConfig parses the severity detection keys. It is enabled by DetectLevel.
+func initSeverityConfig(cfg Getter, res *Config) error {
+	detectLevel := cfg.Get("DetectLevel")
+	if detectLevel == "" {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(detectLevel)
+	if err != nil {
+		return fmt.Errorf("invalid boolean DetectLevel: %v", detectLevel)
+	}
+	if !enabled {
+		return nil
+	}
+
+	conf := &SeverityConfig{
+		Label:      DefaultLevelLabel,
+		Keys:       DefaultLevelKeys,
+		MessageKey: DefaultLevelMessageKey,
+	}
+	if label := cfg.Get("LevelLabel"); label != "" {
+		if !model.LabelName(label).IsValid() {
+			return fmt.Errorf("invalid LevelLabel: %s", label)
+		}
+		conf.Label = label
+	}
+	if levelKeys := cfg.Get("LevelKeys"); levelKeys != "" {
+		conf.Keys = nil
+		for _, key := range strings.Split(levelKeys, ",") {
+			conf.Keys = append(conf.Keys, strings.TrimSpace(key))
+		}
+	}
+	if messageKey := cfg.Get("LevelMessageKey"); messageKey != "" {
+		conf.MessageKey = messageKey
+	}
+
+	levelThresholds := cfg.Get("LevelThresholds")
+	if levelThresholds != "" {
+		if err := json.Unmarshal([]byte(levelThresholds), &conf.Thresholds); err != nil {
+			return fmt.Errorf("failed to Unmarshal LevelThresholds json: %s", err)
+		}
+		for namespace, level := range conf.Thresholds {
+			if LevelIndex(level) < 0 {
+				return fmt.Errorf("invalid level %q for namespace %q in LevelThresholds, expected one of %v", level, namespace, Levels)
+			}
+		}
+	}
+
+	res.PluginConfig.Severity = conf
+	return nil
+}
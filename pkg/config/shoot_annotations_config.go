@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ShootAnnotationPrefix is the prefix of the shoot annotations which override the log shipping of a shoot.
+const ShootAnnotationPrefix = "logging.gardener.cloud/"
+
+// Shoot annotations, without the ShootAnnotationPrefix, which can be allowed in ShootAnnotations
+const (
+	// ShootAnnotationSendToMain sends the logs to the shoot Vali, or mutes it, in every state.
+	ShootAnnotationSendToMain = "send-to-main"
+	// ShootAnnotationSendToDefault sends a copy of the logs to the default Vali, or mutes it, in every state.
+	ShootAnnotationSendToDefault = "send-to-default"
+	// ShootAnnotationExtraLabels adds labels to the logs of the shoot, e.g. "team=logging,tier=backend".
+	ShootAnnotationExtraLabels = "extra-labels"
+	// ShootAnnotationEndpoint replaces the endpoint of the shoot Vali.
+	ShootAnnotationEndpoint = "endpoint"
+)
+
+// ShootAnnotationNames are the supported shoot annotations.
+var ShootAnnotationNames = []string{ShootAnnotationSendToMain, ShootAnnotationSendToDefault, ShootAnnotationExtraLabels, ShootAnnotationEndpoint}
+
+// AllowsShootAnnotation reports if the shoot annotation is respected.
+func (c ControllerConfig) AllowsShootAnnotation(annotation string) bool {
+	return contains(c.ShootAnnotations, annotation)
+}
+
+// initShootAnnotationsConfig parses the ShootAnnotations key. Without it no shoot annotation is respected.
+func initShootAnnotationsConfig(cfg Getter, res *Config) error {
+	annotations := cfg.Get("ShootAnnotations")
+	if annotations == "" {
+		return nil
+	}
+
+	for _, a := range strings.Split(annotations, ",") {
+		a = strings.TrimPrefix(strings.TrimSpace(a), ShootAnnotationPrefix)
+		if !contains(ShootAnnotationNames, a) {
+			return fmt.Errorf("invalid ShootAnnotations: unknown annotation %s, expected %s", a, strings.Join(ShootAnnotationNames, ", "))
+		}
+		res.ControllerConfig.ShootAnnotations = append(res.ControllerConfig.ShootAnnotations, a)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// Encodings of the structured metadata appended to the line
+const (
+	StructuredMetadataLogfmt = "logfmt"
+	StructuredMetadataJSON   = "json"
+)
+
+// StructuredMetadataConfig holds the configuration of the attributes which travel with the entries without being indexed.
+type StructuredMetadataConfig struct {
+	// Attributes are the attributes in the configured order.
+	Attributes []StructuredMetadataAttribute
+	// Encoding is the encoding of the attributes appended to the line.
+	Encoding string
+}
+
+// StructuredMetadataAttribute is an attribute taken from a record key.
+type StructuredMetadataAttribute struct {
+	// Name is the name of the attribute.
+	Name model.LabelName
+	// Field is the record key, nested keys are separated by dots, e.g. kubernetes.pod_id.
+	Field string
+}
+
+// initStructuredMetadataConfig parses the StructuredMetadata keys. The attributes are written as
+// name, taken from the record key of the same name, or as name=field with a dot separated field.
+func initStructuredMetadataConfig(cfg Getter, res *Config) error {
+	attributes := cfg.Get("StructuredMetadata")
+	if attributes == "" {
+		return nil
+	}
+
+	conf := &StructuredMetadataConfig{Encoding: StructuredMetadataLogfmt}
+	seen := map[model.LabelName]bool{}
+	for _, attribute := range strings.Split(attributes, ",") {
+		attribute = strings.TrimSpace(attribute)
+		name, field, found := strings.Cut(attribute, "=")
+		if !found {
+			field = name
+		}
+		a := StructuredMetadataAttribute{Name: model.LabelName(strings.TrimSpace(name)), Field: strings.TrimSpace(field)}
+		if !a.Name.IsValid() {
+			return fmt.Errorf("invalid StructuredMetadata attribute name: %q", a.Name)
+		}
+		for _, key := range strings.Split(a.Field, ".") {
+			if key == "" {
+				return fmt.Errorf("invalid StructuredMetadata field of %s: %q", a.Name, a.Field)
+			}
+		}
+		if seen[a.Name] {
+			return fmt.Errorf("duplicated StructuredMetadata attribute: %s", a.Name)
+		}
+		seen[a.Name] = true
+		for _, key := range res.PluginConfig.LabelKeys {
+			if key == a.Field {
+				return fmt.Errorf("StructuredMetadata key %s is already a label in LabelKeys", key)
+			}
+		}
+		conf.Attributes = append(conf.Attributes, a)
+	}
+
+	if encoding := cfg.Get("StructuredMetadataEncoding"); encoding != "" {
+		switch encoding {
+		case StructuredMetadataLogfmt, StructuredMetadataJSON:
+			conf.Encoding = encoding
+		default:
+			return fmt.Errorf("invalid StructuredMetadataEncoding: %s, expected %s or %s", encoding, StructuredMetadataLogfmt, StructuredMetadataJSON)
+		}
+	}
+
+	res.PluginConfig.StructuredMetadata = conf
+	return nil
+}
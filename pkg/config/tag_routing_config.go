@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/prometheus/common/model"
+)
+
+// TagRoute applies to the records whose fluent-bit tag matches the glob.
+type TagRoute struct {
+	// Match is a glob of the fluent-bit tag, e.g. "journald.*".
+	Match string `json:"match" yaml:"match"`
+	// Host replaces the dynamic host of the records, which selects the client of the cluster.
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+	// Target is the name of a RoutingTargets entry which receives the records.
+	Target string `json:"target,omitempty" yaml:"target,omitempty"`
+	// Labels are added to the label set of the records.
+	Labels model.LabelSet `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Tenant is the tenant of the records.
+	Tenant string `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+}
+
+// Matches reports if the tag matches the glob of the route.
+func (r TagRoute) Matches(tag string) bool {
+	ok, _ := path.Match(r.Match, tag)
+	return ok
+}
+
+// initTagRoutingConfig parses the TagRouting and InjectTag keys.
+func initTagRoutingConfig(cfg Getter, res *Config) error {
+	var err error
+	if injectTag := cfg.Get("InjectTag"); injectTag != "" {
+		if res.PluginConfig.InjectTag, err = strconv.ParseBool(injectTag); err != nil {
+			return fmt.Errorf("invalid value for InjectTag, error: %v", err)
+		}
+	}
+
+	tagRouting := cfg.Get("TagRouting")
+	if tagRouting == "" {
+		return nil
+	}
+	if res.PluginConfig.TagRouting, err = parseTagRouting(tagRouting); err != nil {
+		return err
+	}
+	for i, r := range res.PluginConfig.TagRouting {
+		if r.Target == "" {
+			continue
+		}
+		if len(res.PluginConfig.DynamicHostPath) == 0 {
+			return fmt.Errorf("invalid TagRouting route %d: target %s needs DynamicHostPath", i, r.Target)
+		}
+		if _, ok := res.ControllerConfig.RoutingTargets[r.Target]; !ok {
+			return fmt.Errorf("invalid TagRouting route %d: target %s is not defined in RoutingTargets", i, r.Target)
+		}
+	}
+	return nil
+}
+
+// parseTagRouting parses the routes either from the file at tagRouting or from the inline json.
+func parseTagRouting(tagRouting string) ([]TagRoute, error) {
+	content, err := readFileOrInline("TagRouting", tagRouting)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []TagRoute
+	if err := json.Unmarshal(content, &res); err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal TagRouting json: %s", err)
+	}
+	for i, r := range res {
+		if r.Match == "" {
+			return nil, fmt.Errorf("invalid TagRouting route %d: match is empty", i)
+		}
+		if _, err := path.Match(r.Match, ""); err != nil {
+			return nil, fmt.Errorf("invalid TagRouting route %d: bad glob %q: %v", i, r.Match, err)
+		}
+		if r.Host != "" && r.Target != "" {
+			return nil, fmt.Errorf("invalid TagRouting route %d: host and target can not be set together", i)
+		}
+		if r.Host == "" && r.Target == "" && len(r.Labels) == 0 && r.Tenant == "" {
+			return nil, fmt.Errorf("invalid TagRouting route %d: needs a host, target, labels or tenant", i)
+		}
+		if err := r.Labels.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid TagRouting route %d: %v", i, err)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// TemplateFuncs are the helper functions available in the LineTemplate and in the template processor.
+var TemplateFuncs = template.FuncMap{
+	"toJson":  toJSON,
+	"default": defaultValue,
+	"trunc":   trunc,
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"trim":    strings.TrimSpace,
+}
+
+// ParseTemplate parses a record template with the TemplateFuncs.
+// Missing record keys are rendered as empty values.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=zero").Funcs(TemplateFuncs).Parse(text)
+}
+
+func toJSON(v interface{}) (string, error) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(js), nil
+}
+
+// defaultValue returns the value, or def if the value is nil or empty.
+// The argument order allows the usage in pipelines, e.g. {{.level | default "info"}}.
+func defaultValue(def, value interface{}) interface{} {
+	if value == nil {
+		return def
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if v.Len() == 0 {
+			return def
+		}
+	}
+	return value
+}
+
+// trunc shortens the value to n characters. A negative n keeps the last characters.
+func trunc(n int, value interface{}) string {
+	s, ok := value.(string)
+	if !ok {
+		if value == nil {
+			return ""
+		}
+		s = fmt.Sprint(value)
+	}
+	runes := []rune(s)
+	switch {
+	case n >= 0 && n < len(runes):
+		return string(runes[:n])
+	case n < 0 && -n < len(runes):
+		return string(runes[len(runes)+n:])
+	}
+	return s
+}
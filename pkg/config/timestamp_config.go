@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Formats of the TimestampKey value. Any other TimestampFormat is used as a Go time layout.
+const (
+	TimestampRFC3339 = "rfc3339"
+	TimestampUnix    = "unix"
+	TimestampUnixMs  = "unix_ms"
+	TimestampUnixNs  = "unix_ns"
+	TimestampKlog    = "klog"
+)
+
+// Fallback policies for records whose timestamp cannot be used
+const (
+	TimestampFallbackEventTime = "event_time"
+	TimestampFallbackNow       = "now"
+	TimestampFallbackDrop      = "drop"
+)
+
+// TimestampConfig holds the configuration for taking the entry timestamp from a record key.
+type TimestampConfig struct {
+	// Key is the record key holding the timestamp.
+	Key string
+	// Format is one of the Timestamp formats or a Go time layout.
+	Format string
+	// MaxSkew is the largest accepted distance of the timestamp from the current time. Zero accepts any timestamp.
+	MaxSkew time.Duration
+	// Fallback is the policy for records without a usable timestamp.
+	Fallback string
+}
+
+// initTimestampConfig parses the Timestamp keys. The extraction is enabled by TimestampKey.
+func initTimestampConfig(cfg Getter, res *Config) error {
+	key := cfg.Get("TimestampKey")
+	if key == "" {
+		return nil
+	}
+
+	conf := &TimestampConfig{
+		Key:      key,
+		Format:   TimestampRFC3339,
+		Fallback: TimestampFallbackEventTime,
+	}
+	if format := cfg.Get("TimestampFormat"); format != "" {
+		conf.Format = format
+	}
+	switch conf.Format {
+	case TimestampRFC3339, TimestampUnix, TimestampUnixMs, TimestampUnixNs, TimestampKlog:
+	default:
+		// A layout without any reference time element formats to itself.
+		if time.Unix(0, 0).UTC().Format(conf.Format) == conf.Format {
+			return fmt.Errorf("invalid TimestampFormat: %s is neither a known format nor a time layout", conf.Format)
+		}
+	}
+
+	maxSkew := cfg.Get("TimestampMaxSkew")
+	if maxSkew != "" {
+		var err error
+		if conf.MaxSkew, err = time.ParseDuration(maxSkew); err != nil {
+			return fmt.Errorf("failed to parse TimestampMaxSkew: %s : %v", maxSkew, err)
+		}
+		if conf.MaxSkew < 0 {
+			return fmt.Errorf("TimestampMaxSkew can not be negative: %s", maxSkew)
+		}
+	}
+
+	if fallback := cfg.Get("TimestampFallback"); fallback != "" {
+		switch fallback {
+		case TimestampFallbackEventTime, TimestampFallbackNow, TimestampFallbackDrop:
+		default:
+			return fmt.Errorf("invalid TimestampFallback: %s, expected one of %s, %s or %s", fallback, TimestampFallbackEventTime, TimestampFallbackNow, TimestampFallbackDrop)
+		}
+		conf.Fallback = fallback
+	}
+
+	res.PluginConfig.Timestamp = conf
+	return nil
+}
@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DefaultTopTalkersWindow is the default time over which the top talkers are reported.
+const DefaultTopTalkersWindow = 10 * time.Minute
+
+// TopTalkersConfig holds the configuration of the throughput accounting by namespace, pod and container.
+type TopTalkersConfig struct {
+	// Size is the number of the heaviest namespaces, pods and containers reported per cluster.
+	Size int
+	// Window is the time over which the throughput is accounted. It is a whole number of minutes.
+	Window time.Duration
+}
+
+// initTopTalkersConfig parses the TopTalkers keys. The accounting is enabled by TopTalkers.
+func initTopTalkersConfig(cfg Getter, res *Config) error {
+	topTalkers := cfg.Get("TopTalkers")
+	if topTalkers == "" {
+		return nil
+	}
+
+	conf := &TopTalkersConfig{Window: DefaultTopTalkersWindow}
+	var err error
+	if conf.Size, err = strconv.Atoi(topTalkers); err != nil || conf.Size <= 0 {
+		return fmt.Errorf("invalid TopTalkers: %s, expected a positive number", topTalkers)
+	}
+	if window := cfg.Get("TopTalkersWindow"); window != "" {
+		if conf.Window, err = time.ParseDuration(window); err != nil {
+			return fmt.Errorf("failed to parse TopTalkersWindow: %s : %v", window, err)
+		}
+		if conf.Window < time.Minute || conf.Window%time.Minute != 0 {
+			return fmt.Errorf("TopTalkersWindow has to be a whole number of minutes: %s", window)
+		}
+	}
+
+	res.PluginConfig.TopTalkers = conf
+	return nil
+}
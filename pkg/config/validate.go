@@ -0,0 +1,206 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Validate runs the cross-field checks on a parsed configuration.
+// It returns an error for combinations which fail when the plugin starts and
+// warnings for settings which are silently ignored.
+func Validate(cfg Getter, conf *Config) ([]string, error) {
+	var warnings []string
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	if len(conf.PluginConfig.DynamicHostPath) > 0 {
+		if _, err := regexp.Compile(conf.PluginConfig.DynamicHostRegex); err != nil {
+			return nil, fmt.Errorf("invalid DynamicHostRegex %q: %v", conf.PluginConfig.DynamicHostRegex, err)
+		}
+		if conf.ControllerConfig.DynamicHostURL != "" {
+			for _, key := range []string{"DynamicHostPrefix", "DynamicHostSuffix"} {
+				if cfg.Get(key) != "" {
+					warn("%s is ignored because DynamicHostURL is set", key)
+				}
+			}
+		}
+	} else {
+		for _, key := range []string{"DynamicHostPrefix", "DynamicHostSuffix", "DynamicHostURL", "DynamicHostRegex"} {
+			if cfg.Get(key) != "" {
+				warn("%s is ignored because DynamicHostPath is not set", key)
+			}
+		}
+	}
+
+	if conf.PluginConfig.KubernetesMetadata.FallbackToTagWhenMetadataIsMissing {
+		expression := conf.PluginConfig.KubernetesMetadata.TagPrefix + conf.PluginConfig.KubernetesMetadata.TagExpression
+		if _, err := regexp.Compile(expression); err != nil {
+			return nil, fmt.Errorf("invalid TagPrefix and TagExpression %q: %v", expression, err)
+		}
+	} else if conf.PluginConfig.KubernetesMetadata.DropLogEntryWithoutK8sMetadata {
+		warn("DropLogEntryWithoutK8sMetadata is ignored because FallbackToTagWhenMetadataIsMissing is not set")
+	}
+
+	if len(conf.PluginConfig.DynamicTenant.Rules) == 0 {
+		if cfg.Get("RemoveTenantIdWhenSendingToDefaultURL") != "" {
+			warn("RemoveTenantIdWhenSendingToDefaultURL is ignored because DynamicTenant is not set")
+		}
+		if cfg.Get("DynamicTenantMatch") != "" {
+			warn("DynamicTenantMatch is ignored because DynamicTenant is not set")
+		}
+	} else if conf.PluginConfig.DynamicTenant.Match == DynamicTenantMatchAll && !conf.PluginConfig.EnableMultiTenancy {
+		warn("DynamicTenantMatch all sends the records only to the tenant of the first matching rule because EnableMultiTenancy is not set")
+	}
+
+	if cfg.Get("LabelMapPath") != "" && cfg.Get("LabelKeys") != "" {
+		warn("LabelKeys is ignored because LabelMapPath is set")
+	}
+
+	if conf.PluginConfig.LineTemplate != "" && conf.PluginConfig.LineFormat != TemplateFormat {
+		warn("LineTemplate is ignored because LineFormat is not template")
+	}
+
+	if !conf.ClientConfig.BufferConfig.Buffer {
+		for _, key := range []string{"BufferType", "QueueDir", "QueueSegmentSize", "QueueSync", "QueueName"} {
+			if cfg.Get(key) != "" {
+				warn("%s is ignored because Buffer is not enabled", key)
+			}
+		}
+	}
+
+	if conf.PluginConfig.Multiline == nil {
+		for _, key := range []string{"MultilineKey", "MultilineFlushTimeout", "MultilineMaxLines", "MultilineMaxBytes"} {
+			if cfg.Get(key) != "" {
+				warn("%s is ignored because no Multiline preset or pattern is set", key)
+			}
+		}
+	}
+
+	if conf.PluginConfig.Severity == nil {
+		for _, key := range []string{"LevelLabel", "LevelKeys", "LevelMessageKey", "LevelThresholds"} {
+			if cfg.Get(key) != "" {
+				warn("%s is ignored because DetectLevel is not enabled", key)
+			}
+		}
+	}
+
+	if conf.PluginConfig.ParseLog == nil {
+		for _, key := range []string{"ParseLogKey", "ParseLogPrefix", "ParseLogKeepOriginal"} {
+			if cfg.Get(key) != "" {
+				warn("%s is ignored because ParseLog is not set", key)
+			}
+		}
+	}
+
+	if conf.PluginConfig.Timestamp == nil {
+		for _, key := range []string{"TimestampFormat", "TimestampMaxSkew", "TimestampFallback"} {
+			if cfg.Get(key) != "" {
+				warn("%s is ignored because TimestampKey is not set", key)
+			}
+		}
+	}
+
+	if conf.PluginConfig.LogMetrics == nil {
+		for _, key := range []string{"LogMetricsMaxSeries", "LogMetricsIdleTimeout"} {
+			if cfg.Get(key) != "" {
+				warn("%s is ignored because LogMetrics is not set", key)
+			}
+		}
+	}
+
+	if conf.PluginConfig.TopTalkers == nil && cfg.Get("TopTalkersWindow") != "" {
+		warn("TopTalkersWindow is ignored because TopTalkers is not set")
+	}
+
+	if conf.PluginConfig.WorkerPool == nil {
+		for _, key := range []string{"WorkerQueueSize", "WorkerShardKey"} {
+			if cfg.Get(key) != "" {
+				warn("%s is ignored because Workers is not set", key)
+			}
+		}
+	}
+
+	if conf.PluginConfig.StructuredMetadata == nil && cfg.Get("StructuredMetadataEncoding") != "" {
+		warn("StructuredMetadataEncoding is ignored because StructuredMetadata is not set")
+	}
+
+	if conf.PluginConfig.PodMetadata == nil {
+		for _, key := range []string{"EnrichNodeName", "EnrichAnnotations", "EnrichCacheTTL", "EnrichCacheSize"} {
+			if cfg.Get(key) != "" {
+				warn("%s is ignored because EnrichKubernetesMetadata is not enabled", key)
+			}
+		}
+	}
+
+	if conf.PluginConfig.PodAnnotations == nil && cfg.Get("PodAnnotationNamespaces") != "" {
+		warn("PodAnnotationNamespaces is ignored because PodAnnotations is not set")
+	}
+
+	if conf.PluginConfig.ReloadConfigPath != "" && !conf.PluginConfig.HotReload {
+		warn("ReloadConfigPath is ignored because HotReload is not enabled")
+	}
+	if conf.PluginConfig.HotReload && conf.PluginConfig.LabelMapPath == "" && conf.PluginConfig.ReloadConfigPath == "" {
+		warn("HotReload is enabled, but neither a LabelMapPath file nor ReloadConfigPath is set")
+	}
+
+	if len(conf.PluginConfig.DynamicHostPath) == 0 && (cfg.Get("RoutingPolicy") != "" || cfg.Get("RoutingTargets") != "") {
+		warn("RoutingPolicy and RoutingTargets are ignored because DynamicHostPath is not set")
+	}
+	if len(conf.PluginConfig.DynamicHostPath) == 0 && cfg.Get("DynamicTargetSource") != "" {
+		warn("DynamicTargetSource is ignored because DynamicHostPath is not set")
+	}
+	if len(conf.PluginConfig.DynamicHostPath) > 0 && conf.ControllerConfig.DynamicTargetSource != TargetSourceCluster && len(conf.ControllerConfig.ShootAnnotations) > 0 {
+		warn("ShootAnnotations is ignored because DynamicTargetSource is not cluster")
+	}
+	if len(conf.PluginConfig.DynamicHostPath) == 0 && len(conf.ControllerConfig.ShootAnnotations) > 0 {
+		warn("ShootAnnotations is ignored because DynamicHostPath is not set")
+	}
+	if conf.ControllerConfig.DynamicTargetSource != TargetSourceFile && conf.ControllerConfig.DynamicTargetFile != "" {
+		warn("DynamicTargetFile is ignored because DynamicTargetSource is not file")
+	}
+	if len(conf.PluginConfig.DynamicHostPath) == 0 && len(conf.ControllerConfig.RelabelConfigs) > 0 {
+		warn("ControllerRelabelConfigs is ignored because DynamicHostPath is not set")
+	}
+	if routingPolicy := cfg.Get("RoutingPolicy"); routingPolicy != "" {
+		var overrides RoutingPolicy
+		// The routing policy is already validated while parsing.
+		_ = json.Unmarshal([]byte(routingPolicy), &overrides)
+		for _, k := range legacyRoutingKeys {
+			if _, ok := overrides[k.state]; !ok {
+				continue
+			}
+			for _, key := range []string{k.mainKey, k.defaultKey} {
+				if cfg.Get(key) != "" {
+					warn("%s is ignored because RoutingPolicy defines the %s state", key, k.state)
+				}
+			}
+		}
+	}
+	for _, name := range sortedKeys(conf.ControllerConfig.RoutingTargets) {
+		used := false
+		for _, route := range conf.ControllerConfig.RoutingPolicy {
+			used = used || route.SendsTo(name)
+		}
+		if !used {
+			warn("routing target %s is not used in the RoutingPolicy", name)
+		}
+	}
+
+	return warnings, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("Validate", func() {
+	DescribeTable("#Validate",
+		func(conf map[string]string, want []string) {
+			cfg, err := ParseConfig(MapGetter(conf))
+			Expect(err).ToNot(HaveOccurred())
+			warnings, err := Validate(MapGetter(conf), cfg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(Equal(want))
+		},
+		Entry("default values", map[string]string{}, nil),
+		Entry("LabelKeys with LabelMapPath", map[string]string{
+			"LabelKeys":    "app",
+			"LabelMapPath": `{"app": "app"}`,
+		}, []string{"LabelKeys is ignored because LabelMapPath is set"}),
+		Entry("dynamic host settings without DynamicHostPath", map[string]string{
+			"DynamicHostPrefix": "http://vali.",
+			"RoutingPolicy":     `{"ready": {"targets": ["main"]}}`,
+		}, []string{
+			"DynamicHostPrefix is ignored because DynamicHostPath is not set",
+			"RoutingPolicy and RoutingTargets are ignored because DynamicHostPath is not set",
+		}),
+		Entry("buffer settings without Buffer", map[string]string{
+			"QueueDir": "/tmp",
+		}, []string{"QueueDir is ignored because Buffer is not enabled"}),
+		Entry("multiline settings without pattern", map[string]string{
+			"MultilineMaxLines": "10",
+		}, []string{"MultilineMaxLines is ignored because no Multiline preset or pattern is set"}),
+		Entry("per state flag overridden by RoutingPolicy and unused target", map[string]string{
+			"DynamicHostPath":                         `{"namespace": "namespace"}`,
+			"DynamicHostRegex":                        "^shoot-",
+			"SendLogsToMainClusterWhenIsInReadyState": "false",
+			"RoutingTargets":                          `{"audit": "http://audit:3100/vali/api/v1/push"}`,
+			"RoutingPolicy":                           `{"ready": {"targets": ["default"]}}`,
+		}, []string{
+			"SendLogsToMainClusterWhenIsInReadyState is ignored because RoutingPolicy defines the ready state",
+			"routing target audit is not used in the RoutingPolicy",
+		}),
+		Entry("LineTemplate without template LineFormat", map[string]string{
+			"LineTemplate": "{{.log}}",
+		}, []string{"LineTemplate is ignored because LineFormat is not template"}),
+		Entry("timestamp settings without TimestampKey", map[string]string{
+			"TimestampFormat": "unix",
+		}, []string{"TimestampFormat is ignored because TimestampKey is not set"}),
+		Entry("enrichment settings without EnrichKubernetesMetadata", map[string]string{
+			"EnrichKubernetesMetadata": "false",
+			"EnrichNodeName":           "node-1",
+		}, []string{"EnrichNodeName is ignored because EnrichKubernetesMetadata is not enabled"}),
+		Entry("PodAnnotationNamespaces without PodAnnotations", map[string]string{
+			"PodAnnotationNamespaces": "garden",
+		}, []string{"PodAnnotationNamespaces is ignored because PodAnnotations is not set"}),
+		Entry("DynamicTenantMatch all without EnableMultiTenancy", map[string]string{
+			"DynamicTenant":      "user tag user",
+			"DynamicTenantMatch": "all",
+		}, []string{"DynamicTenantMatch all sends the records only to the tenant of the first matching rule because EnableMultiTenancy is not set"}),
+		Entry("DynamicHostPrefix with DynamicHostURL", map[string]string{
+			"DynamicHostPath":   `["kubernetes.namespace_name"]`,
+			"DynamicHostRegex":  "^shoot-",
+			"DynamicHostPrefix": "http://vali.",
+			"DynamicHostURL":    "http://vali.{{.Cluster}}.svc:3100/vali/api/v1/push",
+		}, []string{"DynamicHostPrefix is ignored because DynamicHostURL is set"}),
+		Entry("LogMetricsMaxSeries without LogMetrics", map[string]string{
+			"LogMetricsMaxSeries": "100",
+		}, []string{"LogMetricsMaxSeries is ignored because LogMetrics is not set"}),
+		Entry("TopTalkersWindow without TopTalkers", map[string]string{
+			"TopTalkersWindow": "5m",
+		}, []string{"TopTalkersWindow is ignored because TopTalkers is not set"}),
+		Entry("WorkerQueueSize without Workers", map[string]string{
+			"WorkerQueueSize": "100",
+		}, []string{"WorkerQueueSize is ignored because Workers is not set"}),
+		Entry("StructuredMetadataEncoding without StructuredMetadata", map[string]string{
+			"StructuredMetadataEncoding": "json",
+		}, []string{"StructuredMetadataEncoding is ignored because StructuredMetadata is not set"}),
+		Entry("DynamicTargetSource without DynamicHostPath", map[string]string{
+			"DynamicTargetSource": "namespace",
+		}, []string{"DynamicTargetSource is ignored because DynamicHostPath is not set"}),
+		Entry("DynamicTargetFile without file source", map[string]string{
+			"DynamicHostPath":   `["kubernetes.namespace_name"]`,
+			"DynamicHostRegex":  ".*",
+			"DynamicTargetFile": "/etc/fluent-bit/targets.json",
+		}, []string{"DynamicTargetFile is ignored because DynamicTargetSource is not file"}),
+		Entry("ShootAnnotations without cluster source", map[string]string{
+			"DynamicHostPath":     `["kubernetes.namespace_name"]`,
+			"DynamicHostRegex":    ".*",
+			"DynamicTargetSource": "namespace",
+			"ShootAnnotations":    "send-to-main",
+		}, []string{"ShootAnnotations is ignored because DynamicTargetSource is not cluster"}),
+		Entry("ShootAnnotations without DynamicHostPath", map[string]string{
+			"ShootAnnotations": "send-to-main",
+		}, []string{"ShootAnnotations is ignored because DynamicHostPath is not set"}),
+		Entry("DropLogEntryWithoutK8sMetadata without fallback", map[string]string{
+			"DropLogEntryWithoutK8sMetadata": "true",
+		}, []string{"DropLogEntryWithoutK8sMetadata is ignored because FallbackToTagWhenMetadataIsMissing is not set"}),
+	)
+})
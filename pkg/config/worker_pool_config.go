@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Shard keys of the worker pool
+const (
+	// WorkerShardStream keeps the order of the records of each namespace, pod and container, or else of each tag.
+	WorkerShardStream = "stream"
+	// WorkerShardHost keeps the order of the records of each dynamic host.
+	WorkerShardHost = "host"
+)
+
+// DefaultWorkerQueueSize is the default number of records queued per worker.
+const DefaultWorkerQueueSize = 1024
+
+// WorkerPoolConfig holds the configuration of the parallel record processing.
+type WorkerPoolConfig struct {
+	// Workers is the number of workers processing the records of a chunk.
+	Workers int
+	// QueueSize is the number of records queued per worker. A full queue blocks the flush.
+	QueueSize int
+	// ShardKey selects the worker of a record. The records of a shard are processed in order.
+	ShardKey string
+}
+
+// initWorkerPoolConfig parses the Worker keys. The worker pool is enabled by Workers.
+func initWorkerPoolConfig(cfg Getter, res *Config) error {
+	workers := cfg.Get("Workers")
+	if workers == "" {
+		return nil
+	}
+
+	conf := &WorkerPoolConfig{QueueSize: DefaultWorkerQueueSize, ShardKey: WorkerShardStream}
+	var err error
+	if conf.Workers, err = strconv.Atoi(workers); err != nil || conf.Workers < 0 {
+		return fmt.Errorf("invalid Workers: %s, expected a non negative number", workers)
+	}
+	// No workers keeps the sequential processing on the flush thread.
+	if conf.Workers == 0 {
+		return nil
+	}
+	if queueSize := cfg.Get("WorkerQueueSize"); queueSize != "" {
+		if conf.QueueSize, err = strconv.Atoi(queueSize); err != nil || conf.QueueSize <= 0 {
+			return fmt.Errorf("invalid WorkerQueueSize: %s, expected a positive number", queueSize)
+		}
+	}
+	if shardKey := cfg.Get("WorkerShardKey"); shardKey != "" {
+		switch shardKey {
+		case WorkerShardStream, WorkerShardHost:
+			conf.ShardKey = shardKey
+		default:
+			return fmt.Errorf("invalid WorkerShardKey: %s, expected %s or %s", shardKey, WorkerShardStream, WorkerShardHost)
+		}
+	}
+	if conf.ShardKey == WorkerShardHost && len(res.PluginConfig.DynamicHostPath) == 0 {
+		return fmt.Errorf("WorkerShardKey %s needs DynamicHostPath", WorkerShardHost)
+	}
+
+	res.PluginConfig.WorkerPool = conf
+	return nil
+}
@@ -6,6 +6,7 @@ package controller
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	gardenercorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
@@ -23,26 +24,32 @@ import (
 type clusterState string
 
 const (
-	clusterStateCreation    clusterState = "creation"
-	clusterStateReady       clusterState = "ready"
-	clusterStateHibernating clusterState = "hibernating"
-	clusterStateHibernated  clusterState = "hibernated"
-	clusterStateWakingUp    clusterState = "waking"
-	clusterStateDeletion    clusterState = "deletion"
-	clusterStateDeleted     clusterState = "deleted"
-	clusterStateMigration   clusterState = "migration"
-	clusterStateRestore     clusterState = "restore"
+	clusterStateCreation    clusterState = config.ClusterStateCreation
+	clusterStateReady       clusterState = config.ClusterStateReady
+	clusterStateHibernating clusterState = config.ClusterStateHibernating
+	clusterStateHibernated  clusterState = config.ClusterStateHibernated
+	clusterStateWakingUp    clusterState = config.ClusterStateWaking
+	clusterStateDeletion    clusterState = config.ClusterStateDeletion
+	clusterStateDeleted     clusterState = config.ClusterStateDeleted
+	clusterStateMigration   clusterState = config.ClusterStateMigration
+	clusterStateRestore     clusterState = config.ClusterStateRestore
 )
 
-// Because loosing some logs when switching on and off client is not important we are omiting the synchronization.
+// The route of the client is changed by the informer and the reload goroutines while
+// the flush goroutines send logs, so it is guarded by the mu.
 type controllerClient struct {
 	mainClient        client.ValiClient
 	defaultClient     client.ValiClient
+	targets           map[string]client.ValiClient
+	mu                sync.RWMutex
 	muteMainClient    bool
 	muteDefaultClient bool
+	activeTargets     []client.ValiClient
+	stateLabels       model.LabelSet
+	overrides         shootOverrides
 	state             clusterState
-	defaultClientConf *config.ControllerClientConfiguration
-	mainClientConf    *config.ControllerClientConfiguration
+	routing           config.RoutingPolicy
+	relabelConfigs    config.RelabelConfigs
 	logger            log.Logger
 	name              string
 }
@@ -54,6 +61,8 @@ type ControllerClient interface {
 	client.ValiClient
 	GetState() clusterState
 	SetState(state clusterState)
+	SetRoutingPolicy(policy config.RoutingPolicy)
+	SetOverrides(overrides shootOverrides)
 }
 
 // GetClient search a client with <name> and returned if found.
@@ -73,6 +82,19 @@ func (ctl *controller) GetClient(name string) (client.ValiClient, bool) {
 	return nil, false
 }
 
+// GetTarget returns the client of the routing target with <name>.
+// In case the controller is closed it returns true as second return value.
+func (ctl *controller) GetTarget(name string) (client.ValiClient, bool) {
+	ctl.lock.RLocker().Lock()
+	defer ctl.lock.RLocker().Unlock()
+
+	if ctl.isStopped() {
+		return nil, true
+	}
+
+	return ctl.targets[name], false
+}
+
 func (ctl *controller) newControllerClient(clusterName string, clientConf *config.Config) (*controllerClient, error) {
 	_ = level.Debug(ctl.logger).Log(
 		"msg", "creating new controller client",
@@ -85,23 +107,25 @@ func (ctl *controller) newControllerClient(clusterName string, clientConf *confi
 	}
 
 	c := &controllerClient{
-		mainClient:        mainClient,
-		defaultClient:     ctl.defaultClient,
-		state:             clusterStateCreation, // check here the actual cluster state
-		defaultClientConf: &ctl.conf.ControllerConfig.DefaultControllerClientConfig,
-		mainClientConf:    &ctl.conf.ControllerConfig.MainControllerClientConfig,
-		logger:            ctl.logger,
-		name:              clientConf.ClientConfig.CredativValiConfig.URL.Host,
+		mainClient:     mainClient,
+		defaultClient:  ctl.defaultClient,
+		targets:        ctl.targets,
+		state:          clusterStateCreation, // check here the actual cluster state
+		routing:        ctl.routingPolicy(),
+		relabelConfigs: ctl.conf.ControllerConfig.RelabelConfigs,
+		logger:         ctl.logger,
+		name:           clientConf.ClientConfig.CredativValiConfig.URL.Host,
 	}
 
-	c.muteDefaultClient = !c.defaultClientConf.SendLogsWhenIsInCreationState
-	c.muteMainClient = !c.mainClientConf.SendLogsWhenIsInCreationState
+	route, _ := c.routing.Route(string(clusterStateCreation))
+	c.applyRoute(route)
 
 	return c, nil
 }
 
-func (ctl *controller) createControllerClient(clusterName string, shoot *gardenercorev1beta1.Shoot) {
-	clientConf := ctl.updateClientConfig(clusterName)
+func (ctl *controller) createControllerClient(target Target) {
+	clusterName, shoot := target.Name, target.Shoot
+	clientConf := ctl.updateClientConfig(target)
 	if clientConf == nil {
 		return
 	}
@@ -161,7 +185,30 @@ func (ctl *controller) deleteControllerClient(clusterName string) {
 	)
 }
 
+// recreateControllerClient replaces the client of the target with a client for its current endpoint.
+// The old client is stopped first, because the new client opens the same buffer queue.
+func (ctl *controller) recreateControllerClient(target Target) {
+	ctl.lock.Lock()
+	if ctl.isStopped() {
+		ctl.lock.Unlock()
+		return
+	}
+	c, ok := ctl.clients[target.Name]
+	delete(ctl.clients, target.Name)
+	ctl.lock.Unlock()
+
+	if ok && c != nil {
+		c.StopWait()
+	}
+	_ = level.Info(ctl.logger).Log(
+		"msg", "replacing controller client",
+		"cluster", target.Name,
+	)
+	ctl.createControllerClient(target)
+}
+
 func (ctl *controller) updateControllerClientState(client ControllerClient, shoot *gardenercorev1beta1.Shoot) {
+	client.SetOverrides(ctl.shootOverrides(shoot))
 	client.SetState(getShootState(shoot))
 }
 
@@ -172,25 +219,51 @@ func (c *controllerClient) GetEndPoint() string {
 // Handle processes and sends log to Vali.
 func (c *controllerClient) Handle(ls model.LabelSet, t time.Time, s string) error {
 	var combineErr error
-	// Because we do not use thread save methods here we just copy the variables
-	// in case they have changed during the two consequential calls to Handle.
-	sendToMain, sendToDefault := !c.muteMainClient, !c.muteDefaultClient
+	// The route is copied, because it may change while the log is sent.
+	// The targets and labels of a route are replaced, never modified.
+	c.mu.RLock()
+	sendToMain, sendToDefault, targets, stateLabels, extraLabels := !c.muteMainClient, !c.muteDefaultClient, c.activeTargets, c.stateLabels, c.overrides.labels
+	c.mu.RUnlock()
+
+	if len(stateLabels) > 0 {
+		ls = ls.Merge(stateLabels)
+	}
+	// The labels of the shoot only add the missing labels, they never overwrite the labels of the stream or the route.
+	if len(extraLabels) > 0 {
+		ls = extraLabels.Merge(ls)
+	}
+	if ls = c.relabelConfigs.Process(ls); ls == nil {
+		return nil
+	}
 
+	receivers := len(targets)
 	if sendToMain {
-		// Because this client does not alter the labels set we don't need to clone
-		// it if we don't spread the logs between the two clients. But if we
-		// are sending the log record to both client we have to pass a copy because
-		// we are not sure what kind of label set processing will be done in the corresponding
-		// client which can lead to "concurrent map iteration and map write error".
-		if err := c.mainClient.Handle(copyLabelSet(ls, sendToDefault), t, s); err != nil {
+		receivers++
+	}
+	if sendToDefault {
+		receivers++
+	}
+	// Because this client does not alter the labels set we don't need to clone
+	// it if we don't spread the logs between several clients. But if we
+	// are sending the log record to more than one client we have to pass a copy because
+	// we are not sure what kind of label set processing will be done in the corresponding
+	// client which can lead to "concurrent map iteration and map write error".
+	spread := receivers > 1
+
+	if sendToMain {
+		if err := c.mainClient.Handle(copyLabelSet(ls, spread), t, s); err != nil {
 			combineErr = giterrors.Wrap(combineErr, err.Error())
 		}
 	}
 	if sendToDefault {
-		if err := c.defaultClient.Handle(copyLabelSet(ls, sendToMain), t, s); err != nil {
+		if err := c.defaultClient.Handle(copyLabelSet(ls, spread), t, s); err != nil {
+			combineErr = giterrors.Wrap(combineErr, err.Error())
+		}
+	}
+	for _, target := range targets {
+		if err := target.Handle(copyLabelSet(ls, spread), t, s); err != nil {
 			combineErr = giterrors.Wrap(combineErr, err.Error())
 		}
-
 	}
 	return combineErr
 }
@@ -205,49 +278,26 @@ func (c *controllerClient) StopWait() {
 	c.mainClient.StopWait()
 }
 
-// SetState manages the MuteMainClient and MuteDefaultClient flags.
-// These flags govern the target to which the logs are send.
-// When MuteMainClient is true the logs are sent to the Default which is the gardener vali instance.
-// When MuteDefaultClient is true the logs are sent to the Main which is the shoot vali instance.
+// SetState looks up the route of the new state in the routing policy.
+// The route governs the targets to which the logs are send.
+// When MuteMainClient is true the logs are not sent to the Main which is the shoot vali instance.
+// When MuteDefaultClient is true the logs are not sent to the Default which is the gardener vali instance.
+// Named targets and additional labels of the route are applied as well.
 func (c *controllerClient) SetState(state clusterState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if state == c.state {
 		return
 	}
 
-	switch state {
-	case clusterStateReady:
-		c.muteMainClient = !c.mainClientConf.SendLogsWhenIsInReadyState
-		c.muteDefaultClient = !c.defaultClientConf.SendLogsWhenIsInReadyState
-	case clusterStateHibernating:
-		c.muteMainClient = !c.mainClientConf.SendLogsWhenIsInHibernatingState
-		c.muteDefaultClient = !c.defaultClientConf.SendLogsWhenIsInHibernatingState
-	case clusterStateWakingUp:
-		c.muteMainClient = !c.mainClientConf.SendLogsWhenIsInWakingState
-		c.muteDefaultClient = !c.defaultClientConf.SendLogsWhenIsInWakingState
-	case clusterStateDeletion:
-		c.muteMainClient = !c.mainClientConf.SendLogsWhenIsInDeletionState
-		c.muteDefaultClient = !c.defaultClientConf.SendLogsWhenIsInDeletionState
-	case clusterStateDeleted:
-		c.muteMainClient = !c.mainClientConf.SendLogsWhenIsInDeletedState
-		c.muteDefaultClient = !c.defaultClientConf.SendLogsWhenIsInDeletedState
-	case clusterStateHibernated:
-		c.muteMainClient = !c.mainClientConf.SendLogsWhenIsInHibernatedState
-		c.muteDefaultClient = !c.defaultClientConf.SendLogsWhenIsInHibernatedState
-	case clusterStateRestore:
-		c.muteMainClient = !c.mainClientConf.SendLogsWhenIsInRestoreState
-		c.muteDefaultClient = !c.defaultClientConf.SendLogsWhenIsInRestoreState
-	case clusterStateMigration:
-		c.muteMainClient = !c.mainClientConf.SendLogsWhenIsInMigrationState
-		c.muteDefaultClient = !c.defaultClientConf.SendLogsWhenIsInMigrationState
-	case clusterStateCreation:
-		c.muteMainClient = !c.mainClientConf.SendLogsWhenIsInCreationState
-		c.muteDefaultClient = !c.defaultClientConf.SendLogsWhenIsInCreationState
-	default:
+	route, ok := c.routing.Route(string(state))
+	if !ok {
 		_ = level.Error(c.logger).Log(
 			"msg", fmt.Sprintf("Unknown state %v for cluster %v. The client state will not be changed", state, c.name),
 		)
 		return
 	}
+	c.applyRoute(route)
 
 	_ = level.Debug(c.logger).Log(
 		"msg", "cluster state changed",
@@ -256,11 +306,84 @@ func (c *controllerClient) SetState(state clusterState) {
 		"newState", state,
 		"mute_main_client", c.muteMainClient,
 		"mute_default_client", c.muteDefaultClient,
+		"targets", fmt.Sprintf("%v", route.Targets),
 	)
 	c.state = state
 }
 
+// applyRoute applies the route with the overrides of the shoot. The caller holds the lock.
+func (c *controllerClient) applyRoute(route config.StateRoute) {
+	var activeTargets []client.ValiClient
+	for _, name := range route.Targets {
+		if name == config.MainRoutingTarget || name == config.DefaultRoutingTarget {
+			continue
+		}
+		target, ok := c.targets[name]
+		if !ok {
+			_ = level.Error(c.logger).Log(
+				"msg", fmt.Sprintf("Unknown routing target %v for cluster %v", name, c.name),
+			)
+			continue
+		}
+		activeTargets = append(activeTargets, target)
+	}
+
+	c.muteMainClient = !route.SendsTo(config.MainRoutingTarget)
+	c.muteDefaultClient = !route.SendsTo(config.DefaultRoutingTarget)
+	if c.overrides.sendToMain != nil {
+		c.muteMainClient = !*c.overrides.sendToMain
+	}
+	if c.overrides.sendToDefault != nil {
+		c.muteDefaultClient = !*c.overrides.sendToDefault
+	}
+	c.activeTargets = activeTargets
+	c.stateLabels = route.Labels
+}
+
+// SetRoutingPolicy replaces the routing policy and applies the route of the current state.
+func (c *controllerClient) SetRoutingPolicy(policy config.RoutingPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.routing = policy
+	route, ok := c.routing.Route(string(c.state))
+	if !ok {
+		_ = level.Error(c.logger).Log(
+			"msg", fmt.Sprintf("State %v for cluster %v is missing in the new routing policy. The route will not be changed", c.state, c.name),
+		)
+		return
+	}
+	c.applyRoute(route)
+}
+
+// SetOverrides replaces the overrides of the shoot and applies the route of the current state with them.
+func (c *controllerClient) SetOverrides(overrides shootOverrides) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if overrides.equal(c.overrides) {
+		return
+	}
+	c.overrides = overrides
+	route, ok := c.routing.Route(string(c.state))
+	if !ok {
+		_ = level.Error(c.logger).Log(
+			"msg", fmt.Sprintf("State %v for cluster %v is missing in the routing policy. The overrides will be applied with the next state", c.state, c.name),
+		)
+		return
+	}
+	c.applyRoute(route)
+
+	_ = level.Debug(c.logger).Log(
+		"msg", "shoot overrides changed",
+		"cluster", c.name,
+		"mute_main_client", c.muteMainClient,
+		"mute_default_client", c.muteDefaultClient,
+		"labels", c.overrides.labels.String(),
+	)
+}
+
 // GetState returns the cluster state.
 func (c *controllerClient) GetState() clusterState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.state
 }
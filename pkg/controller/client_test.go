@@ -15,6 +15,7 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/prometheus/common/model"
 	"github.com/weaveworks/common/logging"
+	"gopkg.in/yaml.v2"
 
 	"github.com/gardener/logging/pkg/client"
 	"github.com/gardener/logging/pkg/config"
@@ -34,6 +35,8 @@ var _ = Describe("Controller Client", func() {
 		line2      = "testline2"
 		entry1     = client.Entry{Labels: labels1, Entry: logproto.Entry{Timestamp: timestamp1, Line: line1}}
 		entry2     = client.Entry{Labels: labels2, Entry: logproto.Entry{Timestamp: timestamp2, Line: line2}}
+
+		defaultRouting = config.NewRoutingPolicy(config.MainControllerClientConfig, config.DefaultControllerClientConfig)
 	)
 
 	BeforeEach(func() {
@@ -113,19 +116,17 @@ var _ = Describe("Controller Client", func() {
 		}),
 	)
 	type setStateArgs struct {
-		inputState        clusterState
-		currentState      clusterState
-		defaultClientConf *config.ControllerClientConfiguration
-		mainClientConf    *config.ControllerClientConfiguration
-		want              struct {
+		inputState   clusterState
+		currentState clusterState
+		routing      config.RoutingPolicy
+		want         struct {
 			muteMainClient    bool
 			muteDefaultClient bool
 			state             clusterState
 		}
 	}
 	DescribeTable("#SetState", func(args setStateArgs) {
-		ctlClient.defaultClientConf = args.defaultClientConf
-		ctlClient.mainClientConf = args.mainClientConf
+		ctlClient.routing = args.routing
 		ctlClient.state = args.currentState
 		ctlClient.SetState(args.inputState)
 
@@ -134,10 +135,9 @@ var _ = Describe("Controller Client", func() {
 		Expect(ctlClient.muteMainClient).To(Equal(args.want.muteMainClient))
 	},
 		Entry("Change state from create to creation", setStateArgs{
-			inputState:        clusterStateCreation,
-			currentState:      clusterStateCreation,
-			defaultClientConf: &config.DefaultControllerClientConfig,
-			mainClientConf:    &config.MainControllerClientConfig,
+			inputState:   clusterStateCreation,
+			currentState: clusterStateCreation,
+			routing:      defaultRouting,
 			want: struct {
 				muteMainClient    bool
 				muteDefaultClient bool
@@ -145,10 +145,9 @@ var _ = Describe("Controller Client", func() {
 			}{false, false, clusterStateCreation},
 		}),
 		Entry("Change state from create to ready", setStateArgs{
-			inputState:        clusterStateReady,
-			currentState:      clusterStateCreation,
-			defaultClientConf: &config.DefaultControllerClientConfig,
-			mainClientConf:    &config.MainControllerClientConfig,
+			inputState:   clusterStateReady,
+			currentState: clusterStateCreation,
+			routing:      defaultRouting,
 			want: struct {
 				muteMainClient    bool
 				muteDefaultClient bool
@@ -156,10 +155,9 @@ var _ = Describe("Controller Client", func() {
 			}{false, true, clusterStateReady},
 		}),
 		Entry("Change state from create to hibernating", setStateArgs{
-			inputState:        clusterStateHibernating,
-			currentState:      clusterStateCreation,
-			defaultClientConf: &config.DefaultControllerClientConfig,
-			mainClientConf:    &config.MainControllerClientConfig,
+			inputState:   clusterStateHibernating,
+			currentState: clusterStateCreation,
+			routing:      defaultRouting,
 			want: struct {
 				muteMainClient    bool
 				muteDefaultClient bool
@@ -167,10 +165,9 @@ var _ = Describe("Controller Client", func() {
 			}{true, true, clusterStateHibernating},
 		}),
 		Entry("Change state from create to hibernated", setStateArgs{
-			inputState:        clusterStateHibernated,
-			currentState:      clusterStateCreation,
-			defaultClientConf: &config.DefaultControllerClientConfig,
-			mainClientConf:    &config.MainControllerClientConfig,
+			inputState:   clusterStateHibernated,
+			currentState: clusterStateCreation,
+			routing:      defaultRouting,
 			want: struct {
 				muteMainClient    bool
 				muteDefaultClient bool
@@ -178,10 +175,9 @@ var _ = Describe("Controller Client", func() {
 			}{true, true, clusterStateHibernated},
 		}),
 		Entry("Change state from create to waking", setStateArgs{
-			inputState:        clusterStateWakingUp,
-			currentState:      clusterStateCreation,
-			defaultClientConf: &config.DefaultControllerClientConfig,
-			mainClientConf:    &config.MainControllerClientConfig,
+			inputState:   clusterStateWakingUp,
+			currentState: clusterStateCreation,
+			routing:      defaultRouting,
 			want: struct {
 				muteMainClient    bool
 				muteDefaultClient bool
@@ -189,10 +185,9 @@ var _ = Describe("Controller Client", func() {
 			}{false, true, clusterStateWakingUp},
 		}),
 		Entry("Change state from create to deletion", setStateArgs{
-			inputState:        clusterStateDeletion,
-			currentState:      clusterStateCreation,
-			defaultClientConf: &config.DefaultControllerClientConfig,
-			mainClientConf:    &config.MainControllerClientConfig,
+			inputState:   clusterStateDeletion,
+			currentState: clusterStateCreation,
+			routing:      defaultRouting,
 			want: struct {
 				muteMainClient    bool
 				muteDefaultClient bool
@@ -201,6 +196,190 @@ var _ = Describe("Controller Client", func() {
 		}),
 	)
 
+	Describe("#SetState with routing policy", func() {
+		var auditClient *client.FakeValiClient
+
+		BeforeEach(func() {
+			auditClient = &client.FakeValiClient{}
+			ctlClient.targets = map[string]client.ValiClient{"audit": auditClient}
+			ctlClient.state = clusterStateCreation
+			ctlClient.routing = config.RoutingPolicy{
+				string(clusterStateCreation): {Targets: []string{config.MainRoutingTarget}},
+				string(clusterStateMigration): {
+					Targets: []string{config.DefaultRoutingTarget, "audit"},
+					Labels:  model.LabelSet{"migration": "true"},
+				},
+			}
+		})
+
+		It("Should send to the named target and add the state labels", func() {
+			ctlClient.SetState(clusterStateMigration)
+			Expect(ctlClient.muteMainClient).To(BeTrue())
+			Expect(ctlClient.muteDefaultClient).To(BeFalse())
+
+			Expect(ctlClient.Handle(labels1, timestamp1, line1)).To(Succeed())
+			want := []client.Entry{{
+				Labels: model.LabelSet{"KeyTest1": "ValueTest1", "migration": "true"},
+				Entry:  logproto.Entry{Timestamp: timestamp1, Line: line1},
+			}}
+			Expect(auditClient.Entries).To(Equal(want))
+			Expect(ctlClient.defaultClient.(*client.FakeValiClient).Entries).To(Equal(want))
+			Expect(ctlClient.mainClient.(*client.FakeValiClient).Entries).To(BeNil())
+			Expect(labels1).To(Equal(model.LabelSet{"KeyTest1": "ValueTest1"}))
+		})
+
+		It("Should apply the route of the current state when the routing policy changes", func() {
+			ctlClient.SetState(clusterStateMigration)
+			ctlClient.SetRoutingPolicy(config.RoutingPolicy{
+				string(clusterStateMigration): {Targets: []string{config.MainRoutingTarget}},
+			})
+			Expect(ctlClient.muteMainClient).To(BeFalse())
+			Expect(ctlClient.muteDefaultClient).To(BeTrue())
+			Expect(ctlClient.activeTargets).To(BeEmpty())
+			Expect(ctlClient.stateLabels).To(BeEmpty())
+		})
+
+		It("Should change the route while logs are sent", func() {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 100; i++ {
+					_ = ctlClient.Handle(labels1, timestamp1, line1)
+				}
+			}()
+			for i := 0; i < 100; i++ {
+				ctlClient.SetState(clusterStateMigration)
+				ctlClient.SetState(clusterStateCreation)
+			}
+			<-done
+			Expect(ctlClient.GetState()).To(Equal(clusterStateCreation))
+		})
+
+		It("Should change the routing policy while logs are sent", func() {
+			policy := ctlClient.routing
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 100; i++ {
+					_ = ctlClient.Handle(labels1, timestamp1, line1)
+				}
+			}()
+			for i := 0; i < 100; i++ {
+				ctlClient.SetRoutingPolicy(config.RoutingPolicy{
+					string(clusterStateCreation): {Targets: []string{"audit"}, Labels: model.LabelSet{"reload": "true"}},
+				})
+				ctlClient.SetRoutingPolicy(policy)
+			}
+			<-done
+			Expect(ctlClient.activeTargets).To(BeEmpty())
+		})
+
+		It("Should not change the state when it is missing in the routing policy", func() {
+			ctlClient.SetState(clusterStateReady)
+			Expect(ctlClient.GetState()).To(Equal(clusterStateCreation))
+		})
+	})
+
+	Describe("#SetOverrides", func() {
+		enabled, disabled := true, false
+
+		BeforeEach(func() {
+			ctlClient.state = clusterStateHibernated
+			ctlClient.routing = config.RoutingPolicy{
+				string(clusterStateHibernated): {Labels: model.LabelSet{"state": "hibernated"}},
+				string(clusterStateReady): {
+					Targets: []string{config.MainRoutingTarget},
+					Labels:  model.LabelSet{"state": "ready"},
+				},
+			}
+		})
+
+		It("Should override the route of the current and the next states", func() {
+			ctlClient.SetOverrides(shootOverrides{
+				sendToDefault: &enabled,
+				labels:        model.LabelSet{"team": "logging", "state": "incident"},
+			})
+			Expect(ctlClient.muteMainClient).To(BeTrue())
+			Expect(ctlClient.muteDefaultClient).To(BeFalse())
+			Expect(ctlClient.Handle(labels1, timestamp1, line1)).To(Succeed())
+			Expect(ctlClient.defaultClient.(*client.FakeValiClient).Entries[0].Labels).To(Equal(model.LabelSet{
+				"KeyTest1": "ValueTest1", "team": "logging", "state": "hibernated",
+			}))
+
+			ctlClient.SetState(clusterStateReady)
+			Expect(ctlClient.muteMainClient).To(BeFalse())
+			Expect(ctlClient.muteDefaultClient).To(BeFalse())
+			Expect(ctlClient.Handle(labels1, timestamp1, line1)).To(Succeed())
+			Expect(ctlClient.mainClient.(*client.FakeValiClient).Entries[0].Labels).To(Equal(model.LabelSet{
+				"KeyTest1": "ValueTest1", "team": "logging", "state": "ready",
+			}))
+		})
+
+		It("Should mute the main client in every state", func() {
+			ctlClient.SetOverrides(shootOverrides{sendToMain: &disabled})
+			ctlClient.SetState(clusterStateReady)
+			Expect(ctlClient.Handle(labels1, timestamp1, line1)).To(Succeed())
+			Expect(ctlClient.mainClient.(*client.FakeValiClient).Entries).To(BeNil())
+		})
+
+		It("Should change the overrides while logs are sent", func() {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 100; i++ {
+					_ = ctlClient.Handle(labels1, timestamp1, line1)
+				}
+			}()
+			for i := 0; i < 100; i++ {
+				ctlClient.SetOverrides(shootOverrides{sendToMain: &enabled, labels: model.LabelSet{"team": "logging"}})
+				ctlClient.SetOverrides(shootOverrides{})
+			}
+			<-done
+			Expect(ctlClient.stateLabels).To(Equal(model.LabelSet{"state": "hibernated"}))
+			Expect(ctlClient.overrides.labels).To(BeEmpty())
+		})
+
+		It("Should restore the route when the overrides are removed", func() {
+			ctlClient.SetState(clusterStateReady)
+			ctlClient.SetOverrides(shootOverrides{sendToMain: &disabled, sendToDefault: &enabled})
+			ctlClient.SetOverrides(shootOverrides{})
+			Expect(ctlClient.muteMainClient).To(BeFalse())
+			Expect(ctlClient.muteDefaultClient).To(BeTrue())
+			Expect(ctlClient.stateLabels).To(Equal(model.LabelSet{"state": "ready"}))
+		})
+	})
+
+	Describe("#Handle with relabel configs", func() {
+		BeforeEach(func() {
+			ctlClient.muteDefaultClient = true
+			ctlClient.relabelConfigs = parseRelabelConfigs(`
+- source_labels: [KeyTest1]
+  regex: Value(.*)
+  target_label: test
+  replacement: $1
+- action: labeldrop
+  regex: KeyTest1
+- source_labels: [KeyTest2]
+  regex: .+
+  action: drop
+`)
+		})
+
+		It("Should rewrite the labels", func() {
+			Expect(ctlClient.Handle(labels1, timestamp1, line1)).To(Succeed())
+			Expect(ctlClient.mainClient.(*client.FakeValiClient).Entries).To(Equal([]client.Entry{{
+				Labels: model.LabelSet{"test": "Test1"},
+				Entry:  logproto.Entry{Timestamp: timestamp1, Line: line1},
+			}}))
+			Expect(labels1).To(Equal(model.LabelSet{"KeyTest1": "ValueTest1"}))
+		})
+
+		It("Should drop the entry", func() {
+			Expect(ctlClient.Handle(labels2, timestamp2, line2)).To(Succeed())
+			Expect(ctlClient.mainClient.(*client.FakeValiClient).Entries).To(BeNil())
+		})
+	})
+
 	Describe("#Stop", func() {
 		It("Should stop immediately", func() {
 			ctlClient.Stop()
@@ -217,8 +396,7 @@ var _ = Describe("Controller Client", func() {
 
 	Describe("#GetState", func() {
 		It("Should get the state", func() {
-			ctlClient.defaultClientConf = &config.DefaultControllerClientConfig
-			ctlClient.mainClientConf = &config.MainControllerClientConfig
+			ctlClient.routing = defaultRouting
 			ctlClient.SetState(clusterStateReady)
 			currentState := ctlClient.GetState()
 			Expect(currentState).To(Equal(clusterStateReady))
@@ -280,6 +458,16 @@ func (c *fakeControllerClient) SetState(state clusterState) {
 	c.state = state
 }
 
+func (c *fakeControllerClient) SetRoutingPolicy(_ config.RoutingPolicy) {}
+
+func (c *fakeControllerClient) SetOverrides(_ shootOverrides) {}
+
 func (c *fakeControllerClient) GetState() clusterState {
 	return c.state
 }
+
+func parseRelabelConfigs(s string) config.RelabelConfigs {
+	var res config.RelabelConfigs
+	Expect(yaml.UnmarshalStrict([]byte(s), &res)).To(Succeed())
+	return res
+}
@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+
+	extensioncontroller "github.com/gardener/gardener/extensions/pkg/controller"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+// clusterSource provides the Gardener clusters of a seed as targets.
+type clusterSource struct {
+	informer cache.SharedIndexInformer
+	r        cache.ResourceEventHandlerRegistration
+	handler  TargetHandler
+	logger   log.Logger
+}
+
+var _ TargetSource = &clusterSource{}
+
+// NewClusterSource returns a TargetSource watching the extensionsv1alpha1.Cluster resources of the informer.
+func NewClusterSource(informer cache.SharedIndexInformer, logger log.Logger) TargetSource {
+	return &clusterSource{informer: informer, logger: logger}
+}
+
+func (s *clusterSource) Start(handler TargetHandler) error {
+	s.handler = handler
+	var err error
+	if s.r, err = s.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    s.addFunc,
+		DeleteFunc: s.delFunc,
+		UpdateFunc: s.updateFunc,
+	}); err != nil {
+		return fmt.Errorf("failed to add event handler: %v", err)
+	}
+	return nil
+}
+
+func (s *clusterSource) HasSynced() bool {
+	return s.informer.HasSynced()
+}
+
+func (s *clusterSource) Stop() {
+	if s.informer == nil || s.r == nil {
+		return
+	}
+
+	if err := s.informer.RemoveEventHandler(s.r); err != nil {
+		_ = level.Error(s.logger).Log("msg", fmt.Sprintf("failed to remove event handler: %v", err))
+	}
+}
+
+// cluster informer callback
+func (s *clusterSource) addFunc(obj interface{}) {
+	cluster, ok := obj.(*extensionsv1alpha1.Cluster)
+	if !ok {
+		metrics.Errors.WithLabelValues(metrics.ErrorAddFuncNotACluster).Inc()
+		_ = level.Error(s.logger).Log("msg", fmt.Sprintf("%v is not a cluster", obj))
+		return
+	}
+
+	shoot, err := extensioncontroller.ShootFromCluster(cluster)
+	if err != nil {
+		metrics.Errors.WithLabelValues(metrics.ErrorCanNotExtractShoot).Inc()
+		_ = level.Error(s.logger).Log("msg", fmt.Sprintf("can't extract shoot from cluster %v", cluster.Name))
+		return
+	}
+
+	s.handler.AddTarget(Target{Name: cluster.Name, Shoot: shoot})
+}
+
+func (s *clusterSource) updateFunc(oldObj interface{}, newObj interface{}) {
+	oldCluster, ok := oldObj.(*extensionsv1alpha1.Cluster)
+	if !ok {
+		metrics.Errors.WithLabelValues(metrics.ErrorUpdateFuncOldNotACluster).Inc()
+		_ = level.Error(s.logger).Log("msg", fmt.Sprintf("%v is not a cluster", oldCluster))
+		return
+	}
+
+	newCluster, ok := newObj.(*extensionsv1alpha1.Cluster)
+	if !ok {
+		metrics.Errors.WithLabelValues(metrics.ErrorUpdateFuncNewNotACluster).Inc()
+		_ = level.Error(s.logger).Log("msg", fmt.Sprintf("%v is not a cluster", newCluster))
+		return
+	}
+
+	if bytes.Equal(oldCluster.Spec.Shoot.Raw, newCluster.Spec.Shoot.Raw) {
+		_ = level.Debug(s.logger).Log("msg", "reconciliation skipped, shoot is the same", "cluster", newCluster.Name)
+		return
+	}
+
+	shoot, err := extensioncontroller.ShootFromCluster(newCluster)
+	if err != nil {
+		metrics.Errors.WithLabelValues(metrics.ErrorCanNotExtractShoot).Inc()
+		_ = level.Error(s.logger).Log("msg", fmt.Sprintf("can't extract shoot from cluster %v", newCluster.Name))
+		return
+	}
+
+	s.handler.UpdateTarget(Target{Name: newCluster.Name, Shoot: shoot})
+}
+
+func (s *clusterSource) delFunc(obj interface{}) {
+	cluster, ok := obj.(*extensionsv1alpha1.Cluster)
+	if !ok {
+		metrics.Errors.WithLabelValues(metrics.ErrorDeleteFuncNotAcluster).Inc()
+		_ = level.Error(s.logger).Log("msg", fmt.Sprintf("%v is not a cluster", obj))
+		return
+	}
+
+	s.handler.DeleteTarget(cluster.Name)
+}
@@ -8,12 +8,11 @@ import (
 	"bytes"
 	"fmt"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/util/flagext"
-	extensioncontroller "github.com/gardener/gardener/extensions/pkg/controller"
 	gardenercorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
-	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"k8s.io/client-go/tools/cache"
@@ -31,36 +30,56 @@ const (
 // create Vali clients base on them
 type Controller interface {
 	GetClient(name string) (client.ValiClient, bool)
+	GetTarget(name string) (client.ValiClient, bool)
+	SetRoutingPolicy(policy config.RoutingPolicy)
 	Stop()
 }
 type controller struct {
 	defaultClient client.ValiClient
+	targets       map[string]client.ValiClient
+	routing       config.RoutingPolicy
 	conf          *config.Config
 	lock          sync.RWMutex
 	clients       map[string]ControllerClient
 	logger        log.Logger
-	informer      cache.SharedIndexInformer
-	r             cache.ResourceEventHandlerRegistration
+	source        TargetSource
+	urlTemplate   *template.Template
 }
 
-// NewController return Controller interface
-func NewController(informer cache.SharedIndexInformer, conf *config.Config, defaultClient client.ValiClient, l log.Logger) (Controller, error) {
+var _ TargetHandler = &controller{}
+
+// dynamicHostURLData is the data of the DynamicHostURL template.
+type dynamicHostURLData struct {
+	// Cluster is the name of the cluster, which is the technical id of the shoot.
+	Cluster string
+	// Shoot is the shoot of the cluster.
+	Shoot *gardenercorev1beta1.Shoot
+}
+
+// NewController return Controller interface which creates a client for each target of the source
+func NewController(source TargetSource, conf *config.Config, defaultClient client.ValiClient, l log.Logger) (Controller, error) {
 	var err error
 
 	ctl := &controller{
 		clients:       make(map[string]ControllerClient, expectedActiveClusters),
 		conf:          conf,
 		defaultClient: defaultClient,
-		informer:      informer,
+		source:        source,
 		logger:        l,
 	}
 
-	if ctl.r, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    ctl.addFunc,
-		DeleteFunc: ctl.delFunc,
-		UpdateFunc: ctl.updateFunc,
-	}); err != nil {
-		return nil, fmt.Errorf("failed to add event handler: %v", err)
+	if conf.ControllerConfig.DynamicHostURL != "" {
+		if ctl.urlTemplate, err = config.ParseTemplate("DynamicHostURL", conf.ControllerConfig.DynamicHostURL); err != nil {
+			return nil, fmt.Errorf("failed to parse DynamicHostURL: %v", err)
+		}
+	}
+
+	if ctl.targets, err = ctl.newRoutingTargetClients(); err != nil {
+		return nil, err
+	}
+
+	if err = source.Start(ctl); err != nil {
+		return nil, err
 	}
 
 	stopChan := make(chan struct{})
@@ -68,7 +87,7 @@ func NewController(informer cache.SharedIndexInformer, conf *config.Config, defa
 		close(stopChan)
 	})
 
-	if !cache.WaitForNamedCacheSync("controller", stopChan, informer.HasSynced) {
+	if !cache.WaitForNamedCacheSync("controller", stopChan, source.HasSynced) {
 		return nil, fmt.Errorf("failed to wait for caches to sync")
 	}
 
@@ -85,123 +104,88 @@ func (ctl *controller) Stop() {
 	if ctl.defaultClient != nil {
 		ctl.defaultClient.StopWait()
 	}
-
-	if ctl.informer == nil || ctl.r == nil {
-		return
+	for _, target := range ctl.targets {
+		target.StopWait()
 	}
 
-	if err := ctl.informer.RemoveEventHandler(ctl.r); err != nil {
-		_ = level.Error(ctl.logger).Log("msg", fmt.Sprintf("failed to remove event handler: %v", err))
+	if ctl.source != nil {
+		ctl.source.Stop()
 	}
 }
 
-// cluster informer callback
-func (ctl *controller) addFunc(obj interface{}) {
-	cluster, ok := obj.(*extensionsv1alpha1.Cluster)
-	if !ok {
-		metrics.Errors.WithLabelValues(metrics.ErrorAddFuncNotACluster).Inc()
-		_ = level.Error(ctl.logger).Log("msg", fmt.Sprintf("%v is not a cluster", obj))
-		return
-	}
-
-	shoot, err := extensioncontroller.ShootFromCluster(cluster)
-	if err != nil {
-		metrics.Errors.WithLabelValues(metrics.ErrorCanNotExtractShoot).Inc()
-		_ = level.Error(ctl.logger).Log("msg", fmt.Sprintf("can't extract shoot from cluster %v", cluster.Name))
-		return
-	}
-
-	if ctl.isAllowedShoot(shoot) && !ctl.isDeletedShoot(shoot) {
+// AddTarget creates a client for the target, unless it is a testing or deleted shoot.
+func (ctl *controller) AddTarget(target Target) {
+	if ctl.isAllowedTarget(target) && !ctl.isDeletedShoot(target.Shoot) {
 		_ = level.Debug(ctl.logger).Log(
 			"msg", "adding cluster",
-			"cluster", cluster.Name,
+			"cluster", target.Name,
 		)
-		ctl.createControllerClient(cluster.Name, shoot)
+		ctl.createControllerClient(target)
 	}
 }
 
-func (ctl *controller) updateFunc(oldObj interface{}, newObj interface{}) {
-	oldCluster, ok := oldObj.(*extensionsv1alpha1.Cluster)
-	if !ok {
-		metrics.Errors.WithLabelValues(metrics.ErrorUpdateFuncOldNotACluster).Inc()
-		_ = level.Error(ctl.logger).Log("msg", fmt.Sprintf("%v is not a cluster", oldCluster))
-		return
-	}
+// UpdateTarget updates the state of the client of the target. The targets with their own
+// endpoint get a new client, because their endpoint may have changed.
+func (ctl *controller) UpdateTarget(target Target) {
+	_ = level.Debug(ctl.logger).Log("msg", "reconciling", "cluster", target.Name)
 
-	newCluster, ok := newObj.(*extensionsv1alpha1.Cluster)
-	if !ok {
-		metrics.Errors.WithLabelValues(metrics.ErrorUpdateFuncNewNotACluster).Inc()
-		_ = level.Error(ctl.logger).Log("msg", fmt.Sprintf("%v is not a cluster", newCluster))
-		return
-	}
-
-	if bytes.Equal(oldCluster.Spec.Shoot.Raw, newCluster.Spec.Shoot.Raw) {
-		_ = level.Debug(ctl.logger).Log("msg", "reconciliation skipped, shoot is the same", "cluster", newCluster.Name)
-		return
-	}
-
-	shoot, err := extensioncontroller.ShootFromCluster(newCluster)
-	if err != nil {
-		metrics.Errors.WithLabelValues(metrics.ErrorCanNotExtractShoot).Inc()
-		_ = level.Error(ctl.logger).Log("msg", fmt.Sprintf("can't extract shoot from cluster %v", newCluster.Name))
-		return
-	}
-
-	_ = level.Debug(ctl.logger).Log("msg", "reconciling", "cluster", newCluster.Name)
-
-	_client, ok := ctl.clients[newCluster.Name]
+	_client, ok := ctl.clients[target.Name]
 	// The client exists in the list, so we need to update it.
 	if ok {
 		// The shoot is no longer applicable for logging
-		if !ctl.isAllowedShoot(shoot) {
-			ctl.deleteControllerClient(oldCluster.Name)
+		if !ctl.isAllowedTarget(target) {
+			ctl.deleteControllerClient(target.Name)
 			return
 		}
 		// Sanity check
 		if _client == nil {
 			_ = level.Error(ctl.logger).Log(
-				"msg", fmt.Sprintf("Nil client for cluster: %v, creating...", oldCluster.Name),
+				"msg", fmt.Sprintf("Nil client for cluster: %v, creating...", target.Name),
 			)
-			ctl.createControllerClient(newCluster.Name, shoot)
+			ctl.createControllerClient(target)
+			return
+		}
+		if target.URL != "" || ctl.endpointChanged(_client, target) {
+			ctl.recreateControllerClient(target)
 			return
 		}
 
-		ctl.updateControllerClientState(_client, shoot)
+		ctl.updateControllerClientState(_client, target.Shoot)
 	} else {
 		// The client does not exist. Try to create a new one, if the shoot is applicable for logging.
-		if ctl.isAllowedShoot(shoot) {
+		if ctl.isAllowedTarget(target) {
 			_ = level.Info(ctl.logger).Log(
 				"msg", "client is not found in controller, creating...",
-				"cluster", newCluster.Name,
+				"cluster", target.Name,
 			)
-			ctl.createControllerClient(newCluster.Name, shoot)
+			ctl.createControllerClient(target)
 		}
 	}
 }
 
-func (ctl *controller) delFunc(obj interface{}) {
-	cluster, ok := obj.(*extensionsv1alpha1.Cluster)
-	if !ok {
-		metrics.Errors.WithLabelValues(metrics.ErrorDeleteFuncNotAcluster).Inc()
-		_ = level.Error(ctl.logger).Log("msg", fmt.Sprintf("%v is not a cluster", obj))
-		return
-	}
-
-	ctl.deleteControllerClient(cluster.Name)
+// DeleteTarget deletes the client of the target.
+func (ctl *controller) DeleteTarget(name string) {
+	ctl.deleteControllerClient(name)
 }
 
 // updateClientConfig constructs the target URL and sets it in the client configuration
 // together with the queue name
-func (ctl *controller) updateClientConfig(clusterName string) *config.Config {
+func (ctl *controller) updateClientConfig(target Target) *config.Config {
 	var clientURL flagext.URLValue
+	clusterName := target.Name
 
-	suffix := ctl.conf.ControllerConfig.DynamicHostSuffix
-
-	// Construct the target URL: DynamicHostPrefix + clusterName + DynamicHostSuffix
-	url := fmt.Sprintf("%s%s%s", ctl.conf.ControllerConfig.DynamicHostPrefix, clusterName, suffix)
+	url, err := ctl.dynamicHostURL(target)
+	if err != nil {
+		metrics.Errors.WithLabelValues(metrics.ErrorFailedToParseURL).Inc()
+		_ = level.Error(ctl.logger).Log(
+			"msg",
+			fmt.Sprintf("failed to render DynamicHostURL for %v: %v", clusterName, err.Error()),
+		)
+		return nil
+	}
 	_ = level.Debug(ctl.logger).Log("msg", "set url", "url", url, "cluster", clusterName)
 
-	err := clientURL.Set(url)
+	err = clientURL.Set(url)
 	if err != nil {
 		metrics.Errors.WithLabelValues(metrics.ErrorFailedToParseURL).Inc()
 		_ = level.Error(ctl.logger).Log(
@@ -218,11 +202,107 @@ func (ctl *controller) updateClientConfig(clusterName string) *config.Config {
 	return &conf
 }
 
+// dynamicHostURL returns the endpoint of the target, renders the DynamicHostURL template of the cluster or,
+// without a template, returns DynamicHostPrefix + clusterName + DynamicHostSuffix.
+func (ctl *controller) dynamicHostURL(target Target) (string, error) {
+	if target.URL != "" {
+		return target.URL, nil
+	}
+	if endpoint := ctl.shootEndpoint(target.Shoot); endpoint != "" {
+		return endpoint, nil
+	}
+	if ctl.urlTemplate == nil {
+		return fmt.Sprintf("%s%s%s", ctl.conf.ControllerConfig.DynamicHostPrefix, target.Name, ctl.conf.ControllerConfig.DynamicHostSuffix), nil
+	}
+	buf := &bytes.Buffer{}
+	if err := ctl.urlTemplate.Execute(buf, dynamicHostURLData{Cluster: target.Name, Shoot: target.Shoot}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// endpointChanged reports if the endpoint annotation of the shoot or the DynamicHostURL template
+// rendered with the shoot changed the endpoint of the client.
+func (ctl *controller) endpointChanged(c ControllerClient, target Target) bool {
+	if ctl.urlTemplate == nil && !ctl.conf.ControllerConfig.AllowsShootAnnotation(config.ShootAnnotationEndpoint) {
+		return false
+	}
+	url, err := ctl.dynamicHostURL(target)
+	return err == nil && url != c.GetEndPoint()
+}
+
+// newRoutingTargetClients creates a client for each named target of the routing policy.
+// These clients are shared between all controller clients.
+func (ctl *controller) newRoutingTargetClients() (map[string]client.ValiClient, error) {
+	targets := make(map[string]client.ValiClient, len(ctl.conf.ControllerConfig.RoutingTargets))
+	for name, url := range ctl.conf.ControllerConfig.RoutingTargets {
+		var targetURL flagext.URLValue
+		if err := targetURL.Set(url); err != nil {
+			return nil, fmt.Errorf("failed to parse URL of routing target %s: %v", name, err)
+		}
+
+		conf := *ctl.conf
+		conf.ClientConfig.CredativValiConfig.URL = targetURL
+		conf.ClientConfig.BufferConfig.DqueConfig.QueueName = ctl.conf.ClientConfig.BufferConfig.DqueConfig.QueueName + "-" + name
+
+		c, err := client.NewClient(conf, ctl.logger, client.Options{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client for routing target %s: %v", name, err)
+		}
+		_ = level.Debug(ctl.logger).Log(
+			"msg", "routing target client created",
+			"target", name,
+			"url", c.GetEndPoint(),
+		)
+		targets[name] = c
+	}
+	return targets, nil
+}
+
+// SetRoutingPolicy replaces the routing policy of the controller and all of its clients.
+func (ctl *controller) SetRoutingPolicy(policy config.RoutingPolicy) {
+	ctl.lock.Lock()
+	defer ctl.lock.Unlock()
+
+	if ctl.isStopped() {
+		return
+	}
+
+	ctl.routing = policy
+	for _, c := range ctl.clients {
+		c.SetRoutingPolicy(policy)
+	}
+	_ = level.Info(ctl.logger).Log("msg", "routing policy updated", "clients", len(ctl.clients))
+}
+
+// routingPolicy returns the routing policy set at runtime or the configured one. When none is set
+// the policy is derived from the per state main and default client configurations.
+func (ctl *controller) routingPolicy() config.RoutingPolicy {
+	ctl.lock.RLock()
+	defer ctl.lock.RUnlock()
+
+	if ctl.routing != nil {
+		return ctl.routing
+	}
+	if ctl.conf.ControllerConfig.RoutingPolicy != nil {
+		return ctl.conf.ControllerConfig.RoutingPolicy
+	}
+	return config.NewRoutingPolicy(
+		ctl.conf.ControllerConfig.MainControllerClientConfig,
+		ctl.conf.ControllerConfig.DefaultControllerClientConfig,
+	)
+}
+
 // Shoots which are testing shoots should not be targeted for logging
 func (ctl *controller) isAllowedShoot(shoot *gardenercorev1beta1.Shoot) bool {
 	return !isTestingShoot(shoot)
 }
 
+// The targets which are not Gardener clusters are always allowed
+func (ctl *controller) isAllowedTarget(target Target) bool {
+	return target.Shoot == nil || ctl.isAllowedShoot(target.Shoot)
+}
+
 // Shoots in deleting state should not be targeted for logging
 func (ctl *controller) isDeletedShoot(shoot *gardenercorev1beta1.Shoot) bool {
 	return shoot != nil && shoot.DeletionTimestamp != nil
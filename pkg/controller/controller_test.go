@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"text/template"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/util/flagext"
@@ -55,6 +56,10 @@ func (c *fakeValiClient) StopWait() {
 
 func (c *fakeValiClient) SetState(state clusterState) {}
 
+func (c *fakeValiClient) SetRoutingPolicy(_ config.RoutingPolicy) {}
+
+func (c *fakeValiClient) SetOverrides(_ shootOverrides) {}
+
 func (c *fakeValiClient) GetState() clusterState {
 	return clusterStateReady
 }
@@ -100,10 +105,51 @@ var _ = Describe("Controller", func() {
 			Expect(shootDevTest2.isStopped).To(BeTrue())
 		})
 	})
+	Describe("#updateClientConfig", func() {
+		shoot := &gardencorev1beta1.Shoot{
+			ObjectMeta: v1.ObjectMeta{Name: "logging", Namespace: "garden-dev"},
+			Spec:       gardencorev1beta1.ShootSpec{Region: "eu-west-1"},
+		}
+
+		DescribeTable("#updateClientConfig",
+			func(urlTemplate string, shoot *gardencorev1beta1.Shoot, want string) {
+				ctl := &controller{
+					conf: &config.Config{
+						ControllerConfig: config.ControllerConfig{
+							DynamicHostPrefix: "http://vali.",
+							DynamicHostSuffix: ".svc:3100/vali/api/v1/push",
+						},
+					},
+					logger: log.NewNopLogger(),
+				}
+				if urlTemplate != "" {
+					ctl.urlTemplate = template.Must(config.ParseTemplate("DynamicHostURL", urlTemplate))
+				}
+
+				conf := ctl.updateClientConfig(Target{Name: "shoot--dev--logging", Shoot: shoot})
+				if want == "" {
+					Expect(conf).To(BeNil())
+					return
+				}
+				Expect(conf).ToNot(BeNil())
+				Expect(conf.ClientConfig.CredativValiConfig.URL.String()).To(Equal(want))
+				Expect(conf.ClientConfig.BufferConfig.DqueConfig.QueueName).To(Equal("shoot--dev--logging"))
+			},
+			Entry("prefix and suffix", "", shoot, "http://vali.shoot--dev--logging.svc:3100/vali/api/v1/push"),
+			Entry("template with the cluster name", "http://logging.{{.Cluster}}.svc:3100/vali/api/v1/push", shoot,
+				"http://logging.shoot--dev--logging.svc:3100/vali/api/v1/push"),
+			Entry("template with shoot fields", "http://vali-{{.Shoot.Spec.Region}}.{{.Cluster}}.svc/{{.Shoot.Namespace}}/push", shoot,
+				"http://vali-eu-west-1.shoot--dev--logging.svc/garden-dev/push"),
+			Entry("template with fields of a missing shoot", "http://{{.Shoot.Spec.Region}}.svc", nil, ""),
+			Entry("template which renders an invalid URL", "http://{{.Cluster}}:port", shoot, ""),
+		)
+	})
+
 	Describe("Event functions", func() {
 		var (
 			conf     *config.Config
 			ctl      *controller
+			source   *clusterSource
 			logLevel logging.Level
 		)
 		defaultURL := flagext.URLValue{}
@@ -191,17 +237,18 @@ var _ = Describe("Controller", func() {
 				conf:    conf,
 				logger:  logger,
 			}
+			source = &clusterSource{handler: ctl, logger: logger}
 		})
 
 		Context("#addFunc", func() {
 			It("Should add new client for a cluster with evaluation purpose", func() {
-				ctl.addFunc(developmentCluster)
+				source.addFunc(developmentCluster)
 				c, ok := ctl.clients[shootName]
 				Expect(c).ToNot(BeNil())
 				Expect(ok).To(BeTrue())
 			})
 			It("Should not add new client for a cluster with testing purpose", func() {
-				ctl.addFunc(testingCluster)
+				source.addFunc(testingCluster)
 				c, ok := ctl.clients[shootName]
 				Expect(c).To(BeNil())
 				Expect(ok).To(BeFalse())
@@ -210,8 +257,8 @@ var _ = Describe("Controller", func() {
 				name := "new-shoot-name"
 				newNameCluster := hibernatedCluster.DeepCopy()
 				newNameCluster.Name = name
-				ctl.addFunc(hibernatedCluster)
-				ctl.addFunc(newNameCluster)
+				source.addFunc(hibernatedCluster)
+				source.addFunc(newNameCluster)
 				Expect(ctl.conf.ClientConfig.CredativValiConfig.URL.String()).ToNot(Equal(ctl.conf.ControllerConfig.DynamicHostPrefix + name + ctl.conf.ControllerConfig.DynamicHostSuffix))
 				Expect(ctl.conf.ClientConfig.CredativValiConfig.URL.String()).ToNot(Equal(ctl.conf.ControllerConfig.DynamicHostPrefix + hibernatedCluster.Name + ctl.conf.ControllerConfig.DynamicHostSuffix))
 			})
@@ -227,7 +274,7 @@ var _ = Describe("Controller", func() {
 
 			DescribeTable("#updateFunc", func(a args) {
 				ctl.clients = a.clients
-				ctl.updateFunc(a.oldCluster, a.newCluster)
+				source.updateFunc(a.oldCluster, a.newCluster)
 				c, ok := ctl.clients[a.newCluster.Name]
 				if a.shouldClientExists {
 					Expect(c).ToNot(BeNil())
@@ -293,7 +340,7 @@ var _ = Describe("Controller", func() {
 		Context("#deleteFunc", func() {
 			It("should delete cluster client when cluster is deleted", func() {
 				ctl.clients[shootName] = &fakeValiClient{}
-				ctl.delFunc(developmentCluster)
+				source.delFunc(developmentCluster)
 				c, ok := ctl.clients[shootName]
 				Expect(c).To(BeNil())
 				Expect(ok).To(BeFalse())
@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+// fileSource provides the targets of a json file which maps their names to their endpoints.
+// An empty endpoint is rendered from the DynamicHostURL or the DynamicHostPrefix and DynamicHostSuffix.
+// The directory of the file is watched, because ConfigMap volumes replace the file through a symlink swap.
+type fileSource struct {
+	file    string
+	hash    [sha256.Size]byte
+	targets map[string]string
+	handler TargetHandler
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+	synced  atomic.Bool
+	logger  log.Logger
+}
+
+var _ TargetSource = &fileSource{}
+
+// NewFileSource returns a TargetSource watching the targets in the json file.
+func NewFileSource(file string, logger log.Logger) TargetSource {
+	return &fileSource{file: file, logger: log.With(logger, "component", "target-file")}
+}
+
+func (s *fileSource) Start(handler TargetHandler) error {
+	targets, hash, err := readTargetFile(s.file)
+	if err != nil {
+		return err
+	}
+	if s.watcher, err = fsnotify.NewWatcher(); err != nil {
+		return fmt.Errorf("failed to create file watcher: %v", err)
+	}
+	if err = s.watcher.Add(filepath.Dir(s.file)); err != nil {
+		_ = s.watcher.Close()
+		return fmt.Errorf("failed to watch %s: %v", s.file, err)
+	}
+
+	s.handler = handler
+	s.done = make(chan struct{})
+	s.hash = hash
+	s.apply(targets)
+	s.synced.Store(true)
+	go s.run()
+	return nil
+}
+
+func (s *fileSource) HasSynced() bool {
+	return s.synced.Load()
+}
+
+// Stop stops watching the file without waiting for a pending update of the targets.
+func (s *fileSource) Stop() {
+	if s.done == nil {
+		return
+	}
+	close(s.done)
+	_ = s.watcher.Close()
+}
+
+func (s *fileSource) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			s.reload()
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			_ = level.Error(s.logger).Log("msg", "file watcher error", "err", err)
+		}
+	}
+}
+
+// reload applies the targets of the file when its content changed. An invalid file keeps the current targets.
+func (s *fileSource) reload() {
+	targets, hash, err := readTargetFile(s.file)
+	if err != nil {
+		// The file may be missing for a moment while it is being replaced.
+		if !os.IsNotExist(err) {
+			metrics.Errors.WithLabelValues(metrics.ErrorTargetFile).Inc()
+			_ = level.Error(s.logger).Log("msg", "invalid target file, keeping the current targets", "err", err)
+		}
+		return
+	}
+	if hash == s.hash {
+		return
+	}
+	s.hash = hash
+	s.apply(targets)
+	_ = level.Info(s.logger).Log("msg", "targets reloaded", "file", s.file, "targets", len(targets))
+}
+
+// apply passes the added, changed and removed targets to the handler.
+func (s *fileSource) apply(targets map[string]string) {
+	for name, url := range targets {
+		old, ok := s.targets[name]
+		switch {
+		case !ok:
+			s.handler.AddTarget(Target{Name: name, URL: url})
+		case old != url:
+			s.handler.UpdateTarget(Target{Name: name, URL: url})
+		}
+	}
+	for name := range s.targets {
+		if _, ok := targets[name]; !ok {
+			s.handler.DeleteTarget(name)
+		}
+	}
+	s.targets = targets
+}
+
+func readTargetFile(file string) (map[string]string, [sha256.Size]byte, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, [sha256.Size]byte{}, err
+	}
+	targets := map[string]string{}
+	if err := json.Unmarshal(content, &targets); err != nil {
+		return nil, [sha256.Size]byte{}, fmt.Errorf("failed to Unmarshal target file %s: %v", file, err)
+	}
+	for name := range targets {
+		if name == "" {
+			return nil, [sha256.Size]byte{}, fmt.Errorf("target file %s has a target without name", file)
+		}
+	}
+	return targets, sha256.Sum256(content), nil
+}
@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+// EndpointAnnotation is the annotation of a namespace with the Vali endpoint of its logs.
+const EndpointAnnotation = "logging.gardener.cloud/endpoint"
+
+// namespaceSource provides the namespaces with the EndpointAnnotation as targets.
+type namespaceSource struct {
+	informer cache.SharedIndexInformer
+	r        cache.ResourceEventHandlerRegistration
+	handler  TargetHandler
+	logger   log.Logger
+}
+
+var _ TargetSource = &namespaceSource{}
+
+// NewNamespaceSource returns a TargetSource watching the corev1.Namespace resources of the informer.
+func NewNamespaceSource(informer cache.SharedIndexInformer, logger log.Logger) TargetSource {
+	return &namespaceSource{informer: informer, logger: logger}
+}
+
+func (s *namespaceSource) Start(handler TargetHandler) error {
+	s.handler = handler
+	var err error
+	if s.r, err = s.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    s.addFunc,
+		DeleteFunc: s.delFunc,
+		UpdateFunc: s.updateFunc,
+	}); err != nil {
+		return fmt.Errorf("failed to add event handler: %v", err)
+	}
+	return nil
+}
+
+func (s *namespaceSource) HasSynced() bool {
+	return s.informer.HasSynced()
+}
+
+func (s *namespaceSource) Stop() {
+	if s.informer == nil || s.r == nil {
+		return
+	}
+
+	if err := s.informer.RemoveEventHandler(s.r); err != nil {
+		_ = level.Error(s.logger).Log("msg", fmt.Sprintf("failed to remove event handler: %v", err))
+	}
+}
+
+// namespace informer callback
+func (s *namespaceSource) addFunc(obj interface{}) {
+	namespace, ok := s.namespace(obj)
+	if !ok {
+		return
+	}
+	if endpoint := namespace.Annotations[EndpointAnnotation]; endpoint != "" {
+		s.handler.AddTarget(Target{Name: namespace.Name, URL: endpoint})
+	}
+}
+
+func (s *namespaceSource) updateFunc(oldObj interface{}, newObj interface{}) {
+	oldNamespace, ok := s.namespace(oldObj)
+	if !ok {
+		return
+	}
+	newNamespace, ok := s.namespace(newObj)
+	if !ok {
+		return
+	}
+
+	oldEndpoint, newEndpoint := oldNamespace.Annotations[EndpointAnnotation], newNamespace.Annotations[EndpointAnnotation]
+	switch {
+	case oldEndpoint == newEndpoint:
+		_ = level.Debug(s.logger).Log("msg", "reconciliation skipped, endpoint is the same", "namespace", newNamespace.Name)
+	case newEndpoint == "":
+		s.handler.DeleteTarget(newNamespace.Name)
+	default:
+		s.handler.UpdateTarget(Target{Name: newNamespace.Name, URL: newEndpoint})
+	}
+}
+
+func (s *namespaceSource) delFunc(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	namespace, ok := s.namespace(obj)
+	if !ok {
+		return
+	}
+	s.handler.DeleteTarget(namespace.Name)
+}
+
+func (s *namespaceSource) namespace(obj interface{}) (*corev1.Namespace, bool) {
+	namespace, ok := obj.(*corev1.Namespace)
+	if !ok {
+		metrics.Errors.WithLabelValues(metrics.ErrorNotANamespace).Inc()
+		_ = level.Error(s.logger).Log("msg", fmt.Sprintf("%v is not a namespace", obj))
+	}
+	return namespace, ok
+}
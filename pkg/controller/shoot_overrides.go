@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	gardenercorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+// shootOverrides override the route of the state of a controller client.
+// A nil sendToMain or sendToDefault keeps the decision of the route.
+type shootOverrides struct {
+	sendToMain    *bool
+	sendToDefault *bool
+	labels        model.LabelSet
+}
+
+func (o shootOverrides) equal(other shootOverrides) bool {
+	return equalFlag(o.sendToMain, other.sendToMain) &&
+		equalFlag(o.sendToDefault, other.sendToDefault) &&
+		o.labels.Equal(other.labels)
+}
+
+func equalFlag(a, b *bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// shootOverrides returns the overrides of the allowed annotations of the shoot.
+// Invalid annotations are ignored.
+func (ctl *controller) shootOverrides(shoot *gardenercorev1beta1.Shoot) shootOverrides {
+	var res shootOverrides
+	if shoot == nil || len(ctl.conf.ControllerConfig.ShootAnnotations) == 0 {
+		return res
+	}
+
+	res.sendToMain = ctl.shootFlag(shoot, config.ShootAnnotationSendToMain)
+	res.sendToDefault = ctl.shootFlag(shoot, config.ShootAnnotationSendToDefault)
+	if value, ok := ctl.shootAnnotation(shoot, config.ShootAnnotationExtraLabels); ok && value != "" {
+		labels, err := config.ParseExtraLabels(value)
+		if err != nil {
+			ctl.invalidShootAnnotation(shoot, config.ShootAnnotationExtraLabels, err)
+		} else {
+			res.labels = labels
+		}
+	}
+	return res
+}
+
+// shootEndpoint returns the allowed endpoint annotation of the shoot.
+func (ctl *controller) shootEndpoint(shoot *gardenercorev1beta1.Shoot) string {
+	if shoot == nil {
+		return ""
+	}
+	endpoint, _ := ctl.shootAnnotation(shoot, config.ShootAnnotationEndpoint)
+	return endpoint
+}
+
+func (ctl *controller) shootAnnotation(shoot *gardenercorev1beta1.Shoot, name string) (string, bool) {
+	if !ctl.conf.ControllerConfig.AllowsShootAnnotation(name) {
+		return "", false
+	}
+	value, ok := shoot.Annotations[config.ShootAnnotationPrefix+name]
+	return value, ok
+}
+
+func (ctl *controller) shootFlag(shoot *gardenercorev1beta1.Shoot, name string) *bool {
+	value, ok := ctl.shootAnnotation(shoot, name)
+	if !ok {
+		return nil
+	}
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		ctl.invalidShootAnnotation(shoot, name, err)
+		return nil
+	}
+	return &flag
+}
+
+func (ctl *controller) invalidShootAnnotation(shoot *gardenercorev1beta1.Shoot, name string, err error) {
+	metrics.Errors.WithLabelValues(metrics.ErrorShootAnnotation).Inc()
+	_ = level.Error(ctl.logger).Log(
+		"msg", fmt.Sprintf("invalid %s%s annotation of shoot %s/%s, it is ignored", config.ShootAnnotationPrefix, name, shoot.Namespace, shoot.Name),
+		"err", err,
+	)
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"time"
+
+	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+	"github.com/go-kit/log"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/model"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/gardener/logging/pkg/client"
+	"github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("Shoot overrides", func() {
+	var ctl *controller
+
+	shoot := func(extraLabels string) *gardencorev1beta1.Shoot {
+		return &gardencorev1beta1.Shoot{ObjectMeta: v1.ObjectMeta{
+			Name:        "logging",
+			Namespace:   "garden-dev",
+			Annotations: map[string]string{config.ShootAnnotationPrefix + config.ShootAnnotationExtraLabels: extraLabels},
+		}}
+	}
+
+	BeforeEach(func() {
+		ctl = &controller{
+			conf: &config.Config{ControllerConfig: config.ControllerConfig{
+				ShootAnnotations: []string{config.ShootAnnotationExtraLabels},
+			}},
+			logger: log.NewNopLogger(),
+		}
+	})
+
+	It("should parse the extra labels", func() {
+		Expect(ctl.shootOverrides(shoot("team=logging, tier=backend")).labels).To(Equal(model.LabelSet{"team": "logging", "tier": "backend"}))
+	})
+
+	It("should ignore the extra labels with a reserved name", func() {
+		Expect(ctl.shootOverrides(shoot("team=logging,__tenant_id__=other")).labels).To(BeNil())
+		Expect(ctl.shootOverrides(shoot("__gardener_multitenant_id__=other")).labels).To(BeNil())
+	})
+
+	It("should not overwrite the labels of the stream and the route", func() {
+		c := &controllerClient{
+			mainClient: &client.FakeValiClient{},
+			state:      clusterStateReady,
+			routing: config.RoutingPolicy{string(clusterStateReady): {
+				Targets: []string{config.MainRoutingTarget},
+				Labels:  model.LabelSet{"state": "ready"},
+			}},
+			logger: log.NewNopLogger(),
+		}
+		c.SetOverrides(ctl.shootOverrides(shoot("namespace=other,state=other,team=logging")))
+
+		Expect(c.Handle(model.LabelSet{"namespace": "shoot--dev--logging"}, time.Now(), "line")).To(Succeed())
+		Expect(c.mainClient.(*client.FakeValiClient).Entries[0].Labels).To(Equal(model.LabelSet{
+			"namespace": "shoot--dev--logging", "state": "ready", "team": "logging",
+		}))
+	})
+})
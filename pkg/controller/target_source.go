@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	gardenercorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+)
+
+// Target is a dynamic target of the controller. The records whose dynamic host
+// matches the name of a target are sent to the Vali of the target.
+type Target struct {
+	// Name is the name of the target, e.g. the cluster or the namespace.
+	Name string
+	// URL is the endpoint of the target. Without it the endpoint is rendered
+	// from the DynamicHostURL or the DynamicHostPrefix and DynamicHostSuffix.
+	URL string
+	// Shoot is the shoot of a Gardener cluster, whose state governs the routing.
+	// The other targets are always in the ready state.
+	Shoot *gardenercorev1beta1.Shoot
+}
+
+// TargetHandler handles the targets of a TargetSource.
+type TargetHandler interface {
+	// AddTarget adds a new target.
+	AddTarget(target Target)
+	// UpdateTarget updates a changed target, or adds it if it is not known yet.
+	UpdateTarget(target Target)
+	// DeleteTarget deletes the target with the name.
+	DeleteTarget(name string)
+}
+
+// TargetSource provides the dynamic targets of the controller.
+type TargetSource interface {
+	// Start passes the current and the future targets to the handler.
+	Start(handler TargetHandler) error
+	// HasSynced returns true once the handler received the current targets.
+	HasSynced() bool
+	// Stop stops passing targets to the handler.
+	Stop()
+}
@@ -0,0 +1,285 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	valiclient "github.com/credativ/vali/pkg/valitail/client"
+	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+	"github.com/go-kit/log"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+// targetRecorder records the target events of a source.
+type targetRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+var _ TargetHandler = &targetRecorder{}
+
+func (r *targetRecorder) AddTarget(target Target) {
+	r.record("add " + target.Name + " " + target.URL)
+}
+
+func (r *targetRecorder) UpdateTarget(target Target) {
+	r.record("update " + target.Name + " " + target.URL)
+}
+
+func (r *targetRecorder) DeleteTarget(name string) {
+	r.record("delete " + name)
+}
+
+func (r *targetRecorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *targetRecorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+var _ = Describe("TargetSource", func() {
+	namespace := func(name, endpoint string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: name}}
+		if endpoint != "" {
+			ns.Annotations = map[string]string{EndpointAnnotation: endpoint}
+		}
+		return ns
+	}
+
+	Describe("#namespaceSource", func() {
+		var (
+			recorder *targetRecorder
+			source   *namespaceSource
+		)
+
+		BeforeEach(func() {
+			recorder = &targetRecorder{}
+			source = &namespaceSource{handler: recorder, logger: log.NewNopLogger()}
+		})
+
+		It("should add the annotated namespaces only", func() {
+			source.addFunc(namespace("team-a", "http://vali.team-a.svc:3100/vali/api/v1/push"))
+			source.addFunc(namespace("team-b", ""))
+			source.addFunc("not a namespace")
+			Expect(recorder.recorded()).To(Equal([]string{"add team-a http://vali.team-a.svc:3100/vali/api/v1/push"}))
+		})
+
+		It("should follow the changes of the annotation", func() {
+			source.updateFunc(namespace("team-a", ""), namespace("team-a", "http://a"))
+			source.updateFunc(namespace("team-a", "http://a"), namespace("team-a", "http://a"))
+			source.updateFunc(namespace("team-a", "http://a"), namespace("team-a", "http://b"))
+			source.updateFunc(namespace("team-a", "http://b"), namespace("team-a", ""))
+			source.updateFunc(namespace("team-b", ""), namespace("team-b", ""))
+			Expect(recorder.recorded()).To(Equal([]string{"update team-a http://a", "update team-a http://b", "delete team-a"}))
+		})
+
+		It("should delete the deleted namespaces", func() {
+			source.delFunc(namespace("team-a", "http://a"))
+			source.delFunc(cache.DeletedFinalStateUnknown{Key: "team-b", Obj: namespace("team-b", "http://b")})
+			Expect(recorder.recorded()).To(Equal([]string{"delete team-a", "delete team-b"}))
+		})
+	})
+
+	Describe("#fileSource", func() {
+		var (
+			dir      string
+			file     string
+			recorder *targetRecorder
+		)
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			file = filepath.Join(dir, "targets.json")
+			recorder = &targetRecorder{}
+		})
+
+		It("should fail to start with an invalid file", func() {
+			Expect(os.WriteFile(file, []byte(`["team-a"]`), 0o600)).To(Succeed())
+			Expect(NewFileSource(file, log.NewNopLogger()).Start(recorder)).ToNot(Succeed())
+			Expect(NewFileSource(filepath.Join(dir, "missing.json"), log.NewNopLogger()).Start(recorder)).ToNot(Succeed())
+		})
+
+		It("should pass the added, changed and removed targets", func() {
+			Expect(os.WriteFile(file, []byte(`{"team-a": "http://a", "team-b": "http://b"}`), 0o600)).To(Succeed())
+			source := NewFileSource(file, log.NewNopLogger())
+			Expect(source.Start(recorder)).To(Succeed())
+			defer source.Stop()
+			Expect(source.HasSynced()).To(BeTrue())
+			Expect(recorder.recorded()).To(ConsistOf("add team-a http://a", "add team-b http://b"))
+
+			// An invalid file keeps the current targets.
+			Expect(os.WriteFile(file, []byte(`{"team-a": `), 0o600)).To(Succeed())
+			Consistently(recorder.recorded, 200*time.Millisecond).Should(HaveLen(2))
+
+			Expect(os.WriteFile(file, []byte(`{"team-a": "http://c", "team-c": ""}`), 0o600)).To(Succeed())
+			Eventually(recorder.recorded).Should(HaveLen(5))
+			Expect(recorder.recorded()[2:]).To(ConsistOf("update team-a http://c", "add team-c ", "delete team-b"))
+		})
+	})
+
+	Describe("#controller", func() {
+		var ctl *controller
+
+		BeforeEach(func() {
+			defaultURL := flagext.URLValue{}
+			_ = defaultURL.Set("http://vali.garden.svc:3100/vali/api/v1/push")
+			ctl = &controller{
+				clients: make(map[string]ControllerClient),
+				conf: &config.Config{
+					ClientConfig: config.ClientConfig{
+						CredativValiConfig: valiclient.Config{URL: defaultURL, BatchWait: 5 * time.Second, BatchSize: 1024 * 1024},
+						BufferConfig:       config.DefaultBufferConfig,
+					},
+					ControllerConfig: config.ControllerConfig{
+						DynamicHostPrefix: "http://vali.",
+						DynamicHostSuffix: ".svc:3100/vali/api/v1/push",
+					},
+				},
+				logger: log.NewNopLogger(),
+			}
+		})
+
+		AfterEach(func() {
+			ctl.Stop()
+		})
+
+		It("should send the logs of a target without shoot to its endpoint in the ready state", func() {
+			ctl.AddTarget(Target{Name: "team-a", URL: "http://vali.team-a.svc:3100/vali/api/v1/push"})
+			c, ok := ctl.clients["team-a"]
+			Expect(ok).To(BeTrue())
+			Expect(c.GetEndPoint()).To(Equal("http://vali.team-a.svc:3100/vali/api/v1/push"))
+			Expect(c.GetState()).To(Equal(clusterStateReady))
+		})
+
+		It("should render the endpoint of a target without URL", func() {
+			ctl.AddTarget(Target{Name: "team-a"})
+			Expect(ctl.clients["team-a"].GetEndPoint()).To(Equal("http://vali.team-a.svc:3100/vali/api/v1/push"))
+		})
+
+		It("should replace the client when the endpoint changes", func() {
+			ctl.AddTarget(Target{Name: "team-a", URL: "http://a.svc:3100/vali/api/v1/push"})
+			ctl.UpdateTarget(Target{Name: "team-a", URL: "http://b.svc:3100/vali/api/v1/push"})
+			Expect(ctl.clients["team-a"].GetEndPoint()).To(Equal("http://b.svc:3100/vali/api/v1/push"))
+
+			ctl.DeleteTarget("team-a")
+			Expect(ctl.clients).ToNot(HaveKey("team-a"))
+		})
+
+		It("should replace the client when the shoot changes the rendered DynamicHostURL", func() {
+			ctl.urlTemplate = template.Must(config.ParseTemplate("DynamicHostURL", "http://vali-{{.Shoot.Spec.Region}}.{{.Cluster}}.svc/push"))
+			shoot := func(region string) *gardencorev1beta1.Shoot {
+				return &gardencorev1beta1.Shoot{Spec: gardencorev1beta1.ShootSpec{Region: region}}
+			}
+
+			ctl.AddTarget(Target{Name: "shoot--dev--logging", Shoot: shoot("eu-west-1")})
+			c := ctl.clients["shoot--dev--logging"]
+			ctl.UpdateTarget(Target{Name: "shoot--dev--logging", Shoot: shoot("eu-west-1")})
+			Expect(ctl.clients["shoot--dev--logging"]).To(BeIdenticalTo(c))
+
+			ctl.UpdateTarget(Target{Name: "shoot--dev--logging", Shoot: shoot("eu-central-1")})
+			Expect(ctl.clients["shoot--dev--logging"].GetEndPoint()).To(Equal("http://vali-eu-central-1.shoot--dev--logging.svc/push"))
+		})
+
+		It("should replace the client of a buffered target when the endpoint changes", func() {
+			ctl.conf.ClientConfig.BufferConfig.Buffer = true
+			ctl.conf.ClientConfig.BufferConfig.DqueConfig.QueueDir = GinkgoT().TempDir()
+
+			ctl.AddTarget(Target{Name: "team-a", URL: "http://a.svc:3100/vali/api/v1/push"})
+			Expect(ctl.clients["team-a"].GetEndPoint()).To(Equal("http://a.svc:3100/vali/api/v1/push"))
+			ctl.UpdateTarget(Target{Name: "team-a", URL: "http://b.svc:3100/vali/api/v1/push"})
+			Expect(ctl.clients).To(HaveKey("team-a"))
+			Expect(ctl.clients["team-a"].GetEndPoint()).To(Equal("http://b.svc:3100/vali/api/v1/push"))
+		})
+
+		Context("with shoot annotations", func() {
+			shoot := func(annotations map[string]string) *gardencorev1beta1.Shoot {
+				return &gardencorev1beta1.Shoot{ObjectMeta: v1.ObjectMeta{Name: "logging", Namespace: "garden-dev", Annotations: annotations}}
+			}
+
+			BeforeEach(func() {
+				ctl.conf.ControllerConfig.ShootAnnotations = []string{
+					config.ShootAnnotationSendToMain, config.ShootAnnotationSendToDefault, config.ShootAnnotationExtraLabels, config.ShootAnnotationEndpoint,
+				}
+				ctl.conf.ControllerConfig.MainControllerClientConfig = config.MainControllerClientConfig
+				ctl.conf.ControllerConfig.DefaultControllerClientConfig = config.DefaultControllerClientConfig
+				ctl.defaultClient = &fakeValiClient{}
+			})
+
+			It("should apply the overrides on every update", func() {
+				ctl.AddTarget(Target{Name: "shoot--dev--logging", Shoot: shoot(map[string]string{
+					config.ShootAnnotationPrefix + config.ShootAnnotationSendToMain:    "false",
+					config.ShootAnnotationPrefix + config.ShootAnnotationSendToDefault: "true",
+					config.ShootAnnotationPrefix + config.ShootAnnotationExtraLabels:   "team=logging",
+				})})
+				c := ctl.clients["shoot--dev--logging"].(*controllerClient)
+				Expect(c.muteMainClient).To(BeTrue())
+				Expect(c.muteDefaultClient).To(BeFalse())
+				Expect(c.overrides.labels).To(Equal(model.LabelSet{"team": "logging"}))
+
+				ctl.UpdateTarget(Target{Name: "shoot--dev--logging", Shoot: shoot(map[string]string{
+					config.ShootAnnotationPrefix + config.ShootAnnotationSendToMain:  "invalid",
+					config.ShootAnnotationPrefix + config.ShootAnnotationExtraLabels: "team",
+				})})
+				// The invalid annotations are ignored, so the route of the creation state applies again.
+				route, _ := c.routing.Route(string(clusterStateCreation))
+				Expect(ctl.clients["shoot--dev--logging"]).To(BeIdenticalTo(c))
+				Expect(c.muteMainClient).To(Equal(!route.SendsTo(config.MainRoutingTarget)))
+				Expect(c.muteDefaultClient).To(Equal(!route.SendsTo(config.DefaultRoutingTarget)))
+				Expect(c.overrides.labels).To(BeEmpty())
+			})
+
+			It("should ignore the annotations which are not allowed", func() {
+				ctl.conf.ControllerConfig.ShootAnnotations = []string{config.ShootAnnotationExtraLabels}
+				ctl.AddTarget(Target{Name: "shoot--dev--logging", Shoot: shoot(map[string]string{
+					config.ShootAnnotationPrefix + config.ShootAnnotationSendToMain: "false",
+					config.ShootAnnotationPrefix + config.ShootAnnotationEndpoint:   "http://other.svc:3100/vali/api/v1/push",
+				})})
+				c := ctl.clients["shoot--dev--logging"].(*controllerClient)
+				Expect(c.muteMainClient).To(BeFalse())
+				Expect(c.GetEndPoint()).To(Equal("http://vali.shoot--dev--logging.svc:3100/vali/api/v1/push"))
+			})
+
+			It("should replace the client when the endpoint annotation changes", func() {
+				ctl.AddTarget(Target{Name: "shoot--dev--logging", Shoot: shoot(map[string]string{
+					config.ShootAnnotationPrefix + config.ShootAnnotationEndpoint: "http://a.svc:3100/vali/api/v1/push",
+				})})
+				Expect(ctl.clients["shoot--dev--logging"].GetEndPoint()).To(Equal("http://a.svc:3100/vali/api/v1/push"))
+
+				ctl.UpdateTarget(Target{Name: "shoot--dev--logging", Shoot: shoot(nil)})
+				Expect(ctl.clients["shoot--dev--logging"].GetEndPoint()).To(Equal("http://vali.shoot--dev--logging.svc:3100/vali/api/v1/push"))
+			})
+
+			It("should replace the buffered client when the endpoint annotation changes", func() {
+				ctl.conf.ClientConfig.BufferConfig.Buffer = true
+				ctl.conf.ClientConfig.BufferConfig.DqueConfig.QueueDir = GinkgoT().TempDir()
+
+				ctl.AddTarget(Target{Name: "shoot--dev--logging", Shoot: shoot(nil)})
+				ctl.UpdateTarget(Target{Name: "shoot--dev--logging", Shoot: shoot(map[string]string{
+					config.ShootAnnotationPrefix + config.ShootAnnotationEndpoint: "http://a.svc:3100/vali/api/v1/push",
+				})})
+				Expect(ctl.clients).To(HaveKey("shoot--dev--logging"))
+				Expect(ctl.clients["shoot--dev--logging"].GetEndPoint()).To(Equal("http://a.svc:3100/vali/api/v1/push"))
+			})
+		})
+	})
+})
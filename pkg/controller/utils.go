@@ -49,7 +49,10 @@ func isShootInCreation(shoot *gardencorev1beta1.Shoot) bool {
 
 func getShootState(shoot *gardencorev1beta1.Shoot) clusterState {
 	switch {
-	case shoot != nil && shoot.DeletionTimestamp != nil:
+	// The targets which are not Gardener clusters have no shoot and are always ready.
+	case shoot == nil:
+		return clusterStateReady
+	case shoot.DeletionTimestamp != nil:
 		return clusterStateDeletion
 	case isShootMarkedForMigration(shoot) || isShootInMigration(shoot):
 		return clusterStateMigration
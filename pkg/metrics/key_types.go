@@ -24,6 +24,29 @@ const (
 	ErrorK8sLabelsNotFound            = "K8sLabelsNotFound"
 	ErrorCreateLine                   = "CreateLine"
 	ErrorSendRecordToVali             = "SendRecordToVali"
+	ErrorProcessRecord                = "ProcessRecord"
+	ErrorPodAnnotation                = "PodAnnotation"
+	ErrorLogMetric                    = "LogMetric"
+	ErrorNotANamespace                = "NotANamespace"
+	ErrorTargetFile                   = "TargetFile"
+	ErrorShootAnnotation              = "ShootAnnotation"
 
 	MissingMetadataType = "Kubernetes"
+
+	ReloadSuccess = "success"
+	ReloadFailure = "failure"
+
+	MultilineFlushNewEntry = "new_entry"
+	MultilineFlushTimeout  = "timeout"
+	MultilineFlushMaxLines = "max_lines"
+	MultilineFlushMaxBytes = "max_bytes"
+	MultilineFlushClose    = "close"
+
+	TimestampMissing = "missing"
+	TimestampInvalid = "invalid"
+	TimestampSkew    = "skew"
+
+	PodMetadataCacheHit = "cache_hit"
+	PodMetadataInformer = "informer"
+	PodMetadataNotFound = "not_found"
 )
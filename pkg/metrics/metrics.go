@@ -9,6 +9,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// LogMetricsNamespace is the namespace of the metrics derived from the log lines.
+const LogMetricsNamespace = "fluentbit_vali_gardener_log"
+
 var (
 	namespace = "fluentbit_vali_gardener"
 
@@ -53,4 +56,88 @@ var (
 		Name:      "dropped_logs_total",
 		Help:      "Total number of dropped logs by the output plugin",
 	}, []string{"host"})
+
+	// ConfigReloads is a prometheus metric which keeps the number of configuration reloads
+	ConfigReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reloads_total",
+		Help:      "Total number of the configuration reloads by result",
+	}, []string{"result"})
+
+	// Redactions is a prometheus metric which keeps the number of redacted secrets per rule
+	Redactions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redactions_total",
+		Help:      "Total number of the secrets redacted from the logs by rule",
+	}, []string{"rule"})
+
+	// MultilineFlushes is a prometheus metric which keeps the number of joined multiline entries by the reason they were sent
+	MultilineFlushes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "multiline_flushes_total",
+		Help:      "Total number of the joined multiline entries by flush reason",
+	}, []string{"reason"})
+
+	// MultilineDroppedEntries is a prometheus metric which keeps the number of held back multiline entries which failed to be sent
+	MultilineDroppedEntries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "multiline_dropped_entries_total",
+		Help:      "Total number of the multiline entries which failed to be sent after their chunk was acknowledged",
+	})
+
+	// LogsDroppedBySeverity is a prometheus metric which keeps the number of logs dropped below the level threshold
+	LogsDroppedBySeverity = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logs_dropped_by_severity_total",
+		Help:      "Total number of the logs dropped because their level is below the threshold of the namespace",
+	}, []string{"level"})
+
+	// TimestampFailures is a prometheus metric which keeps the number of records whose timestamp could not be used
+	TimestampFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "timestamp_failures_total",
+		Help:      "Total number of the records whose timestamp key could not be used by reason",
+	}, []string{"reason"})
+
+	// LogsDroppedByFilter is a prometheus metric which keeps the number of logs dropped by the DropIf and KeepIf rules
+	LogsDroppedByFilter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logs_dropped_by_filter_total",
+		Help:      "Total number of the logs dropped by a DropIf rule or because they match no KeepIf rule",
+	}, []string{"rule"})
+
+	// PodMetadataLookups is a prometheus metric which keeps the number of pod metadata lookups by result
+	PodMetadataLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pod_metadata_lookups_total",
+		Help:      "Total number of the pod metadata lookups by result",
+	}, []string{"result"})
+
+	// LogsDroppedByAnnotation is a prometheus metric which keeps the number of logs dropped by the drop and sample-rate pod annotations
+	LogsDroppedByAnnotation = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logs_dropped_by_annotation_total",
+		Help:      "Total number of the logs dropped by the drop or sample-rate pod annotation",
+	}, []string{"annotation"})
+
+	// LogMetricsDroppedSeries is a prometheus metric which keeps the number of log metric samples dropped by the series limit
+	LogMetricsDroppedSeries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "log_metrics_dropped_series_total",
+		Help:      "Total number of the log metric samples dropped because the metric has reached LogMetricsMaxSeries",
+	}, []string{"metric"})
+
+	// ResumedChunks is a prometheus metric which keeps the number of retried chunks resumed after their last sent record
+	ResumedChunks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "resumed_chunks_total",
+		Help:      "Total number of the chunks retried by fluent-bit which are resumed after their last sent record",
+	})
+
+	// AvoidedDuplicates is a prometheus metric which keeps the number of records not sent again on the retry of their chunk
+	AvoidedDuplicates = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "avoided_duplicates_total",
+		Help:      "Total number of the records which are not sent again when fluent-bit retries their chunk",
+	})
 )
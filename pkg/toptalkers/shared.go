@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package toptalkers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	sharedMu sync.Mutex
+	shared   *Tracker
+
+	bytesDesc = prometheus.NewDesc("fluentbit_vali_gardener_top_talker_bytes",
+		"Bytes sent over the TopTalkersWindow by the heaviest namespaces, pods and containers of each cluster",
+		[]string{"host", "kind", "name"}, nil)
+	linesDesc = prometheus.NewDesc("fluentbit_vali_gardener_top_talker_lines",
+		"Lines sent over the TopTalkersWindow by the heaviest namespaces, pods and containers of each cluster",
+		[]string{"host", "kind", "name"}, nil)
+)
+
+// Shared returns the tracker of the process, which is shared by the plugin instances like the other metrics.
+// It is created and registered as prometheus collector on the first call, whose size and window apply.
+func Shared(size int, window time.Duration) (*Tracker, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if shared != nil {
+		return shared, nil
+	}
+	t := NewTracker(size, window)
+	if err := prometheus.Register(t); err != nil {
+		return nil, fmt.Errorf("failed to register TopTalkers: %v", err)
+	}
+	shared = t
+	return shared, nil
+}
+
+// Describe implements prometheus.Collector.
+func (t *Tracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- bytesDesc
+	ch <- linesDesc
+}
+
+// Collect implements prometheus.Collector with the talkers of the Snapshot.
+func (t *Tracker) Collect(ch chan<- prometheus.Metric) {
+	for cluster, snapshot := range t.Snapshot().Clusters {
+		for kind, talkers := range map[string][]Talker{KindNamespace: snapshot.Namespaces, KindPod: snapshot.Pods, KindContainer: snapshot.Containers} {
+			for _, talker := range talkers {
+				ch <- prometheus.MustNewConstMetric(bytesDesc, prometheus.GaugeValue, float64(talker.Bytes), cluster, kind, talker.Name)
+				ch <- prometheus.MustNewConstMetric(linesDesc, prometheus.GaugeValue, float64(talker.Lines), cluster, kind, talker.Name)
+			}
+		}
+	}
+}
+
+// Handler returns the /debug/top handler, which serves the Snapshot of the shared tracker as json.
+// The cluster query parameter restricts the snapshot to one cluster, and the minutes query parameter
+// restricts it to the last minutes of the window.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sharedMu.Lock()
+		t := shared
+		sharedMu.Unlock()
+		if t == nil {
+			http.Error(w, "TopTalkers is not enabled", http.StatusNotFound)
+			return
+		}
+
+		window := t.Window()
+		if value := r.URL.Query().Get("minutes"); value != "" {
+			minutes, err := strconv.Atoi(value)
+			if err != nil || minutes < 1 || time.Duration(minutes)*time.Minute > window {
+				http.Error(w, fmt.Sprintf("minutes must be a number from 1 to %d", int(window/time.Minute)), http.StatusBadRequest)
+				return
+			}
+			window = time.Duration(minutes) * time.Minute
+		}
+
+		snapshot := t.SnapshotOver(window)
+		if cluster := r.URL.Query().Get("cluster"); cluster != "" {
+			clusters := map[string]ClusterSnapshot{}
+			if c, ok := snapshot.Clusters[cluster]; ok {
+				clusters[cluster] = c
+			}
+			snapshot.Clusters = clusters
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
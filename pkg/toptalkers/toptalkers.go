@@ -0,0 +1,260 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package toptalkers
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of the accounted workloads
+const (
+	KindNamespace = "namespace"
+	KindPod       = "pod"
+	KindContainer = "container"
+)
+
+var kinds = []string{KindNamespace, KindPod, KindContainer}
+
+// capacityFactor is the number of counters kept per reported talker. The spare counters
+// bound the error of the reported ones.
+const capacityFactor = 4
+
+// Tracker accounts the bytes and lines sent per cluster by namespace, pod and container.
+// Each kind is tracked in one Space-Saving sketch per minute of the window, so the memory
+// is bounded by the clusters times the minutes of the window times the size.
+type Tracker struct {
+	mu       sync.Mutex
+	size     int
+	window   time.Duration
+	clusters map[string][]*slot
+	// pruned is the minute in which the clusters without entries in the window were last removed.
+	pruned time.Time
+	now    func() time.Time
+}
+
+// slot holds the sketches of one minute.
+type slot struct {
+	start     time.Time
+	summaries map[string]*summary
+}
+
+// NewTracker returns a tracker reporting the size heaviest talkers of each kind over the window.
+func NewTracker(size int, window time.Duration) *Tracker {
+	if window < time.Minute {
+		window = time.Minute
+	}
+	return &Tracker{
+		size:     size,
+		window:   window.Truncate(time.Minute),
+		clusters: map[string][]*slot{},
+		now:      time.Now,
+	}
+}
+
+// Size returns the number of reported talkers of each kind.
+func (t *Tracker) Size() int {
+	return t.size
+}
+
+// Window returns the time over which the talkers are reported.
+func (t *Tracker) Window() time.Duration {
+	return t.window
+}
+
+// Add accounts a line of the given bytes to the cluster and to its namespace, pod and container.
+// Entries without namespace are not accounted.
+func (t *Tracker) Add(cluster, namespace, pod, container string, bytes int) {
+	if namespace == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if minute := now.Truncate(time.Minute); !minute.Equal(t.pruned) {
+		t.prune(now)
+		t.pruned = minute
+	}
+	s := t.slot(cluster, now)
+	s.summaries[KindNamespace].add(namespace, uint64(bytes))
+	if pod == "" {
+		return
+	}
+	s.summaries[KindPod].add(namespace+"/"+pod, uint64(bytes))
+	if container == "" {
+		return
+	}
+	s.summaries[KindContainer].add(namespace+"/"+pod+"/"+container, uint64(bytes))
+}
+
+// slot returns the slot of the cluster for the minute of now, replacing the expired one.
+func (t *Tracker) slot(cluster string, now time.Time) *slot {
+	slots, ok := t.clusters[cluster]
+	if !ok {
+		slots = make([]*slot, t.window/time.Minute)
+		t.clusters[cluster] = slots
+	}
+	start := now.Truncate(time.Minute)
+	idx := int(start.Unix()/60) % len(slots)
+	if slots[idx] == nil || !slots[idx].start.Equal(start) {
+		slots[idx] = &slot{start: start, summaries: make(map[string]*summary, len(kinds))}
+		for _, kind := range kinds {
+			slots[idx].summaries[kind] = newSummary(t.size * capacityFactor)
+		}
+	}
+	return slots[idx]
+}
+
+// Talker is the throughput of a namespace, pod or container.
+type Talker struct {
+	Name  string `json:"name"`
+	Bytes uint64 `json:"bytes"`
+	Lines uint64 `json:"lines"`
+	// Error is the upper bound by which Bytes may overestimate the throughput of the sketched talkers.
+	Error uint64 `json:"error,omitempty"`
+}
+
+// ClusterSnapshot holds the heaviest talkers of a cluster.
+type ClusterSnapshot struct {
+	Namespaces []Talker `json:"namespaces"`
+	Pods       []Talker `json:"pods"`
+	Containers []Talker `json:"containers"`
+}
+
+// Snapshot holds the heaviest talkers of each cluster over the window.
+type Snapshot struct {
+	Window   string                     `json:"window"`
+	Clusters map[string]ClusterSnapshot `json:"clusters"`
+}
+
+// Snapshot returns the heaviest talkers of the window.
+func (t *Tracker) Snapshot() Snapshot {
+	return t.SnapshotOver(t.window)
+}
+
+// SnapshotOver returns the heaviest talkers of the last minutes of the window, at least one and at most all of them.
+// The clusters without entries in the window are removed.
+func (t *Tracker) SnapshotOver(window time.Duration) Snapshot {
+	window = window.Truncate(time.Minute)
+	if window < time.Minute {
+		window = time.Minute
+	}
+	if window > t.window {
+		window = t.window
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.prune(now)
+	res := Snapshot{Window: window.String(), Clusters: make(map[string]ClusterSnapshot, len(t.clusters))}
+	for cluster, slots := range t.clusters {
+		active := activeSlots(slots, now, window)
+		if len(active) == 0 {
+			continue
+		}
+		res.Clusters[cluster] = ClusterSnapshot{
+			Namespaces: t.top(active, KindNamespace),
+			Pods:       t.top(active, KindPod),
+			Containers: t.top(active, KindContainer),
+		}
+	}
+	return res
+}
+
+// prune removes the clusters without entries in the window. The caller holds the lock.
+func (t *Tracker) prune(now time.Time) {
+	for cluster, slots := range t.clusters {
+		if len(activeSlots(slots, now, t.window)) == 0 {
+			delete(t.clusters, cluster)
+		}
+	}
+}
+
+// activeSlots returns the slots of the minutes within the window before now.
+func activeSlots(slots []*slot, now time.Time, window time.Duration) []*slot {
+	var res []*slot
+	for _, s := range slots {
+		if s != nil && now.Sub(s.start) < window {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// top merges the sketches of the kind and returns the heaviest talkers.
+func (t *Tracker) top(slots []*slot, kind string) []Talker {
+	merged := map[string]*Talker{}
+	for _, s := range slots {
+		for name, c := range s.summaries[kind].counters {
+			talker, ok := merged[name]
+			if !ok {
+				talker = &Talker{Name: name}
+				merged[name] = talker
+			}
+			talker.Bytes += c.bytes
+			talker.Lines += c.lines
+			talker.Error += c.err
+		}
+	}
+
+	res := make([]Talker, 0, len(merged))
+	for _, talker := range merged {
+		res = append(res, *talker)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Bytes != res[j].Bytes {
+			return res[i].Bytes > res[j].Bytes
+		}
+		return res[i].Name < res[j].Name
+	})
+	if len(res) > t.size {
+		res = res[:t.size]
+	}
+	return res
+}
+
+// counter is the throughput of one key in a summary.
+type counter struct {
+	bytes uint64
+	lines uint64
+	err   uint64
+}
+
+// summary is a Space-Saving sketch keeping the counters of the heaviest keys by bytes.
+// A new key replaces the smallest counter and inherits its value as error.
+type summary struct {
+	capacity int
+	counters map[string]*counter
+}
+
+func newSummary(capacity int) *summary {
+	return &summary{capacity: capacity, counters: make(map[string]*counter, capacity)}
+}
+
+func (s *summary) add(key string, bytes uint64) {
+	if c, ok := s.counters[key]; ok {
+		c.bytes += bytes
+		c.lines++
+		return
+	}
+	if len(s.counters) < s.capacity {
+		s.counters[key] = &counter{bytes: bytes, lines: 1}
+		return
+	}
+
+	var minKey string
+	var min *counter
+	for k, c := range s.counters {
+		if min == nil || c.bytes < min.bytes || (c.bytes == min.bytes && k < minKey) {
+			minKey, min = k, c
+		}
+	}
+	delete(s.counters, minKey)
+	s.counters[key] = &counter{bytes: min.bytes + bytes, lines: min.lines + 1, err: min.bytes}
+}
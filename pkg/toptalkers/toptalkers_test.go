@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package toptalkers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TopTalkers", func() {
+	var (
+		t   *Tracker
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		t = NewTracker(2, 3*time.Minute)
+		now = time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+		t.now = func() time.Time { return now }
+	})
+
+	It("should snapshot the heaviest namespaces, pods and containers of each cluster", func() {
+		t.Add("shoot--dev--a", "kube-system", "coredns-1", "coredns", 100)
+		t.Add("shoot--dev--a", "kube-system", "coredns-1", "coredns", 100)
+		t.Add("shoot--dev--a", "kube-system", "kube-proxy-1", "kube-proxy", 50)
+		t.Add("shoot--dev--a", "default", "app-1", "app", 500)
+		t.Add("shoot--dev--a", "monitoring", "prometheus-0", "prometheus", 10)
+		t.Add("shoot--dev--b", "default", "app-1", "", 7)
+		t.Add("shoot--dev--b", "", "journald", "", 1000)
+
+		snapshot := t.Snapshot()
+		Expect(snapshot.Window).To(Equal("3m0s"))
+		Expect(snapshot.Clusters).To(HaveLen(2))
+		Expect(snapshot.Clusters["shoot--dev--a"]).To(Equal(ClusterSnapshot{
+			Namespaces: []Talker{{Name: "default", Bytes: 500, Lines: 1}, {Name: "kube-system", Bytes: 250, Lines: 3}},
+			Pods:       []Talker{{Name: "default/app-1", Bytes: 500, Lines: 1}, {Name: "kube-system/coredns-1", Bytes: 200, Lines: 2}},
+			Containers: []Talker{{Name: "default/app-1/app", Bytes: 500, Lines: 1}, {Name: "kube-system/coredns-1/coredns", Bytes: 200, Lines: 2}},
+		}))
+		Expect(snapshot.Clusters["shoot--dev--b"]).To(Equal(ClusterSnapshot{
+			Namespaces: []Talker{{Name: "default", Bytes: 7, Lines: 1}},
+			Pods:       []Talker{{Name: "default/app-1", Bytes: 7, Lines: 1}},
+			Containers: []Talker{},
+		}))
+	})
+
+	It("should keep the heavy talkers when the sketch is full", func() {
+		for i := 0; i < 100; i++ {
+			t.Add("shoot--dev--a", fmt.Sprintf("namespace-%d", i), "", "", 1)
+			t.Add("shoot--dev--a", "flooding", "", "", 10)
+		}
+		Expect(t.clusters["shoot--dev--a"][0].summaries[KindNamespace].counters).To(HaveLen(2 * capacityFactor))
+
+		namespaces := t.Snapshot().Clusters["shoot--dev--a"].Namespaces
+		Expect(namespaces).To(HaveLen(2))
+		Expect(namespaces[0]).To(Equal(Talker{Name: "flooding", Bytes: 1000, Lines: 100}))
+		Expect(namespaces[1].Bytes - namespaces[1].Error).To(BeNumerically("<=", 1))
+	})
+
+	It("should snapshot the talkers of the window only", func() {
+		t.Add("shoot--dev--a", "default", "", "", 1)
+		now = now.Add(time.Minute)
+		t.Add("shoot--dev--a", "default", "", "", 2)
+		t.Add("shoot--dev--a", "garden", "", "", 1)
+		now = now.Add(time.Minute)
+		Expect(t.Snapshot().Clusters["shoot--dev--a"].Namespaces).To(Equal([]Talker{
+			{Name: "default", Bytes: 3, Lines: 2},
+			{Name: "garden", Bytes: 1, Lines: 1},
+		}))
+
+		now = now.Add(time.Minute)
+		Expect(t.Snapshot().Clusters["shoot--dev--a"].Namespaces).To(Equal([]Talker{
+			{Name: "default", Bytes: 2, Lines: 1},
+			{Name: "garden", Bytes: 1, Lines: 1},
+		}))
+
+		now = now.Add(time.Minute)
+		Expect(t.Snapshot().Clusters).To(BeEmpty())
+		Expect(t.clusters).To(BeEmpty())
+	})
+
+	It("should snapshot the talkers of the last minutes", func() {
+		t.Add("shoot--dev--a", "default", "", "", 1)
+		now = now.Add(time.Minute)
+		t.Add("shoot--dev--a", "garden", "", "", 2)
+
+		snapshot := t.SnapshotOver(time.Minute)
+		Expect(snapshot.Window).To(Equal("1m0s"))
+		Expect(snapshot.Clusters["shoot--dev--a"].Namespaces).To(Equal([]Talker{{Name: "garden", Bytes: 2, Lines: 1}}))
+		Expect(t.SnapshotOver(time.Hour).Window).To(Equal(t.Window().String()))
+	})
+
+	It("should remove the idle clusters on add", func() {
+		t.Add("shoot--dev--a", "default", "", "", 1)
+		now = now.Add(t.Window())
+		t.Add("shoot--dev--b", "default", "", "", 1)
+		Expect(t.clusters).To(HaveLen(1))
+		Expect(t.clusters).To(HaveKey("shoot--dev--b"))
+	})
+
+	It("should serve the snapshot of the shared tracker", func() {
+		server := httptest.NewServer(Handler())
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Body.Close()).To(Succeed())
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+
+		shared, err := Shared(1, time.Minute)
+		Expect(err).ToNot(HaveOccurred())
+		again, err := Shared(5, time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(again).To(BeIdenticalTo(shared))
+		shared.Add("shoot--dev--a", "default", "app-1", "app", 10)
+		shared.Add("shoot--dev--b", "default", "app-1", "app", 20)
+
+		for _, minutes := range []string{"0", "2", "x"} {
+			resp, err = http.Get(server.URL + "?minutes=" + minutes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Body.Close()).To(Succeed())
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		}
+
+		resp, err = http.Get(server.URL + "?cluster=shoot--dev--a&minutes=1")
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var snapshot Snapshot
+		Expect(json.NewDecoder(resp.Body).Decode(&snapshot)).To(Succeed())
+		Expect(snapshot).To(Equal(Snapshot{
+			Window: "1m0s",
+			Clusters: map[string]ClusterSnapshot{"shoot--dev--a": {
+				Namespaces: []Talker{{Name: "default", Bytes: 10, Lines: 1}},
+				Pods:       []Talker{{Name: "default/app-1", Bytes: 10, Lines: 1}},
+				Containers: []Talker{{Name: "default/app-1/app", Bytes: 10, Lines: 1}},
+			}},
+		}))
+	})
+})
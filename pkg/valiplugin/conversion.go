@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+// unixNanoTime is implemented by time.Time and by the fluent-bit event time, which embeds it.
+type unixNanoTime interface {
+	UnixNano() int64
+}
+
+// converter converts the msgpack values of a record into values which can be encoded as line.
+// Without configuration it keeps the legacy conversion: the non string keys are dropped,
+// the byte values are converted to strings and the other values are kept as they are.
+type converter struct {
+	conf *config.ConversionConfig
+}
+
+func newConverter(conf *config.ConversionConfig) *converter {
+	return &converter{conf: conf}
+}
+
+// toStringMap converts the record. A converter without conf keeps the legacy conversion.
+func (c *converter) toStringMap(record map[interface{}]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(record)+inCaseKubernetesMetadataIsMissing)
+	var others []interface{}
+	for k, v := range record {
+		key, ok := k.(string)
+		if !ok {
+			if c.conf != nil && c.conf.StringifyKeys {
+				others = append(others, k)
+			}
+			continue
+		}
+		m[key] = c.value(v)
+	}
+	if len(others) == 0 {
+		return m
+	}
+
+	// The string keys win over the stringified ones, and the stringified ones are added in a stable order.
+	keys := make([]string, len(others))
+	for i, k := range others {
+		keys[i] = c.key(k)
+	}
+	sort.Sort(keysByString{keys: keys, others: others})
+	for i, key := range keys {
+		if _, ok := m[key]; !ok {
+			m[key] = c.value(record[others[i]])
+		}
+	}
+	return m
+}
+
+func (c *converter) toStringSlice(slice []interface{}) []interface{} {
+	s := make([]interface{}, 0, len(slice))
+	for _, v := range slice {
+		s = append(s, c.value(v))
+	}
+	return s
+}
+
+func (c *converter) value(v interface{}) interface{} {
+	switch t := v.(type) {
+	case []byte:
+		return c.bytes(t)
+	case map[interface{}]interface{}:
+		return c.toStringMap(t)
+	case []interface{}:
+		return c.toStringSlice(t)
+	}
+	if c.conf == nil {
+		return v
+	}
+
+	switch t := v.(type) {
+	case float64:
+		return c.float(t)
+	case float32:
+		return c.float(float64(t))
+	case codec.RawExt:
+		return c.ext(t.Tag, t.Data)
+	case *codec.RawExt:
+		if t == nil {
+			return nil
+		}
+		return c.ext(t.Tag, t.Data)
+	case unixNanoTime:
+		return c.time(t.UnixNano())
+	}
+	return v
+}
+
+func (c *converter) key(k interface{}) string {
+	switch t := c.value(k).(type) {
+	case string:
+		return t
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// bytes converts the valid UTF-8 bytes to a string and encodes the others with the BinaryEncoding.
+func (c *converter) bytes(b []byte) interface{} {
+	if c.conf == nil || utf8.Valid(b) {
+		return string(b)
+	}
+	switch c.conf.BinaryEncoding {
+	case config.BinaryEncodingBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	case config.BinaryEncodingHex:
+		return hex.EncodeToString(b)
+	default:
+		return string(b)
+	}
+}
+
+// float keeps the values which have no JSON number as strings, so the line can still be encoded.
+func (c *converter) float(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return f
+}
+
+// ext renders a msgpack extension, which has no JSON counterpart, with its type and encoded data.
+func (c *converter) ext(tag uint64, data []byte) interface{} {
+	var encoded string
+	if c.conf.BinaryEncoding == config.BinaryEncodingHex {
+		encoded = hex.EncodeToString(data)
+	} else {
+		encoded = base64.StdEncoding.EncodeToString(data)
+	}
+	return map[string]interface{}{"type": tag, "data": encoded}
+}
+
+func (c *converter) time(nanos int64) interface{} {
+	switch c.conf.EventTimeFormat {
+	case config.EventTimeUnix:
+		return float64(nanos) / float64(time.Second)
+	case config.EventTimeUnixMs:
+		return nanos / int64(time.Millisecond)
+	case config.EventTimeUnixNs:
+		return nanos
+	default:
+		return time.Unix(0, nanos).UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// keysByString sorts the stringified keys together with their original keys.
+type keysByString struct {
+	keys   []string
+	others []interface{}
+}
+
+func (k keysByString) Len() int { return len(k.keys) }
+
+func (k keysByString) Less(i, j int) bool {
+	if k.keys[i] != k.keys[j] {
+		return k.keys[i] < k.keys[j]
+	}
+	return fmt.Sprintf("%T", k.others[i]) < fmt.Sprintf("%T", k.others[j])
+}
+
+func (k keysByString) Swap(i, j int) {
+	k.keys[i], k.keys[j] = k.keys[j], k.keys[i]
+	k.others[i], k.others[j] = k.others[j], k.others[i]
+}
@@ -0,0 +1,215 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fluent/fluent-bit-go/output"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ugorji/go/codec"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("Conversion", func() {
+	type conversionArgs struct {
+		conf   *config.ConversionConfig
+		record map[interface{}]interface{}
+		want   map[string]interface{}
+	}
+
+	eventTime := time.Date(2024, 3, 1, 12, 0, 0, 1500000, time.UTC)
+	withConf := func(binaryEncoding string, stringifyKeys bool, eventTimeFormat string) *config.ConversionConfig {
+		return &config.ConversionConfig{BinaryEncoding: binaryEncoding, StringifyKeys: stringifyKeys, EventTimeFormat: eventTimeFormat}
+	}
+	defaultConf := withConf(config.BinaryEncodingString, false, config.EventTimeRFC3339Nano)
+
+	DescribeTable("#toStringMap",
+		func(args conversionArgs) {
+			got := newConverter(args.conf).toStringMap(args.record)
+			Expect(got).To(Equal(args.want))
+			_, err := createLine(got, config.JSONFormat, nil)
+			Expect(err).ToNot(HaveOccurred())
+		},
+		Entry("legacy conversion without configuration",
+			conversionArgs{
+				record: map[interface{}]interface{}{"log": []byte("a\xc5z"), int64(1): "one", "time": output.FLBTime{Time: eventTime}},
+				want:   map[string]interface{}{"log": "a\xc5z", "time": output.FLBTime{Time: eventTime}},
+			},
+		),
+		Entry("valid UTF-8 bytes are strings with any encoding",
+			conversionArgs{
+				conf:   withConf(config.BinaryEncodingBase64, false, config.EventTimeRFC3339Nano),
+				record: map[interface{}]interface{}{"log": []byte("héllo")},
+				want:   map[string]interface{}{"log": "héllo"},
+			},
+		),
+		Entry("base64 binary",
+			conversionArgs{
+				conf:   withConf(config.BinaryEncodingBase64, false, config.EventTimeRFC3339Nano),
+				record: map[interface{}]interface{}{"log": []byte{0xff, 0x00}, "list": []interface{}{[]byte{0xc5}}},
+				want:   map[string]interface{}{"log": "/wA=", "list": []interface{}{"xQ=="}},
+			},
+		),
+		Entry("hex binary",
+			conversionArgs{
+				conf:   withConf(config.BinaryEncodingHex, false, config.EventTimeRFC3339Nano),
+				record: map[interface{}]interface{}{"log": []byte{0xff, 0x00}, "nested": map[interface{}]interface{}{"b": []byte{0xc5}}},
+				want:   map[string]interface{}{"log": "ff00", "nested": map[string]interface{}{"b": "c5"}},
+			},
+		),
+		Entry("stringified keys",
+			conversionArgs{
+				conf:   withConf(config.BinaryEncodingString, true, config.EventTimeRFC3339Nano),
+				record: map[interface{}]interface{}{int64(1): "one", uint64(2): "two", true: "yes", nil: "none", 1.5: "half"},
+				want:   map[string]interface{}{"1": "one", "2": "two", "true": "yes", "null": "none", "1.5": "half"},
+			},
+		),
+		Entry("string keys win over stringified keys",
+			conversionArgs{
+				conf:   withConf(config.BinaryEncodingString, true, config.EventTimeRFC3339Nano),
+				record: map[interface{}]interface{}{"1": "string", int64(1): "int"},
+				want:   map[string]interface{}{"1": "string"},
+			},
+		),
+		Entry("non string keys are dropped without StringifyKeys",
+			conversionArgs{
+				conf:   defaultConf,
+				record: map[interface{}]interface{}{"log": "line", int64(1): "one"},
+				want:   map[string]interface{}{"log": "line"},
+			},
+		),
+		Entry("extensions",
+			conversionArgs{
+				conf:   defaultConf,
+				record: map[interface{}]interface{}{"ext": codec.RawExt{Tag: 5, Data: []byte{1, 2}}, "ptr": &codec.RawExt{Tag: 7, Data: []byte{0xff}}},
+				want: map[string]interface{}{
+					"ext": map[string]interface{}{"type": uint64(5), "data": "AQI="},
+					"ptr": map[string]interface{}{"type": uint64(7), "data": "/w=="},
+				},
+			},
+		),
+		Entry("hex extensions",
+			conversionArgs{
+				conf:   withConf(config.BinaryEncodingHex, false, config.EventTimeRFC3339Nano),
+				record: map[interface{}]interface{}{"ext": codec.RawExt{Tag: 5, Data: []byte{1, 2}}},
+				want:   map[string]interface{}{"ext": map[string]interface{}{"type": uint64(5), "data": "0102"}},
+			},
+		),
+		Entry("rfc3339nano time",
+			conversionArgs{
+				conf:   defaultConf,
+				record: map[interface{}]interface{}{"flb": output.FLBTime{Time: eventTime}, "time": eventTime},
+				want:   map[string]interface{}{"flb": "2024-03-01T12:00:00.0015Z", "time": "2024-03-01T12:00:00.0015Z"},
+			},
+		),
+		Entry("unix time",
+			conversionArgs{
+				conf:   withConf(config.BinaryEncodingString, false, config.EventTimeUnix),
+				record: map[interface{}]interface{}{"flb": output.FLBTime{Time: eventTime}},
+				want:   map[string]interface{}{"flb": float64(eventTime.UnixNano()) / float64(time.Second)},
+			},
+		),
+		Entry("unix_ms time",
+			conversionArgs{
+				conf:   withConf(config.BinaryEncodingString, false, config.EventTimeUnixMs),
+				record: map[interface{}]interface{}{"flb": output.FLBTime{Time: eventTime}},
+				want:   map[string]interface{}{"flb": eventTime.UnixMilli()},
+			},
+		),
+		Entry("unix_ns time",
+			conversionArgs{
+				conf:   withConf(config.BinaryEncodingString, false, config.EventTimeUnixNs),
+				record: map[interface{}]interface{}{"flb": output.FLBTime{Time: eventTime}},
+				want:   map[string]interface{}{"flb": eventTime.UnixNano()},
+			},
+		),
+		Entry("floats without JSON numbers",
+			conversionArgs{
+				conf:   defaultConf,
+				record: map[interface{}]interface{}{"nan": math.NaN(), "inf": []interface{}{math.Inf(1), math.Inf(-1), 1.5}},
+				want:   map[string]interface{}{"nan": "NaN", "inf": []interface{}{"+Inf", "-Inf", 1.5}},
+			},
+		),
+	)
+})
+
+// FuzzConvert decodes random msgpack payloads like fluent-bit-go does and checks that
+// every conversion can be encoded as line.
+func FuzzConvert(f *testing.F) {
+	// {"s":"str",1:"k","b":bin(ff00),"e":ext5(0102),"t":eventtime(1,2),"a":[1,-2,1.5,nil,true],"m":{2:"x"}}
+	f.Add([]byte{
+		0x87,
+		0xa1, 's', 0xa3, 's', 't', 'r',
+		0x01, 0xa1, 'k',
+		0xa1, 'b', 0xc4, 0x02, 0xff, 0x00,
+		0xa1, 'e', 0xd5, 0x05, 0x01, 0x02,
+		0xa1, 't', 0xd7, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+		0xa1, 'a', 0x95, 0x01, 0xfe, 0xcb, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xc0, 0xc3,
+		0xa1, 'm', 0x81, 0x02, 0xa1, 'x',
+	})
+	// {"log":str(a\xc5z),"nan":float64(NaN),1.5:bin(c5)}
+	f.Add([]byte{
+		0x83,
+		0xa3, 'l', 'o', 'g', 0xa3, 'a', 0xc5, 'z',
+		0xa3, 'n', 'a', 'n', 0xcb, 0x7f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0xcb, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xc4, 0x01, 0xc5,
+	})
+	// {"1":"string",1:"int",nil:{"deep":[[{"x":ext1()}]]}}
+	f.Add([]byte{
+		0x83,
+		0xa1, '1', 0xa6, 's', 't', 'r', 'i', 'n', 'g',
+		0x01, 0xa3, 'i', 'n', 't',
+		0xc0, 0x81, 0xa4, 'd', 'e', 'e', 'p', 0x91, 0x91, 0x81, 0xa1, 'x', 0xc7, 0x00, 0x01,
+	})
+
+	h := &codec.MsgpackHandle{}
+	if err := h.SetBytesExt(reflect.TypeOf(output.FLBTime{}), 0, &output.FLBTime{}); err != nil {
+		f.Fatal(err)
+	}
+	confs := []*config.ConversionConfig{
+		nil,
+		{BinaryEncoding: config.BinaryEncodingString, StringifyKeys: true, EventTimeFormat: config.EventTimeRFC3339Nano},
+		{BinaryEncoding: config.BinaryEncodingBase64, StringifyKeys: true, EventTimeFormat: config.EventTimeUnix},
+		{BinaryEncoding: config.BinaryEncodingHex, StringifyKeys: false, EventTimeFormat: config.EventTimeUnixNs},
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var record map[interface{}]interface{}
+		if err := codec.NewDecoderBytes(data, h).Decode(&record); err != nil {
+			return
+		}
+		for _, conf := range confs {
+			got := newConverter(conf).toStringMap(record)
+			if conf != nil && conf.StringifyKeys && len(got) == 0 && len(record) > 0 {
+				t.Fatalf("all keys of %v were dropped", record)
+			}
+			for k := range record {
+				if s, ok := k.(string); ok {
+					if _, ok := got[s]; !ok {
+						t.Fatalf("key %q was dropped", s)
+					}
+				}
+			}
+			if conf == nil {
+				// The legacy conversion keeps the values which have no JSON encoding.
+				continue
+			}
+			line, err := createLine(got, config.JSONFormat, nil)
+			if err != nil {
+				t.Fatalf("failed to create the line of %v: %v", got, err)
+			}
+			if conf.BinaryEncoding != config.BinaryEncodingString && !utf8.ValidString(line) {
+				t.Fatalf("line %q is not valid UTF-8", line)
+			}
+		}
+	})
+}
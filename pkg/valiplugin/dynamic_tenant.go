@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	grafanavaliclient "github.com/credativ/vali/pkg/valitail/client"
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/client"
+	"github.com/gardener/logging/pkg/config"
+)
+
+// dynamicTenant sets the tenant of the records of the dynamic hosts by the DynamicTenant rules.
+type dynamicTenant struct {
+	rules []*dynamicTenantRule
+	all   bool
+}
+
+type dynamicTenantRule struct {
+	field    fieldPath
+	regex    *regexp.Regexp
+	tenant   string
+	template string
+}
+
+func newDynamicTenant(conf config.DynamicTenant) (*dynamicTenant, error) {
+	if len(conf.Rules) == 0 {
+		return nil, nil
+	}
+	res := &dynamicTenant{all: conf.Match == config.DynamicTenantMatchAll}
+	for _, r := range conf.Rules {
+		regex, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile DynamicTenant regex: %v", err)
+		}
+		res.rules = append(res.rules, &dynamicTenantRule{field: fieldPath(r.Field), regex: regex, tenant: r.Tenant, template: r.Template})
+	}
+	return res, nil
+}
+
+// tenants returns the tenants of the matching rules, only the first one unless all matches are used.
+func (d *dynamicTenant) tenants(records map[string]interface{}) []string {
+	var res []string
+	for _, r := range d.rules {
+		tenant, ok := r.match(records)
+		if !ok || tenant == "" {
+			continue
+		}
+		if !d.all {
+			return []string{tenant}
+		}
+		if !slices.Contains(res, tenant) {
+			res = append(res, tenant)
+		}
+	}
+	return res
+}
+
+// set sets the tenant label of the first tenant. More tenants are added as multi-tenant ids,
+// which the client sends the record to if EnableMultiTenancy is set.
+func (d *dynamicTenant) set(records map[string]interface{}, lbs model.LabelSet) {
+	tenants := d.tenants(records)
+	if len(tenants) == 0 {
+		return
+	}
+	lbs[grafanavaliclient.ReservedLabelTenantID] = model.LabelValue(tenants[0])
+	if len(tenants) > 1 {
+		lbs[client.MultiTenantClientLabel] = model.LabelValue(strings.Join(tenants, client.MultiTenantClientsSeparator))
+	}
+}
+
+func (r *dynamicTenantRule) match(records map[string]interface{}) (string, bool) {
+	value, ok := r.field.get(records)
+	if !ok {
+		return "", false
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", false
+	}
+	if r.template == "" {
+		return r.tenant, r.regex.MatchString(s)
+	}
+	match := r.regex.FindStringSubmatchIndex(s)
+	if match == nil {
+		return "", false
+	}
+	return string(r.regex.ExpandString(nil, r.template, s, match)), true
+}
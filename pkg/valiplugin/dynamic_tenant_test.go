@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("DynamicTenant", func() {
+	rules := []config.DynamicTenantRule{
+		{Field: "kubernetes.namespace_name", Regex: "^team-(?P<team>[a-z]+)-", Template: "team-${team}"},
+		{Field: "tag", Regex: "^user-exposed\\.", Tenant: "user"},
+		{Field: "kubernetes.labels.tier", Regex: "^backend$", Tenant: "backend"},
+	}
+	record := func(namespace, tag string) map[string]interface{} {
+		return map[string]interface{}{
+			"log": "message",
+			"tag": tag,
+			"kubernetes": map[string]interface{}{
+				"namespace_name": namespace,
+				"labels":         map[string]interface{}{"tier": "backend"},
+			},
+		}
+	}
+
+	DescribeTable("#set",
+		func(match string, records map[string]interface{}, expected model.LabelSet) {
+			d, err := newDynamicTenant(config.DynamicTenant{Rules: rules, Match: match})
+			Expect(err).ToNot(HaveOccurred())
+			lbs := model.LabelSet{"foo": "bar"}
+			d.set(records, lbs)
+			Expect(lbs).To(Equal(expected))
+		},
+		Entry("first matching rule with capture template", config.DynamicTenantMatchFirst,
+			record("team-a-dev", "user-exposed.kubernetes"),
+			model.LabelSet{"foo": "bar", "__tenant_id__": "team-a"}),
+		Entry("first matching rule in order", config.DynamicTenantMatchFirst,
+			record("kube-system", "user-exposed.kubernetes"),
+			model.LabelSet{"foo": "bar", "__tenant_id__": "user"}),
+		Entry("nested field", config.DynamicTenantMatchFirst,
+			record("kube-system", "kubernetes.var.log"),
+			model.LabelSet{"foo": "bar", "__tenant_id__": "backend"}),
+		Entry("all matching rules", config.DynamicTenantMatchAll,
+			record("team-a-dev", "user-exposed.kubernetes"),
+			model.LabelSet{"foo": "bar", "__tenant_id__": "team-a", "__gardener_multitenant_id__": "team-a;user;backend"}),
+		Entry("single match with all matching rules", config.DynamicTenantMatchAll,
+			map[string]interface{}{"tag": "user-exposed.kubernetes"},
+			model.LabelSet{"foo": "bar", "__tenant_id__": "user"}),
+		Entry("no matching rule", config.DynamicTenantMatchAll,
+			map[string]interface{}{"tag": "kubernetes.var.log", "kubernetes": map[string]interface{}{"namespace_name": "garden"}},
+			model.LabelSet{"foo": "bar"}),
+		Entry("field which is not a string", config.DynamicTenantMatchFirst,
+			map[string]interface{}{"tag": 42},
+			model.LabelSet{"foo": "bar"}),
+	)
+
+	It("should skip a template which expands to an empty tenant", func() {
+		d, err := newDynamicTenant(config.DynamicTenant{Rules: []config.DynamicTenantRule{
+			{Field: "tag", Regex: "^(x*)user", Template: "$1"},
+			{Field: "tag", Regex: "user", Tenant: "user"},
+		}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(d.tenants(map[string]interface{}{"tag": "user"})).To(Equal([]string{"user"}))
+	})
+})
@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/credativ/vali/pkg/logql"
+	"github.com/credativ/vali/pkg/logql/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+// maxFilterStreams bounds the per stream cache of a rule pipeline.
+const maxFilterStreams = 10000
+
+// filter drops entries by the DropIf and KeepIf rules.
+type filter struct {
+	dropIf []*filterRule
+	keepIf []*filterRule
+}
+
+type filterRule struct {
+	name     string
+	selector logql.LogSelectorExpr
+	// mu guards the pipeline and the streams, the stream pipelines are not safe for concurrent use.
+	mu       sync.Mutex
+	pipeline log.Pipeline
+	streams  map[uint64]struct{}
+}
+
+func newFilter(conf config.PluginConfig) (*filter, error) {
+	if len(conf.DropIf) == 0 && len(conf.KeepIf) == 0 {
+		return nil, nil
+	}
+	f := &filter{}
+	var err error
+	if f.dropIf, err = newFilterRules(conf.DropIf); err != nil {
+		return nil, err
+	}
+	if f.keepIf, err = newFilterRules(conf.KeepIf); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func newFilterRules(rules config.FilterRules) ([]*filterRule, error) {
+	res := make([]*filterRule, 0, len(rules))
+	for name, s := range rules {
+		selector, err := logql.ParseLogSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		pipeline, err := selector.Pipeline()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &filterRule{name: name, selector: selector, pipeline: pipeline, streams: map[uint64]struct{}{}})
+	}
+	// The rules are evaluated in a stable order, so the first matching rule is the one which is counted.
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res, nil
+}
+
+// drop reports if the entry is dropped and counts it by the rule which dropped it.
+func (f *filter) drop(lbs model.LabelSet, line string) bool {
+	ls := make(labels.Labels, 0, len(lbs))
+	for name, value := range lbs {
+		ls = append(ls, labels.Label{Name: string(name), Value: string(value)})
+	}
+	ls = labels.New(ls...)
+
+	for _, r := range f.dropIf {
+		if r.match(ls, line) {
+			metrics.LogsDroppedByFilter.WithLabelValues(r.name).Inc()
+			return true
+		}
+	}
+	if len(f.keepIf) == 0 {
+		return false
+	}
+	for _, r := range f.keepIf {
+		if r.match(ls, line) {
+			return false
+		}
+	}
+	metrics.LogsDroppedByFilter.WithLabelValues(config.KeepIfRule).Inc()
+	return true
+}
+
+func (r *filterRule) match(ls labels.Labels, line string) bool {
+	for _, m := range r.selector.Matchers() {
+		if !m.Matches(ls.Get(m.Name)) {
+			return false
+		}
+	}
+	if !r.selector.HasFilter() {
+		return true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The pipeline keeps a stream pipeline per label set, which is renewed
+	// instead of growing without bound with the streams of deleted pods.
+	if len(r.streams) >= maxFilterStreams {
+		if pipeline, err := r.selector.Pipeline(); err == nil {
+			r.pipeline, r.streams = pipeline, map[uint64]struct{}{}
+		}
+	}
+	r.streams[ls.Hash()] = struct{}{}
+
+	_, _, ok := r.pipeline.ForStream(ls).ProcessString(line)
+	return ok
+}
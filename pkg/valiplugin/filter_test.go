@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+var _ = Describe("Filter", func() {
+	coredns := model.LabelSet{"namespace_name": "kube-system", "container_name": "coredns"}
+	apiserver := model.LabelSet{"namespace_name": "shoot--dev--test", "container_name": "kube-apiserver"}
+
+	dropped := func(rule string) float64 {
+		m := &dto.Metric{}
+		Expect(metrics.LogsDroppedByFilter.WithLabelValues(rule).Write(m)).To(Succeed())
+		return m.GetCounter().GetValue()
+	}
+
+	DescribeTable("#drop",
+		func(dropIf, keepIf config.FilterRules, lbs model.LabelSet, line string, expected bool, rule string) {
+			f, err := newFilter(config.PluginConfig{DropIf: dropIf, KeepIf: keepIf})
+			Expect(err).ToNot(HaveOccurred())
+			before := dropped(rule)
+			Expect(f.drop(lbs, line)).To(Equal(expected))
+			if expected {
+				Expect(dropped(rule)).To(Equal(before + 1))
+			}
+		},
+		Entry("stream selector with line filter",
+			config.FilterRules{"coredns": `{namespace_name="kube-system",container_name="coredns"} |= "NOERROR"`}, nil,
+			coredns, `[INFO] 10.0.0.1 "A IN example.com." NOERROR`, true, "coredns"),
+		Entry("line filter does not match",
+			config.FilterRules{"coredns": `{namespace_name="kube-system",container_name="coredns"} |= "NOERROR"`}, nil,
+			coredns, `[ERROR] plugin/errors: SERVFAIL`, false, ""),
+		Entry("stream selector does not match",
+			config.FilterRules{"coredns": `{namespace_name="kube-system",container_name="coredns"} |= "NOERROR"`}, nil,
+			apiserver, `NOERROR`, false, ""),
+		Entry("regex matcher without line filter",
+			config.FilterRules{"shoots": `{namespace_name=~"shoot--.+"}`}, nil,
+			apiserver, `started`, true, "shoots"),
+		Entry("negative regex line filter",
+			config.FilterRules{"no-errors": `{container_name="kube-apiserver"} !~ "(?i)error|fail"`}, nil,
+			apiserver, `GET /healthz 200`, true, "no-errors"),
+		Entry("json label filter",
+			config.FilterRules{"debug": `{container_name="kube-apiserver"} | json | level="debug"`}, nil,
+			apiserver, `{"level":"debug","msg":"watch"}`, true, "debug"),
+		Entry("keep a matching entry",
+			nil, config.FilterRules{"errors": `{namespace_name=~".+"} |~ "(?i)error"`},
+			apiserver, `E0102 an error occurred`, false, ""),
+		Entry("drop an entry matching no keep rule",
+			nil, config.FilterRules{"errors": `{namespace_name=~".+"} |~ "(?i)error"`},
+			apiserver, `I0102 started`, true, config.KeepIfRule),
+		Entry("drop rule before keep rule",
+			config.FilterRules{"coredns": `{container_name="coredns"}`}, config.FilterRules{"all": `{namespace_name=~".+"}`},
+			coredns, `NOERROR`, true, "coredns"),
+	)
+
+	It("should renew the stream pipelines of a rule", func() {
+		f, err := newFilter(config.PluginConfig{DropIf: config.FilterRules{"noise": `{namespace_name="kube-system"} |= "noise"`}})
+		Expect(err).ToNot(HaveOccurred())
+		for i := 0; i < maxFilterStreams+10; i++ {
+			lbs := model.LabelSet{"namespace_name": "kube-system", "pod_name": model.LabelValue(fmt.Sprintf("pod-%d", i))}
+			Expect(f.drop(lbs, "noise")).To(BeTrue())
+		}
+		Expect(f.dropIf[0].streams).To(HaveLen(10))
+	})
+
+	It("should drop entries concurrently", func() {
+		f, err := newFilter(config.PluginConfig{
+			DropIf: config.FilterRules{"noise": `{namespace_name="kube-system"} |= "noise"`},
+			KeepIf: config.FilterRules{"all": `{namespace_name=~".+"} != "debug"`},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer GinkgoRecover()
+				lbs := model.LabelSet{"namespace_name": "kube-system", "pod_name": model.LabelValue(fmt.Sprintf("pod-%d", i))}
+				for j := 0; j < 100; j++ {
+					Expect(f.drop(lbs, "noise")).To(BeTrue())
+					Expect(f.drop(lbs, "info")).To(BeFalse())
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+
+	It("should not create a filter without rules", func() {
+		f, err := newFilter(config.PluginConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f).To(BeNil())
+	})
+})
@@ -0,0 +1,265 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/credativ/vali/pkg/logql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+// logMetrics are the metrics derived from the log lines of an output, which are collected by the shared collector.
+type logMetrics struct {
+	metrics []*logMetric
+	shared  *sharedLogMetrics
+}
+
+// logMetric is a metric with its series, which are guarded by the mu.
+type logMetric struct {
+	conf      config.LogMetric
+	desc      *prometheus.Desc
+	matchers  []*labels.Matcher
+	regex     *regexp.Regexp
+	maxSeries int
+	idle      time.Duration
+	mu        sync.Mutex
+	series    map[string]*logSeries
+}
+
+// sharedLogMetrics is the prometheus collector of the log metrics of all outputs of the process.
+// The outputs which define a metric with the same name share it, like the other metrics of the plugin.
+type sharedLogMetrics struct {
+	mu         sync.Mutex
+	registerer prometheus.Registerer
+	registered bool
+	metrics    map[string]*logMetric
+	refs       map[string]int
+	now        func() time.Time
+}
+
+var sharedMetrics = newSharedLogMetrics(prometheus.DefaultRegisterer)
+
+func newSharedLogMetrics(registerer prometheus.Registerer) *sharedLogMetrics {
+	return &sharedLogMetrics{registerer: registerer, metrics: map[string]*logMetric{}, refs: map[string]int{}, now: time.Now}
+}
+
+// logSeries is the state of a metric for one set of label values.
+type logSeries struct {
+	labelValues []string
+	value       float64
+	count       uint64
+	buckets     []uint64
+	updated     time.Time
+}
+
+var _ prometheus.Collector = &sharedLogMetrics{}
+
+func newLogMetrics(conf *config.LogMetricsConfig) (*logMetrics, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	res := &logMetrics{shared: sharedMetrics}
+	for _, c := range conf.Metrics {
+		m := &logMetric{conf: c, maxSeries: conf.MaxSeries, idle: conf.IdleTimeout, series: map[string]*logSeries{}}
+		var err error
+		if c.Selector != "" {
+			if m.matchers, err = logql.ParseMatchers(c.Selector); err != nil {
+				return nil, err
+			}
+		}
+		if m.regex, err = regexp.Compile(c.Regex); err != nil {
+			return nil, err
+		}
+		if m.conf.Type == config.LogMetricHistogram && len(m.conf.Buckets) == 0 {
+			m.conf.Buckets = prometheus.DefBuckets
+		}
+		m.desc = prometheus.NewDesc(prometheus.BuildFQName(metrics.LogMetricsNamespace, "", c.Name), c.Help, c.Labels, nil)
+		res.metrics = append(res.metrics, m)
+	}
+	return res, nil
+}
+
+// register shares the metrics with the other outputs and registers them as prometheus collector.
+// A metric which another output defines differently is an error.
+func (l *logMetrics) register() error {
+	s := l.shared
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range l.metrics {
+		if other, ok := s.metrics[m.conf.Name]; ok && !reflect.DeepEqual(other.conf, m.conf) {
+			return fmt.Errorf("LogMetrics %s is already defined differently by another output", m.conf.Name)
+		}
+	}
+	if !s.registered {
+		if err := s.registerer.Register(s); err != nil {
+			return err
+		}
+		s.registered = true
+	}
+	for i, m := range l.metrics {
+		if other, ok := s.metrics[m.conf.Name]; ok {
+			l.metrics[i] = other
+		} else {
+			s.metrics[m.conf.Name] = m
+		}
+		s.refs[m.conf.Name]++
+	}
+	return nil
+}
+
+// unregister removes the metrics which are not used by another output.
+func (l *logMetrics) unregister() {
+	s := l.shared
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range l.metrics {
+		if s.refs[m.conf.Name]--; s.refs[m.conf.Name] <= 0 {
+			delete(s.metrics, m.conf.Name)
+			delete(s.refs, m.conf.Name)
+		}
+	}
+}
+
+// observe updates the metrics whose selector and regex match the entry.
+func (l *logMetrics) observe(lbs model.LabelSet, line string) {
+	now := l.shared.now()
+	for _, m := range l.metrics {
+		m.mu.Lock()
+		m.observe(lbs, line, now)
+		m.mu.Unlock()
+	}
+}
+
+// observe updates the metric if its selector and regex match the entry. The caller holds the lock.
+func (m *logMetric) observe(lbs model.LabelSet, line string, now time.Time) {
+	for _, matcher := range m.matchers {
+		if !matcher.Matches(string(lbs[model.LabelName(matcher.Name)])) {
+			return
+		}
+	}
+	captures := m.regex.FindStringSubmatch(line)
+	if captures == nil {
+		return
+	}
+
+	// The labels are taken from the named captures or else from the stream labels.
+	labelValues := make([]string, len(m.conf.Labels))
+	for i, name := range m.conf.Labels {
+		if idx := m.regex.SubexpIndex(name); idx > 0 && captures[idx] != "" {
+			labelValues[i] = captures[idx]
+		} else {
+			labelValues[i] = string(lbs[model.LabelName(name)])
+		}
+		if !utf8.ValidString(labelValues[i]) {
+			labelValues[i] = strings.ToValidUTF8(labelValues[i], string(utf8.RuneError))
+		}
+	}
+
+	var value float64
+	if m.conf.Value != "" {
+		var err error
+		value, err = strconv.ParseFloat(captures[m.regex.SubexpIndex(m.conf.Value)], 64)
+		// Counters can not decrease.
+		if err != nil || (m.conf.Type == config.LogMetricCounter && value < 0) {
+			metrics.Errors.WithLabelValues(metrics.ErrorLogMetric).Inc()
+			return
+		}
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		if len(m.series) >= m.maxSeries {
+			m.expire(now)
+		}
+		if len(m.series) >= m.maxSeries {
+			metrics.LogMetricsDroppedSeries.WithLabelValues(m.conf.Name).Inc()
+			return
+		}
+		s = &logSeries{labelValues: labelValues}
+		if m.conf.Type == config.LogMetricHistogram {
+			s.buckets = make([]uint64, len(m.conf.Buckets))
+		}
+		m.series[key] = s
+	}
+	s.updated = now
+
+	switch m.conf.Action {
+	case config.LogMetricInc:
+		s.value++
+	case config.LogMetricDec:
+		s.value--
+	case config.LogMetricAdd:
+		s.value += value
+	case config.LogMetricSub:
+		s.value -= value
+	case config.LogMetricSet:
+		s.value = value
+	case config.LogMetricObserve:
+		s.count++
+		s.value += value
+		for i, upper := range m.conf.Buckets {
+			if value <= upper {
+				s.buckets[i]++
+			}
+		}
+	}
+}
+
+// Describe implements prometheus.Collector. The shared metrics change with the outputs, so the collector is unchecked.
+func (s *sharedLogMetrics) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector. The series which were not updated within the idle timeout are removed.
+func (s *sharedLogMetrics) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, m := range s.metrics {
+		m.collect(ch, now)
+	}
+}
+
+// collect removes the idle series and sends the others.
+func (m *logMetric) collect(ch chan<- prometheus.Metric, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire(now)
+	for _, s := range m.series {
+		switch m.conf.Type {
+		case config.LogMetricCounter:
+			ch <- prometheus.MustNewConstMetric(m.desc, prometheus.CounterValue, s.value, s.labelValues...)
+		case config.LogMetricGauge:
+			ch <- prometheus.MustNewConstMetric(m.desc, prometheus.GaugeValue, s.value, s.labelValues...)
+		case config.LogMetricHistogram:
+			buckets := make(map[float64]uint64, len(m.conf.Buckets))
+			for i, upper := range m.conf.Buckets {
+				buckets[upper] = s.buckets[i]
+			}
+			ch <- prometheus.MustNewConstHistogram(m.desc, s.count, s.value, buckets, s.labelValues...)
+		}
+	}
+}
+
+// expire removes the series which were not updated within the idle timeout.
+func (m *logMetric) expire(now time.Time) {
+	for key, s := range m.series {
+		if now.Sub(s.updated) > m.idle {
+			delete(m.series, key)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+var _ = Describe("LogMetrics", func() {
+	etcd := model.LabelSet{"namespace_name": "shoot--dev--test", "container_name": "etcd"}
+	apiserver := model.LabelSet{"namespace_name": "shoot--dev--test", "container_name": "kube-apiserver"}
+
+	var (
+		lm       *logMetrics
+		shared   *sharedLogMetrics
+		registry *prometheus.Registry
+		now      time.Time
+	)
+
+	BeforeEach(func() {
+		registry = prometheus.NewPedanticRegistry()
+		shared = newSharedLogMetrics(registry)
+		now = time.Now()
+		shared.now = func() time.Time { return now }
+	})
+
+	// register registers the metrics of an output like the plugin, but with the shared collector of the test.
+	register := func(conf *config.LogMetricsConfig) (*logMetrics, error) {
+		for i := range conf.Metrics {
+			Expect(conf.Metrics[i].Validate()).To(Succeed())
+		}
+		l, err := newLogMetrics(conf)
+		Expect(err).ToNot(HaveOccurred())
+		l.shared = shared
+		return l, l.register()
+	}
+
+	newMetrics := func(conf *config.LogMetricsConfig) {
+		var err error
+		lm, err = register(conf)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	gather := func(name string) []*dto.Metric {
+		families, err := registry.Gather()
+		Expect(err).ToNot(HaveOccurred())
+		for _, f := range families {
+			if f.GetName() == metrics.LogMetricsNamespace+"_"+name {
+				return f.GetMetric()
+			}
+		}
+		return nil
+	}
+
+	counter := func(vec *prometheus.CounterVec, label string) float64 {
+		m := &dto.Metric{}
+		Expect(vec.WithLabelValues(label).Write(m)).To(Succeed())
+		return m.GetCounter().GetValue()
+	}
+
+	It("should count the lines matching the selector and the regex", func() {
+		newMetrics(&config.LogMetricsConfig{
+			Metrics: []config.LogMetric{{
+				Name:     "etcd_leader_changes_total",
+				Type:     config.LogMetricCounter,
+				Selector: `{container_name="etcd"}`,
+				Regex:    `elected leader (?P<member>[0-9a-f]+)`,
+				Labels:   []string{"namespace_name", "member"},
+			}},
+			MaxSeries:   10,
+			IdleTimeout: time.Minute,
+		})
+		lm.observe(etcd, "raft.node: 8e9e05c52164694d elected leader 8e9e05c52164694d at term 2")
+		lm.observe(etcd, "raft.node: 8e9e05c52164694d elected leader 8e9e05c52164694d at term 3")
+		lm.observe(etcd, "raft.node: 8e9e05c52164694d elected leader 91bc3c398fb3c146 at term 4")
+		lm.observe(etcd, "apply request took too long")
+		lm.observe(apiserver, "elected leader 8e9e05c52164694d")
+
+		series := gather("etcd_leader_changes_total")
+		Expect(series).To(HaveLen(2))
+		values := map[string]float64{}
+		for _, s := range series {
+			Expect(s.GetLabel()[1].GetName()).To(Equal("namespace_name"))
+			Expect(s.GetLabel()[1].GetValue()).To(Equal("shoot--dev--test"))
+			values[s.GetLabel()[0].GetValue()] = s.GetCounter().GetValue()
+		}
+		Expect(values).To(Equal(map[string]float64{"8e9e05c52164694d": 2, "91bc3c398fb3c146": 1}))
+	})
+
+	It("should set a gauge and observe a histogram from the captured value", func() {
+		newMetrics(&config.LogMetricsConfig{
+			Metrics: []config.LogMetric{
+				{
+					Name:  "etcd_db_size_bytes",
+					Type:  config.LogMetricGauge,
+					Regex: `db size: (?P<size>\d+)`,
+					Value: "size",
+				},
+				{
+					Name:    "apiserver_request_seconds",
+					Type:    config.LogMetricHistogram,
+					Regex:   `took (?P<seconds>[0-9.]+)s`,
+					Value:   "seconds",
+					Buckets: []float64{0.5, 1, 5},
+				},
+			},
+			MaxSeries:   10,
+			IdleTimeout: time.Minute,
+		})
+		lm.observe(etcd, "db size: 1024")
+		lm.observe(etcd, "db size: 2048")
+		lm.observe(apiserver, "request took 0.2s")
+		lm.observe(apiserver, "request took 3s")
+
+		gauge := gather("etcd_db_size_bytes")
+		Expect(gauge).To(HaveLen(1))
+		Expect(gauge[0].GetGauge().GetValue()).To(Equal(2048.0))
+
+		histogram := gather("apiserver_request_seconds")
+		Expect(histogram).To(HaveLen(1))
+		Expect(histogram[0].GetHistogram().GetSampleCount()).To(Equal(uint64(2)))
+		Expect(histogram[0].GetHistogram().GetSampleSum()).To(Equal(3.2))
+		var cumulative []uint64
+		for _, b := range histogram[0].GetHistogram().GetBucket() {
+			cumulative = append(cumulative, b.GetCumulativeCount())
+		}
+		Expect(cumulative).To(Equal([]uint64{1, 1, 2}))
+	})
+
+	It("should count a value which is not a number as error", func() {
+		newMetrics(&config.LogMetricsConfig{
+			Metrics: []config.LogMetric{{
+				Name:   "bytes_total",
+				Type:   config.LogMetricCounter,
+				Action: config.LogMetricAdd,
+				Regex:  `sent (?P<bytes>\S+) bytes`,
+				Value:  "bytes",
+			}},
+			MaxSeries:   10,
+			IdleTimeout: time.Minute,
+		})
+		before := counter(metrics.Errors, metrics.ErrorLogMetric)
+		lm.observe(etcd, "sent many bytes")
+		lm.observe(etcd, "sent -5 bytes")
+		Expect(counter(metrics.Errors, metrics.ErrorLogMetric)).To(Equal(before + 2))
+		Expect(gather("bytes_total")).To(BeEmpty())
+	})
+
+	It("should share the metrics of the outputs", func() {
+		conf := func(regex string) *config.LogMetricsConfig {
+			return &config.LogMetricsConfig{
+				Metrics:     []config.LogMetric{{Name: "shared_total", Type: config.LogMetricCounter, Regex: regex}},
+				MaxSeries:   10,
+				IdleTimeout: time.Minute,
+			}
+		}
+		first, err := register(conf("error"))
+		Expect(err).ToNot(HaveOccurred())
+		second, err := register(conf("error"))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = register(conf("warning"))
+		Expect(err).To(MatchError(ContainSubstring("defined differently")))
+
+		first.observe(etcd, "error")
+		second.observe(etcd, "error")
+		Expect(second.metrics[0]).To(BeIdenticalTo(first.metrics[0]))
+		series := gather("shared_total")
+		Expect(series).To(HaveLen(1))
+		Expect(series[0].GetCounter().GetValue()).To(Equal(2.0))
+
+		second.unregister()
+		Expect(gather("shared_total")).To(HaveLen(1))
+		first.unregister()
+		Expect(gather("shared_total")).To(BeEmpty())
+	})
+
+	It("should expire the idle series of the outputs", func() {
+		conf := &config.LogMetricsConfig{
+			Metrics:     []config.LogMetric{{Name: "idle_total", Type: config.LogMetricCounter, Regex: `error code=(?P<code>\d+)`, Labels: []string{"code"}}},
+			MaxSeries:   10,
+			IdleTimeout: time.Minute,
+		}
+		first, err := register(conf)
+		Expect(err).ToNot(HaveOccurred())
+		second, err := register(conf)
+		Expect(err).ToNot(HaveOccurred())
+
+		first.observe(etcd, "error code=1")
+		now = now.Add(45 * time.Second)
+		second.observe(etcd, "error code=2")
+		Expect(gather("idle_total")).To(HaveLen(2))
+
+		now = now.Add(30 * time.Second)
+		series := gather("idle_total")
+		Expect(series).To(HaveLen(1))
+		Expect(series[0].GetLabel()[0].GetValue()).To(Equal("2"))
+	})
+
+	It("should drop the series above the limit and expire the idle ones", func() {
+		newMetrics(&config.LogMetricsConfig{
+			Metrics: []config.LogMetric{{
+				Name:   "errors_total",
+				Type:   config.LogMetricCounter,
+				Regex:  `error code=(?P<code>\d+)`,
+				Labels: []string{"code"},
+			}},
+			MaxSeries:   2,
+			IdleTimeout: time.Minute,
+		})
+		before := counter(metrics.LogMetricsDroppedSeries, "errors_total")
+		lm.observe(etcd, "error code=1")
+		lm.observe(etcd, "error code=2")
+		lm.observe(etcd, "error code=3")
+		Expect(gather("errors_total")).To(HaveLen(2))
+		Expect(counter(metrics.LogMetricsDroppedSeries, "errors_total")).To(Equal(before + 1))
+
+		now = now.Add(30 * time.Second)
+		lm.observe(etcd, "error code=2")
+		now = now.Add(45 * time.Second)
+		series := gather("errors_total")
+		Expect(series).To(HaveLen(1))
+		Expect(series[0].GetLabel()[0].GetValue()).To(Equal("2"))
+
+		lm.observe(etcd, "error code=3")
+		Expect(gather("errors_total")).To(HaveLen(2))
+	})
+})
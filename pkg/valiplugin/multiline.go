@@ -0,0 +1,254 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+// multilineEntry is a record which is ready to be sent.
+type multilineEntry struct {
+	records map[string]interface{}
+	ts      time.Time
+}
+
+// multilineGroup is the pending entry of a stream.
+type multilineGroup struct {
+	multilineEntry
+	lines   []string
+	bytes   int
+	updated time.Time
+}
+
+// multilinePattern is the compiled config.MultilinePattern.
+type multilinePattern struct {
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+}
+
+func newMultilinePattern(p config.MultilinePattern) (*multilinePattern, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	res := &multilinePattern{}
+	if p.Start != "" {
+		res.start = regexp.MustCompile(p.Start)
+	}
+	if p.Continuation != "" {
+		res.continuation = regexp.MustCompile(p.Continuation)
+	}
+	return res, nil
+}
+
+// multiline joins the lines of a stream, e.g. stack traces, into one record.
+type multiline struct {
+	key string
+	// pattern is used for the streams without a preset. Without pattern their lines are passed through.
+	pattern      *multilinePattern
+	presets      map[string]*multilinePattern
+	flushTimeout time.Duration
+	maxLines     int
+	maxBytes     int
+
+	mu      sync.Mutex
+	streams map[string]*multilineGroup
+	now     func() time.Time
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newMultiline returns the multiline reassembly of the config. With presets the streams
+// can select one of the config.MultilinePresets, also when the config is nil.
+func newMultiline(conf *config.MultilineConfig, presets bool) (*multiline, error) {
+	if conf == nil {
+		if !presets {
+			return nil, nil
+		}
+		conf = &config.MultilineConfig{
+			Key:          config.DefaultMultilineKey,
+			FlushTimeout: config.DefaultMultilineFlushTimeout,
+			MaxLines:     config.DefaultMultilineMaxLines,
+			MaxBytes:     config.DefaultMultilineMaxBytes,
+		}
+	}
+	m := &multiline{
+		key:          conf.Key,
+		flushTimeout: conf.FlushTimeout,
+		maxLines:     conf.MaxLines,
+		maxBytes:     conf.MaxBytes,
+		streams:      map[string]*multilineGroup{},
+		now:          time.Now,
+		done:         make(chan struct{}),
+	}
+	if conf.Pattern != (config.MultilinePattern{}) {
+		var err error
+		if m.pattern, err = newMultilinePattern(conf.Pattern); err != nil {
+			return nil, err
+		}
+	}
+	if presets {
+		m.presets = make(map[string]*multilinePattern, len(config.MultilinePresets))
+		for name, p := range config.MultilinePresets {
+			pattern, err := newMultilinePattern(p)
+			if err != nil {
+				return nil, err
+			}
+			m.presets[name] = pattern
+		}
+	}
+	return m, nil
+}
+
+// add adds the record to its stream and returns the entries which are complete, in order.
+// The preset, if known, replaces the pattern for the stream. Records without a stream,
+// without a string line or without a pattern are passed through.
+func (m *multiline) add(stream string, records map[string]interface{}, ts time.Time, preset string) []multilineEntry {
+	pattern := m.pattern
+	if p, ok := m.presets[preset]; ok {
+		pattern = p
+	}
+	line, ok := records[m.key].(string)
+	if stream == "" || !ok {
+		return []multilineEntry{{records: records, ts: ts}}
+	}
+	line = strings.TrimSuffix(line, "\n")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var res []multilineEntry
+	group, pending := m.streams[stream]
+	if pattern == nil {
+		if pending {
+			res = append(res, m.flush(stream, metrics.MultilineFlushNewEntry))
+		}
+		return append(res, multilineEntry{records: records, ts: ts})
+	}
+	isStart := pattern.start != nil && pattern.start.MatchString(line)
+	isContinuation := pending && !isStart && (pattern.continuation == nil || pattern.continuation.MatchString(line))
+
+	if isContinuation {
+		if group.bytes+len(line) > m.maxBytes {
+			res = append(res, m.flush(stream, metrics.MultilineFlushMaxBytes))
+			m.begin(stream, records, ts, line)
+			return res
+		}
+		group.lines = append(group.lines, line)
+		group.bytes += len(line)
+		group.updated = m.now()
+		if len(group.lines) >= m.maxLines {
+			res = append(res, m.flush(stream, metrics.MultilineFlushMaxLines))
+		}
+		return res
+	}
+
+	if pending {
+		res = append(res, m.flush(stream, metrics.MultilineFlushNewEntry))
+	}
+	if pattern.start != nil && !isStart {
+		return append(res, multilineEntry{records: records, ts: ts})
+	}
+	m.begin(stream, records, ts, line)
+	return res
+}
+
+// begin starts a new pending entry for the stream. The caller holds the lock.
+func (m *multiline) begin(stream string, records map[string]interface{}, ts time.Time, line string) {
+	m.streams[stream] = &multilineGroup{
+		multilineEntry: multilineEntry{records: records, ts: ts},
+		lines:          []string{line},
+		bytes:          len(line),
+		updated:        m.now(),
+	}
+}
+
+// flushExpired returns the pending entries which did not get a new line within the flush timeout.
+func (m *multiline) flushExpired() []multilineEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var res []multilineEntry
+	deadline := m.now().Add(-m.flushTimeout)
+	for stream, group := range m.streams {
+		if group.updated.Before(deadline) {
+			res = append(res, m.flush(stream, metrics.MultilineFlushTimeout))
+		}
+	}
+	return res
+}
+
+// flushAll returns all pending entries.
+func (m *multiline) flushAll() []multilineEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := make([]multilineEntry, 0, len(m.streams))
+	for stream := range m.streams {
+		res = append(res, m.flush(stream, metrics.MultilineFlushClose))
+	}
+	return res
+}
+
+// flush removes the pending entry of the stream and joins its lines. The caller holds the lock.
+func (m *multiline) flush(stream, reason string) multilineEntry {
+	group := m.streams[stream]
+	delete(m.streams, stream)
+	if len(group.lines) > 1 {
+		group.records[m.key] = strings.Join(group.lines, "\n")
+		metrics.MultilineFlushes.WithLabelValues(reason).Inc()
+	}
+	return group.multilineEntry
+}
+
+// startFlusher sends the expired entries in the background until stop is called.
+func (m *multiline) startFlusher(send func(multilineEntry)) {
+	m.wg.Add(1)
+	go m.run(send)
+}
+
+func (m *multiline) run(send func(multilineEntry)) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.flushTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			for _, e := range m.flushExpired() {
+				send(e)
+			}
+		}
+	}
+}
+
+// stop ends the flusher and returns the pending entries.
+func (m *multiline) stop() []multilineEntry {
+	close(m.done)
+	m.wg.Wait()
+	return m.flushAll()
+}
+
+// getStream returns the namespace/pod/container key of the record,
+// or the tag if the kubernetes metadata is missing.
+func getStream(records map[string]interface{}, tagKey string) string {
+	if kubernetes, ok := records["kubernetes"].(map[string]interface{}); ok {
+		namespace, _ := getRecordValue(namespaceName, kubernetes)
+		pod, _ := getRecordValue(podName, kubernetes)
+		container, _ := getRecordValue(containerName, kubernetes)
+		if namespace != "" || pod != "" || container != "" {
+			return namespace + "/" + pod + "/" + container
+		}
+	}
+	tag, _ := getRecordValue(tagKey, records)
+	return tag
+}
@@ -0,0 +1,218 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+var _ = Describe("Multiline", func() {
+	type multilineArgs struct {
+		conf     config.MultilineConfig
+		lines    []string
+		expected []string
+	}
+
+	newConf := func(preset string) config.MultilineConfig {
+		return config.MultilineConfig{
+			Preset:       preset,
+			Pattern:      config.MultilinePresets[preset],
+			Key:          config.DefaultMultilineKey,
+			FlushTimeout: config.DefaultMultilineFlushTimeout,
+			MaxLines:     config.DefaultMultilineMaxLines,
+			MaxBytes:     config.DefaultMultilineMaxBytes,
+		}
+	}
+
+	lines := func(entries []multilineEntry) []string {
+		var res []string
+		for _, e := range entries {
+			res = append(res, e.records["log"].(string))
+		}
+		return res
+	}
+
+	DescribeTable("#add",
+		func(args multilineArgs) {
+			m, err := newMultiline(&args.conf, false)
+			Expect(err).ToNot(HaveOccurred())
+
+			var got []string
+			for _, line := range args.lines {
+				got = append(got, lines(m.add("garden/pod/container", map[string]interface{}{"log": line}, time.Now(), ""))...)
+			}
+			got = append(got, lines(m.flushAll())...)
+			Expect(got).To(Equal(args.expected))
+		},
+		Entry("go panic", multilineArgs{
+			conf: newConf(config.MultilinePresetGo),
+			lines: []string{
+				"starting\n",
+				"panic: runtime error: invalid memory address or nil pointer dereference\n",
+				"[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4553c6]\n",
+				"\n",
+				"goroutine 1 [running]:\n",
+				"main.main()\n",
+				"\t/go/src/app/main.go:12 +0x26\n",
+				"exit status 2\n",
+				"restarted\n",
+			},
+			expected: []string{
+				"starting\n",
+				"panic: runtime error: invalid memory address or nil pointer dereference\n" +
+					"[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4553c6]\n\n" +
+					"goroutine 1 [running]:\nmain.main()\n\t/go/src/app/main.go:12 +0x26\nexit status 2",
+				"restarted\n",
+			},
+		}),
+		Entry("java exception", multilineArgs{
+			conf: newConf(config.MultilinePresetJava),
+			lines: []string{
+				"Exception in thread \"main\" java.lang.IllegalStateException: boom",
+				"\tat com.example.App.run(App.java:10)",
+				"\tat com.example.App.main(App.java:5)",
+				"Caused by: java.io.IOException: closed",
+				"\t... 2 more",
+				"next line",
+			},
+			expected: []string{
+				"Exception in thread \"main\" java.lang.IllegalStateException: boom\n" +
+					"\tat com.example.App.run(App.java:10)\n\tat com.example.App.main(App.java:5)\n" +
+					"Caused by: java.io.IOException: closed\n\t... 2 more",
+				"next line",
+			},
+		}),
+		Entry("python traceback", multilineArgs{
+			conf: newConf(config.MultilinePresetPython),
+			lines: []string{
+				"Traceback (most recent call last):",
+				"  File \"app.py\", line 3, in <module>",
+				"    main()",
+				"ValueError: bad value",
+				"INFO done",
+			},
+			expected: []string{
+				"Traceback (most recent call last):\n  File \"app.py\", line 3, in <module>\n    main()\nValueError: bad value",
+				"INFO done",
+			},
+		}),
+		Entry("custom start pattern", multilineArgs{
+			conf: config.MultilineConfig{
+				Pattern:  config.MultilinePattern{Start: `^\d{4}-\d{2}-\d{2} `},
+				Key:      config.DefaultMultilineKey,
+				MaxLines: 10, MaxBytes: 1024, FlushTimeout: time.Second,
+			},
+			lines:    []string{"2024-01-01 first", "  detail", "2024-01-01 second"},
+			expected: []string{"2024-01-01 first\n  detail", "2024-01-01 second"},
+		}),
+		Entry("max lines", multilineArgs{
+			conf: config.MultilineConfig{
+				Pattern:  config.MultilinePattern{Continuation: `^\s`},
+				Key:      config.DefaultMultilineKey,
+				MaxLines: 2, MaxBytes: 1024, FlushTimeout: time.Second,
+			},
+			lines:    []string{"a", " 1", " 2", " 3"},
+			expected: []string{"a\n 1", " 2\n 3"},
+		}),
+		Entry("max bytes", multilineArgs{
+			conf: config.MultilineConfig{
+				Pattern:  config.MultilinePattern{Continuation: `^\s`},
+				Key:      config.DefaultMultilineKey,
+				MaxLines: 10, MaxBytes: 6, FlushTimeout: time.Second,
+			},
+			lines:    []string{"abc", " de", " fg", " h"},
+			expected: []string{"abc\n de", " fg\n h"},
+		}),
+	)
+
+	It("should flush the entries after the flush timeout", func() {
+		conf := newConf(config.MultilinePresetJava)
+		m, err := newMultiline(&conf, false)
+		Expect(err).ToNot(HaveOccurred())
+		now := time.Now()
+		m.now = func() time.Time { return now }
+
+		Expect(m.add("garden/a/c", map[string]interface{}{"log": "java.lang.Exception"}, now, "")).To(BeEmpty())
+		Expect(m.add("garden/a/c", map[string]interface{}{"log": "\tat A.a(A.java:1)"}, now, "")).To(BeEmpty())
+		Expect(m.add("garden/b/c", map[string]interface{}{"log": "java.io.IOException: other stream"}, now, "")).To(BeEmpty())
+		Expect(m.flushExpired()).To(BeEmpty())
+
+		now = now.Add(conf.FlushTimeout + time.Millisecond)
+		Expect(lines(m.flushExpired())).To(ConsistOf("java.lang.Exception\n\tat A.a(A.java:1)", "java.io.IOException: other stream"))
+		Expect(m.flushAll()).To(BeEmpty())
+	})
+
+	It("should not hold back the ordinary lines of the java preset", func() {
+		conf := newConf(config.MultilinePresetJava)
+		m, err := newMultiline(&conf, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lines(m.add("garden/a/c", map[string]interface{}{"log": "INFO started in 2s"}, time.Now(), ""))).To(Equal([]string{"INFO started in 2s"}))
+		Expect(m.add("garden/a/c", map[string]interface{}{"log": "Exception in thread \"main\" java.lang.Error"}, time.Now(), "")).To(BeEmpty())
+	})
+
+	It("should count the flushed entries which failed to be sent", func() {
+		l := &vali{cfg: &config.Config{PluginConfig: config.PluginConfig{LineFormat: config.JSONFormat}}, defaultClient: &linesClient{}, converter: newConverter(nil), logger: logger}
+		dropped := func() float64 {
+			m := &dto.Metric{}
+			Expect(metrics.MultilineDroppedEntries.Write(m)).To(Succeed())
+			return m.GetCounter().GetValue()
+		}
+		before := dropped()
+		l.sendMultilineEntry(multilineEntry{records: map[string]interface{}{"log": "ok"}, ts: time.Now()})
+		l.sendMultilineEntry(multilineEntry{records: map[string]interface{}{"log": "fail"}, ts: time.Now()})
+		Expect(dropped()).To(Equal(before + 1))
+	})
+
+	It("should pass through records without stream or line", func() {
+		conf := newConf(config.MultilinePresetJava)
+		m, err := newMultiline(&conf, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m.add("", map[string]interface{}{"log": "line"}, time.Now(), "")).To(HaveLen(1))
+		Expect(m.add("garden/a/c", map[string]interface{}{"message": "line"}, time.Now(), "")).To(HaveLen(1))
+	})
+
+	It("should join the lines of the streams with a preset only", func() {
+		m, err := newMultiline(nil, true)
+		Expect(err).ToNot(HaveOccurred())
+
+		var got []multilineEntry
+		got = append(got, m.add("garden/a/c", map[string]interface{}{"log": "java.lang.Exception"}, time.Now(), config.MultilinePresetJava)...)
+		got = append(got, m.add("garden/b/c", map[string]interface{}{"log": "java.lang.Exception"}, time.Now(), "")...)
+		got = append(got, m.add("garden/b/c", map[string]interface{}{"log": "\tat A.a(A.java:1)"}, time.Now(), "")...)
+		got = append(got, m.add("garden/a/c", map[string]interface{}{"log": "\tat A.a(A.java:1)"}, time.Now(), config.MultilinePresetJava)...)
+		got = append(got, m.add("garden/a/c", map[string]interface{}{"log": "done"}, time.Now(), "unknown")...)
+		Expect(lines(got)).To(Equal([]string{
+			"java.lang.Exception",
+			"\tat A.a(A.java:1)",
+			"java.lang.Exception\n\tat A.a(A.java:1)",
+			"done",
+		}))
+	})
+
+	It("should not reassemble without pattern and presets", func() {
+		m, err := newMultiline(nil, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m).To(BeNil())
+	})
+
+	DescribeTable("#getStream",
+		func(records map[string]interface{}, expected string) {
+			Expect(getStream(records, "tag")).To(Equal(expected))
+		},
+		Entry("kubernetes metadata", map[string]interface{}{
+			"kubernetes": map[string]interface{}{"namespace_name": "garden", "pod_name": "vali-0", "container_name": "vali"},
+			"tag":        "kubernetes.var.log.containers.vali-0_garden_vali-123.log",
+		}, "garden/vali-0/vali"),
+		Entry("tag", map[string]interface{}{"tag": "systemd.kubelet"}, "systemd.kubelet"),
+		Entry("nothing", map[string]interface{}{"log": "line"}, ""),
+	)
+})
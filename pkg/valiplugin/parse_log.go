@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-logfmt/logfmt"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+// parseLogProcessor parses the embedded json or logfmt payload of the log field
+// and merges its keys into the record.
+type parseLogProcessor struct {
+	format       string
+	key          string
+	prefix       string
+	keepOriginal bool
+}
+
+func newParseLogProcessor(conf *config.ParseLogConfig) Processor {
+	if conf == nil {
+		return nil
+	}
+	return &parseLogProcessor{
+		format:       conf.Format,
+		key:          conf.Key,
+		prefix:       conf.Prefix,
+		keepOriginal: conf.KeepOriginal,
+	}
+}
+
+// Process merges the parsed keys into the record. Keys which are already present are not overwritten.
+// Lines which are not in the configured format are left as they are.
+func (p *parseLogProcessor) Process(record *Record) error {
+	line, ok := record.Fields[p.key].(string)
+	if !ok {
+		return nil
+	}
+	trimmed := strings.TrimSpace(line)
+
+	var (
+		parsed map[string]interface{}
+		err    error
+	)
+	switch {
+	case p.format != config.ParseLogLogfmt && strings.HasPrefix(trimmed, "{"):
+		parsed, err = parseJSONObject(trimmed)
+	case p.format != config.ParseLogJSON:
+		parsed = parseLogfmt(trimmed)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %v", p.key, err)
+	}
+	if len(parsed) == 0 {
+		return nil
+	}
+
+	if !p.keepOriginal {
+		delete(record.Fields, p.key)
+	}
+	for k, v := range parsed {
+		k = p.prefix + k
+		if _, ok := record.Fields[k]; !ok {
+			record.Fields[k] = v
+		}
+	}
+	return nil
+}
+
+// parseJSONObject keeps the numbers as json.Number, so they are serialized again without loss.
+func parseJSONObject(s string) (map[string]interface{}, error) {
+	var res map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// parseLogfmt returns nil unless every token of the line is a key=value pair with a value,
+// so plain text lines are not mistaken for logfmt.
+func parseLogfmt(s string) map[string]interface{} {
+	if !strings.Contains(s, "=") {
+		return nil
+	}
+	res := map[string]interface{}{}
+	dec := logfmt.NewDecoder(strings.NewReader(s))
+	for dec.ScanRecord() {
+		for dec.ScanKeyval() {
+			if dec.Value() == nil {
+				return nil
+			}
+			res[string(dec.Key())] = string(dec.Value())
+		}
+	}
+	if dec.Err() != nil {
+		return nil
+	}
+	return res
+}
@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("ParseLog", func() {
+	type parseLogArgs struct {
+		conf     config.ParseLogConfig
+		fields   map[string]interface{}
+		expected map[string]interface{}
+		wantErr  bool
+	}
+
+	DescribeTable("#Process",
+		func(args parseLogArgs) {
+			p := newParseLogProcessor(&args.conf)
+			record := &Record{Fields: args.fields}
+			err := p.Process(record)
+			if args.wantErr {
+				Expect(err).To(HaveOccurred())
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(record.Fields).To(Equal(args.expected))
+		},
+		Entry("json", parseLogArgs{
+			conf:   config.ParseLogConfig{Format: config.ParseLogJSON, Key: "log"},
+			fields: map[string]interface{}{"log": `{"level":"info","msg":"started","count":12345678901234567890,"nested":{"a":"b"}}` + "\n", "stream": "stdout"},
+			expected: map[string]interface{}{
+				"level":  "info",
+				"msg":    "started",
+				"count":  json.Number("12345678901234567890"),
+				"nested": map[string]interface{}{"a": "b"},
+				"stream": "stdout",
+			},
+		}),
+		Entry("json with prefix keeps the original and the existing keys", parseLogArgs{
+			conf:     config.ParseLogConfig{Format: config.ParseLogJSON, Key: "log", Prefix: "log_", KeepOriginal: true},
+			fields:   map[string]interface{}{"log": `{"level":"info","stream":"x"}`, "log_stream": "stdout"},
+			expected: map[string]interface{}{"log": `{"level":"info","stream":"x"}`, "log_level": "info", "log_stream": "stdout"},
+		}),
+		Entry("json ignores logfmt", parseLogArgs{
+			conf:     config.ParseLogConfig{Format: config.ParseLogJSON, Key: "log"},
+			fields:   map[string]interface{}{"log": `level=info msg=started`},
+			expected: map[string]interface{}{"log": `level=info msg=started`},
+		}),
+		Entry("broken json", parseLogArgs{
+			conf:     config.ParseLogConfig{Format: config.ParseLogAuto, Key: "log"},
+			fields:   map[string]interface{}{"log": `{"level":`},
+			expected: map[string]interface{}{"log": `{"level":`},
+			wantErr:  true,
+		}),
+		Entry("logfmt", parseLogArgs{
+			conf:     config.ParseLogConfig{Format: config.ParseLogLogfmt, Key: "log"},
+			fields:   map[string]interface{}{"log": `time="2024-01-02T15:04:05Z" level=info msg="reconciling shoot"`},
+			expected: map[string]interface{}{"time": "2024-01-02T15:04:05Z", "level": "info", "msg": "reconciling shoot"},
+		}),
+		Entry("auto detects json", parseLogArgs{
+			conf:     config.ParseLogConfig{Format: config.ParseLogAuto, Key: "message"},
+			fields:   map[string]interface{}{"message": ` {"level":"warn"}`},
+			expected: map[string]interface{}{"level": "warn"},
+		}),
+		Entry("auto leaves plain text", parseLogArgs{
+			conf:     config.ParseLogConfig{Format: config.ParseLogAuto, Key: "log"},
+			fields:   map[string]interface{}{"log": "Error: retry=3 failed"},
+			expected: map[string]interface{}{"log": "Error: retry=3 failed"},
+		}),
+		Entry("missing key", parseLogArgs{
+			conf:     config.ParseLogConfig{Format: config.ParseLogAuto, Key: "log"},
+			fields:   map[string]interface{}{"message": "level=info"},
+			expected: map[string]interface{}{"message": "level=info"},
+		}),
+	)
+})
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+// podAnnotations applies the logging.gardener.cloud pod annotations of the kubernetes record.
+type podAnnotations struct {
+	conf   *config.PodAnnotationsConfig
+	random func() float64
+}
+
+func newPodAnnotations(conf *config.PodAnnotationsConfig) *podAnnotations {
+	if conf == nil {
+		return nil
+	}
+	return &podAnnotations{conf: conf, random: rand.Float64}
+}
+
+// of returns the allowed annotations of the record by their name without prefix.
+// Pods in namespaces which are not allowed have no annotations.
+func (a *podAnnotations) of(records map[string]interface{}) map[string]string {
+	kube, ok := records["kubernetes"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	namespace, _ := kube[namespaceName].(string)
+	if !a.conf.AllowsNamespace(namespace) {
+		return nil
+	}
+	annotations, ok := kube[annotationsKey].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var res map[string]string
+	for _, name := range a.conf.Annotations {
+		value, ok := annotations[config.PodAnnotationPrefix+name].(string)
+		if !ok {
+			continue
+		}
+		if res == nil {
+			res = map[string]string{}
+		}
+		res[name] = value
+	}
+	return res
+}
+
+// drop reports if the record is dropped by the drop or sample-rate annotation and returns the annotation.
+func (a *podAnnotations) drop(annotations map[string]string) (string, bool) {
+	if value, ok := annotations[config.PodAnnotationDrop]; ok {
+		drop, err := strconv.ParseBool(value)
+		if err != nil {
+			metrics.Errors.WithLabelValues(metrics.ErrorPodAnnotation).Inc()
+		} else if drop {
+			return config.PodAnnotationDrop, true
+		}
+	}
+	if value, ok := annotations[config.PodAnnotationSampleRate]; ok {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			metrics.Errors.WithLabelValues(metrics.ErrorPodAnnotation).Inc()
+		} else if a.random() >= rate {
+			return config.PodAnnotationSampleRate, true
+		}
+	}
+	return "", false
+}
+
+// addExtraLabels adds the labels of the extra-labels annotation, e.g. "team=logging,tier=backend".
+// Labels which are already set are not overwritten.
+func (a *podAnnotations) addExtraLabels(annotations map[string]string, lbs model.LabelSet) error {
+	value, ok := annotations[config.PodAnnotationExtraLabels]
+	if !ok || value == "" {
+		return nil
+	}
+	extra, err := config.ParseExtraLabels(value)
+	if err != nil {
+		metrics.Errors.WithLabelValues(metrics.ErrorPodAnnotation).Inc()
+		return fmt.Errorf("invalid %s%s annotation: %v", config.PodAnnotationPrefix, config.PodAnnotationExtraLabels, err)
+	}
+	for name, val := range extra {
+		if _, ok := lbs[name]; !ok {
+			lbs[name] = val
+		}
+	}
+	return nil
+}
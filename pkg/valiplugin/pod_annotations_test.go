@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("Pod annotations", func() {
+	a := newPodAnnotations(&config.PodAnnotationsConfig{
+		Annotations: []string{config.PodAnnotationDrop, config.PodAnnotationExtraLabels, config.PodAnnotationSampleRate},
+		Namespaces:  []string{"garden", "kube-system"},
+	})
+	a.random = func() float64 { return 0.5 }
+
+	record := func(namespace string, annotations map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"kubernetes": map[string]interface{}{namespaceName: namespace, podName: "pod", annotationsKey: annotations},
+		}
+	}
+
+	DescribeTable("#of",
+		func(records map[string]interface{}, expected map[string]string) {
+			Expect(a.of(records)).To(Equal(expected))
+		},
+		Entry("allowed annotations", record("garden", map[string]interface{}{
+			"logging.gardener.cloud/drop":             "true",
+			"logging.gardener.cloud/extra-labels":     "team=logging",
+			"logging.gardener.cloud/multiline-preset": "java",
+			"other.io/drop":                           "true",
+		}), map[string]string{config.PodAnnotationDrop: "true", config.PodAnnotationExtraLabels: "team=logging"}),
+		Entry("namespace which is not allowed", record("shoot--dev--test", map[string]interface{}{
+			"logging.gardener.cloud/drop": "true",
+		}), nil),
+		Entry("without annotations", map[string]interface{}{
+			"kubernetes": map[string]interface{}{namespaceName: "garden", podName: "pod"},
+		}, nil),
+		Entry("without kubernetes metadata", map[string]interface{}{"log": "line"}, nil),
+	)
+
+	DescribeTable("#drop",
+		func(annotations map[string]string, expectedAnnotation string, expected bool) {
+			annotation, drop := a.drop(annotations)
+			Expect(drop).To(Equal(expected))
+			Expect(annotation).To(Equal(expectedAnnotation))
+		},
+		Entry("drop", map[string]string{config.PodAnnotationDrop: "true"}, config.PodAnnotationDrop, true),
+		Entry("drop false", map[string]string{config.PodAnnotationDrop: "false"}, "", false),
+		Entry("invalid drop", map[string]string{config.PodAnnotationDrop: "yes"}, "", false),
+		Entry("sampled out", map[string]string{config.PodAnnotationSampleRate: "0.1"}, config.PodAnnotationSampleRate, true),
+		Entry("sampled in", map[string]string{config.PodAnnotationSampleRate: "0.75"}, "", false),
+		Entry("invalid sample rate", map[string]string{config.PodAnnotationSampleRate: "2"}, "", false),
+		Entry("no annotations", nil, "", false),
+	)
+
+	DescribeTable("#addExtraLabels",
+		func(value string, expected model.LabelSet, wantErr bool) {
+			lbs := model.LabelSet{"app": "vali"}
+			err := a.addExtraLabels(map[string]string{config.PodAnnotationExtraLabels: value}, lbs)
+			if wantErr {
+				Expect(err).To(HaveOccurred())
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(lbs).To(Equal(expected))
+		},
+		Entry("labels", "team=logging, tier=backend", model.LabelSet{"app": "vali", "team": "logging", "tier": "backend"}, false),
+		Entry("existing labels are kept", "app=other,team=logging", model.LabelSet{"app": "vali", "team": "logging"}, false),
+		Entry("invalid label name", "team-name=logging", model.LabelSet{"app": "vali"}, true),
+		Entry("missing value", "team", model.LabelSet{"app": "vali"}, true),
+		Entry("reserved tenant label", "__tenant_id__=other", model.LabelSet{"app": "vali"}, true),
+		Entry("reserved client label", "team=logging,__gardener_multitenant_id__=other", model.LabelSet{"app": "vali"}, true),
+		Entry("empty", "", model.LabelSet{"app": "vali"}, false),
+	)
+})
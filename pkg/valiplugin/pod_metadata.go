@@ -0,0 +1,203 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+// Keys of the kubernetes record added by the pod metadata enrichment
+const (
+	podIDKey       = "pod_id"
+	hostKey        = "host"
+	labelsKey      = "labels"
+	annotationsKey = "annotations"
+	ownerKindKey   = "owner_kind"
+	ownerNameKey   = "owner_name"
+)
+
+// podMetadata adds the metadata of the pods of the node to the kubernetes record,
+// like the fluent-bit kubernetes filter does.
+type podMetadata struct {
+	indexer     cache.Indexer
+	annotations []string
+	cache       *metadataCache
+}
+
+func newPodMetadata(conf *config.PodMetadataConfig, indexer cache.Indexer) *podMetadata {
+	if conf == nil {
+		return nil
+	}
+	return &podMetadata{
+		indexer:     indexer,
+		annotations: conf.Annotations,
+		cache:       newMetadataCache(conf.CacheTTL, conf.CacheSize),
+	}
+}
+
+// enrich adds the pod metadata to the kubernetes record. Keys which are already set are kept,
+// so the metadata of the upstream kubernetes filter takes precedence.
+func (p *podMetadata) enrich(records map[string]interface{}) {
+	kube, ok := records["kubernetes"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	namespace, _ := kube[namespaceName].(string)
+	pod, _ := kube[podName].(string)
+	if namespace == "" || pod == "" {
+		return
+	}
+
+	metadata, ok := p.lookup(namespace + "/" + pod)
+	if !ok {
+		return
+	}
+	for k, v := range metadata {
+		m, isMap := v.(map[string]interface{})
+		existing, ok := kube[k]
+		switch {
+		case !ok && isMap:
+			// The cached maps are copied, the later stages may modify the record.
+			copied := make(map[string]interface{}, len(m))
+			for mk, mv := range m {
+				copied[mk] = mv
+			}
+			kube[k] = copied
+		case !ok:
+			kube[k] = v
+		case isMap:
+			// The labels and annotations are merged key by key.
+			if existingMap, ok := existing.(map[string]interface{}); ok {
+				for mk, mv := range m {
+					if _, ok := existingMap[mk]; !ok {
+						existingMap[mk] = mv
+					}
+				}
+			}
+		}
+	}
+}
+
+func (p *podMetadata) lookup(key string) (map[string]interface{}, bool) {
+	if metadata, ok := p.cache.get(key); ok {
+		metrics.PodMetadataLookups.WithLabelValues(metrics.PodMetadataCacheHit).Inc()
+		return metadata, true
+	}
+
+	obj, exists, err := p.indexer.GetByKey(key)
+	if err != nil || !exists {
+		metrics.PodMetadataLookups.WithLabelValues(metrics.PodMetadataNotFound).Inc()
+		return nil, false
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		metrics.PodMetadataLookups.WithLabelValues(metrics.PodMetadataNotFound).Inc()
+		return nil, false
+	}
+
+	metrics.PodMetadataLookups.WithLabelValues(metrics.PodMetadataInformer).Inc()
+	metadata := p.metadataOf(pod)
+	p.cache.add(key, metadata)
+	return metadata, true
+}
+
+func (p *podMetadata) metadataOf(pod *corev1.Pod) map[string]interface{} {
+	res := map[string]interface{}{
+		podIDKey: string(pod.UID),
+	}
+	if pod.Spec.NodeName != "" {
+		res[hostKey] = pod.Spec.NodeName
+	}
+	if len(pod.Labels) > 0 {
+		labels := make(map[string]interface{}, len(pod.Labels))
+		for k, v := range pod.Labels {
+			labels[k] = v
+		}
+		res[labelsKey] = labels
+	}
+	annotations := map[string]interface{}{}
+	for _, k := range p.annotations {
+		if v, ok := pod.Annotations[k]; ok {
+			annotations[k] = v
+		}
+	}
+	if len(annotations) > 0 {
+		res[annotationsKey] = annotations
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		res[ownerKindKey] = owner.Kind
+		res[ownerNameKey] = owner.Name
+	}
+	return res
+}
+
+// metadataCache is a least recently used cache whose entries expire after the ttl.
+type metadataCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+type metadataCacheEntry struct {
+	key      string
+	metadata map[string]interface{}
+	expires  time.Time
+}
+
+func newMetadataCache(ttl time.Duration, size int) *metadataCache {
+	return &metadataCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+func (c *metadataCache) get(key string) (map[string]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*metadataCacheEntry)
+	if c.now().After(entry.expires) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return entry.metadata, true
+}
+
+func (c *metadataCache) add(key string, metadata map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &metadataCacheEntry{key: key, metadata: metadata, expires: c.now().Add(c.ttl)}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*metadataCacheEntry).key)
+	}
+}
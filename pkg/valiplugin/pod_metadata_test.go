@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/ptr"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("Pod metadata", func() {
+	var (
+		indexer cache.Indexer
+		p       *podMetadata
+		now     time.Time
+	)
+
+	newPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "garden",
+				UID:         types.UID("uid-" + name),
+				Labels:      map[string]string{"app": "vali", "role": "logging"},
+				Annotations: map[string]string{"checksum/config": "abc", "other": "x"},
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "StatefulSet", Name: "vali", Controller: ptr.To(true)},
+				},
+			},
+			Spec: corev1.PodSpec{NodeName: "node-1"},
+		}
+	}
+
+	BeforeEach(func() {
+		indexer = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		Expect(indexer.Add(newPod("vali-0"))).To(Succeed())
+		p = newPodMetadata(&config.PodMetadataConfig{
+			NodeName:    "node-1",
+			Annotations: []string{"checksum/config"},
+			CacheTTL:    time.Minute,
+			CacheSize:   2,
+		}, indexer)
+		now = time.Now()
+		p.cache.now = func() time.Time { return now }
+	})
+
+	It("should enrich the kubernetes record from the informer", func() {
+		records := map[string]interface{}{
+			"kubernetes": map[string]interface{}{podName: "vali-0", namespaceName: "garden", containerName: "vali"},
+		}
+		p.enrich(records)
+		Expect(records["kubernetes"]).To(Equal(map[string]interface{}{
+			podName:        "vali-0",
+			namespaceName:  "garden",
+			containerName:  "vali",
+			podIDKey:       "uid-vali-0",
+			hostKey:        "node-1",
+			labelsKey:      map[string]interface{}{"app": "vali", "role": "logging"},
+			annotationsKey: map[string]interface{}{"checksum/config": "abc"},
+			ownerKindKey:   "StatefulSet",
+			ownerNameKey:   "vali",
+		}))
+	})
+
+	It("should keep the metadata of the upstream kubernetes filter", func() {
+		records := map[string]interface{}{
+			"kubernetes": map[string]interface{}{
+				podName:       "vali-0",
+				namespaceName: "garden",
+				hostKey:       "node-from-filter",
+				labelsKey:     map[string]interface{}{"app": "from-filter"},
+			},
+		}
+		p.enrich(records)
+		kube := records["kubernetes"].(map[string]interface{})
+		Expect(kube[hostKey]).To(Equal("node-from-filter"))
+		Expect(kube[labelsKey]).To(Equal(map[string]interface{}{"app": "from-filter", "role": "logging"}))
+	})
+
+	It("should not modify the cache through the record", func() {
+		records := map[string]interface{}{"kubernetes": map[string]interface{}{podName: "vali-0", namespaceName: "garden"}}
+		p.enrich(records)
+		records["kubernetes"].(map[string]interface{})[labelsKey].(map[string]interface{})["app"] = "changed"
+
+		records = map[string]interface{}{"kubernetes": map[string]interface{}{podName: "vali-0", namespaceName: "garden"}}
+		p.enrich(records)
+		Expect(records["kubernetes"].(map[string]interface{})[labelsKey]).To(HaveKeyWithValue("app", "vali"))
+	})
+
+	It("should leave records of unknown pods and without kubernetes metadata as they are", func() {
+		records := map[string]interface{}{"kubernetes": map[string]interface{}{podName: "unknown", namespaceName: "garden"}}
+		p.enrich(records)
+		Expect(records).To(Equal(map[string]interface{}{"kubernetes": map[string]interface{}{podName: "unknown", namespaceName: "garden"}}))
+
+		records = map[string]interface{}{"log": "line"}
+		p.enrich(records)
+		Expect(records).To(Equal(map[string]interface{}{"log": "line"}))
+	})
+
+	It("should keep the metadata of a deleted pod until the ttl expires", func() {
+		records := func() map[string]interface{} {
+			return map[string]interface{}{"kubernetes": map[string]interface{}{podName: "vali-0", namespaceName: "garden"}}
+		}
+		p.enrich(records())
+		Expect(indexer.Delete(newPod("vali-0"))).To(Succeed())
+
+		r := records()
+		p.enrich(r)
+		Expect(r["kubernetes"]).To(HaveKeyWithValue(ownerNameKey, "vali"))
+
+		now = now.Add(2 * time.Minute)
+		r = records()
+		p.enrich(r)
+		Expect(r["kubernetes"]).ToNot(HaveKey(ownerNameKey))
+	})
+
+	It("should evict the least recently used pod", func() {
+		c := newMetadataCache(time.Minute, 2)
+		c.add("a", map[string]interface{}{})
+		c.add("b", map[string]interface{}{})
+		_, ok := c.get("a")
+		Expect(ok).To(BeTrue())
+		c.add("c", map[string]interface{}{})
+
+		_, ok = c.get("b")
+		Expect(ok).To(BeFalse())
+		_, ok = c.get("a")
+		Expect(ok).To(BeTrue())
+		_, ok = c.get("c")
+		Expect(ok).To(BeTrue())
+	})
+})
@@ -0,0 +1,304 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+// Record is a log record on its way through the processor chain.
+type Record struct {
+	// Fields are the record keys and values.
+	Fields map[string]interface{}
+	// Labels are added to the stream labels after they are extracted from the Fields.
+	Labels model.LabelSet
+}
+
+// Processor is one stage of the record processor chain.
+type Processor interface {
+	// Process modifies the record in place.
+	Process(record *Record) error
+}
+
+// NewProcessors creates the processor chain in the configured order.
+func NewProcessors(configs []config.ProcessorConfig) ([]Processor, error) {
+	res := make([]Processor, 0, len(configs))
+	for i, conf := range configs {
+		p, err := newProcessor(conf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create processor %d: %v", i, err)
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+func newProcessor(conf config.ProcessorConfig) (Processor, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	switch conf.Type {
+	case config.ProcessorRename:
+		return &renameProcessor{field: fieldPath(conf.Field), target: fieldPath(conf.Target)}, nil
+	case config.ProcessorCopy:
+		return &copyProcessor{field: fieldPath(conf.Field), target: fieldPath(conf.Target)}, nil
+	case config.ProcessorDropField:
+		p := &dropFieldProcessor{}
+		for _, f := range append([]string{conf.Field}, conf.Fields...) {
+			if f != "" {
+				p.fields = append(p.fields, fieldPath(f))
+			}
+		}
+		return p, nil
+	case config.ProcessorSetLabel:
+		p := &setLabelProcessor{label: model.LabelName(conf.Label), value: model.LabelValue(conf.Value)}
+		if conf.Field != "" {
+			p.field = fieldPath(conf.Field)
+		}
+		return p, nil
+	case config.ProcessorTemplate:
+		tmpl, err := config.ParseTemplate(conf.Target, conf.Template)
+		if err != nil {
+			return nil, err
+		}
+		return &templateProcessor{template: tmpl, target: fieldPath(conf.Target)}, nil
+	case config.ProcessorParseJSON:
+		p := &parseJSONProcessor{field: fieldPath(conf.Field), removeSource: conf.RemoveSource}
+		if conf.Target != "" {
+			p.target = fieldPath(conf.Target)
+		}
+		return p, nil
+	case config.ProcessorRegexExtract:
+		p := &regexExtractProcessor{field: fieldPath(conf.Field), regex: regexp.MustCompile(conf.Regex), removeSource: conf.RemoveSource}
+		if conf.Target != "" {
+			p.target = fieldPath(conf.Target)
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown processor type %q", conf.Type)
+}
+
+type renameProcessor struct {
+	field, target fieldPath
+}
+
+func (p *renameProcessor) Process(record *Record) error {
+	if value, ok := p.field.get(record.Fields); ok {
+		p.field.remove(record.Fields)
+		if err := p.target.set(record.Fields, value); err != nil {
+			_ = p.field.set(record.Fields, value)
+			return fmt.Errorf("rename: %v", err)
+		}
+	}
+	return nil
+}
+
+type copyProcessor struct {
+	field, target fieldPath
+}
+
+func (p *copyProcessor) Process(record *Record) error {
+	if value, ok := p.field.get(record.Fields); ok {
+		if err := p.target.set(record.Fields, value); err != nil {
+			return fmt.Errorf("copy: %v", err)
+		}
+	}
+	return nil
+}
+
+type dropFieldProcessor struct {
+	fields []fieldPath
+}
+
+func (p *dropFieldProcessor) Process(record *Record) error {
+	for _, f := range p.fields {
+		f.remove(record.Fields)
+	}
+	return nil
+}
+
+type setLabelProcessor struct {
+	label model.LabelName
+	value model.LabelValue
+	field fieldPath
+}
+
+func (p *setLabelProcessor) Process(record *Record) error {
+	value := p.value
+	if p.field != "" {
+		v, ok := p.field.get(record.Fields)
+		if !ok {
+			return nil
+		}
+		value = model.LabelValue(fmt.Sprintf("%v", v))
+	}
+	if record.Labels == nil {
+		record.Labels = model.LabelSet{}
+	}
+	record.Labels[p.label] = value
+	return nil
+}
+
+type templateProcessor struct {
+	template *template.Template
+	target   fieldPath
+}
+
+func (p *templateProcessor) Process(record *Record) error {
+	var buf bytes.Buffer
+	if err := p.template.Execute(&buf, record.Fields); err != nil {
+		return fmt.Errorf("template: %v", err)
+	}
+	if err := p.target.set(record.Fields, buf.String()); err != nil {
+		return fmt.Errorf("template: %v", err)
+	}
+	return nil
+}
+
+type parseJSONProcessor struct {
+	field, target fieldPath
+	removeSource  bool
+}
+
+func (p *parseJSONProcessor) Process(record *Record) error {
+	value, ok := p.field.get(record.Fields)
+	if !ok {
+		return nil
+	}
+	s, ok := value.(string)
+	if !ok || !strings.HasPrefix(strings.TrimSpace(s), "{") {
+		return nil
+	}
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &parsed); err != nil {
+		return fmt.Errorf("parse-json: %v", err)
+	}
+
+	if p.removeSource {
+		p.field.remove(record.Fields)
+	}
+	if p.target != "" {
+		if err := p.target.set(record.Fields, parsed); err != nil {
+			if p.removeSource {
+				_ = p.field.set(record.Fields, value)
+			}
+			return fmt.Errorf("parse-json: %v", err)
+		}
+		return nil
+	}
+	for k, v := range parsed {
+		record.Fields[k] = v
+	}
+	return nil
+}
+
+type regexExtractProcessor struct {
+	field, target fieldPath
+	regex         *regexp.Regexp
+	removeSource  bool
+}
+
+func (p *regexExtractProcessor) Process(record *Record) error {
+	value, ok := p.field.get(record.Fields)
+	if !ok {
+		return nil
+	}
+	match := p.regex.FindStringSubmatch(fmt.Sprintf("%v", value))
+	if match == nil {
+		return nil
+	}
+
+	if p.removeSource {
+		p.field.remove(record.Fields)
+	}
+	for i, name := range p.regex.SubexpNames()[1:] {
+		if err := p.target.join(name).set(record.Fields, match[i+1]); err != nil {
+			if p.removeSource {
+				_ = p.field.set(record.Fields, value)
+			}
+			return fmt.Errorf("regex-extract: %v", err)
+		}
+	}
+	return nil
+}
+
+// fieldPath is a dot separated path into the nested record.
+// Keys which contain dots themselves, like kubernetes label names, are matched as well.
+type fieldPath string
+
+func (f fieldPath) join(name string) fieldPath {
+	if f == "" {
+		return fieldPath(name)
+	}
+	return f + "." + fieldPath(name)
+}
+
+func (f fieldPath) get(record map[string]interface{}) (interface{}, bool) {
+	parent, key, ok := f.parent(record, false)
+	if !ok {
+		return nil, false
+	}
+	value, ok := parent[key]
+	return value, ok
+}
+
+// set sets the value of the field. An intermediate field which is not a map is not replaced, but reported.
+func (f fieldPath) set(record map[string]interface{}, value interface{}) error {
+	parent, key, ok := f.parent(record, true)
+	if !ok {
+		return fmt.Errorf("can not set %s, a parent field is not a map", f)
+	}
+	parent[key] = value
+	return nil
+}
+
+func (f fieldPath) remove(record map[string]interface{}) {
+	if parent, key, ok := f.parent(record, false); ok {
+		delete(parent, key)
+	}
+}
+
+// parent returns the map holding the last key of the path.
+// If create is set the missing intermediate maps are added, but existing fields which are not maps are kept.
+func (f fieldPath) parent(record map[string]interface{}, create bool) (map[string]interface{}, string, bool) {
+	segments := strings.Split(string(f), ".")
+	current := record
+	for len(segments) > 1 {
+		if _, ok := current[strings.Join(segments, ".")]; ok {
+			break
+		}
+		next, consumed := lookupNested(current, segments)
+		if next == nil {
+			if _, exists := current[segments[0]]; !create || exists {
+				return nil, "", false
+			}
+			next = map[string]interface{}{}
+			current[segments[0]] = next
+			consumed = 1
+		}
+		current, segments = next, segments[consumed:]
+	}
+	return current, strings.Join(segments, "."), true
+}
+
+// lookupNested finds the longest leading segments which name a nested map.
+func lookupNested(m map[string]interface{}, segments []string) (map[string]interface{}, int) {
+	for i := len(segments) - 1; i > 0; i-- {
+		if next, ok := m[strings.Join(segments[:i], ".")].(map[string]interface{}); ok {
+			return next, i
+		}
+	}
+	return nil, 0
+}
@@ -0,0 +1,183 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("Processor", func() {
+	type processArgs struct {
+		processors     []config.ProcessorConfig
+		fields         map[string]interface{}
+		expectedFields map[string]interface{}
+		expectedLabels model.LabelSet
+		wantErr        bool
+	}
+
+	kubernetes := func() map[string]interface{} {
+		return map[string]interface{}{
+			"namespace_name": "shoot--dev--test",
+			"pod_name":       "gardener-resource-manager-0",
+			"labels": map[string]interface{}{
+				"app.kubernetes.io/name": "gardener-resource-manager",
+			},
+		}
+	}
+
+	DescribeTable("#Process",
+		func(args processArgs) {
+			processors, err := NewProcessors(args.processors)
+			Expect(err).ToNot(HaveOccurred())
+
+			record := &Record{Fields: args.fields}
+			var errs []error
+			for _, p := range processors {
+				if err := p.Process(record); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			if args.wantErr {
+				Expect(errs).ToNot(BeEmpty())
+			} else {
+				Expect(errs).To(BeEmpty())
+			}
+			Expect(record.Fields).To(Equal(args.expectedFields))
+			if args.expectedLabels == nil {
+				Expect(record.Labels).To(BeEmpty())
+			} else {
+				Expect(record.Labels).To(Equal(args.expectedLabels))
+			}
+		},
+		Entry("rename a nested field", processArgs{
+			processors: []config.ProcessorConfig{{Type: config.ProcessorRename, Field: "kubernetes.pod_name", Target: "pod"}},
+			fields:     map[string]interface{}{"log": "msg", "kubernetes": kubernetes()},
+			expectedFields: map[string]interface{}{
+				"log": "msg",
+				"pod": "gardener-resource-manager-0",
+				"kubernetes": map[string]interface{}{
+					"namespace_name": "shoot--dev--test",
+					"labels":         map[string]interface{}{"app.kubernetes.io/name": "gardener-resource-manager"},
+				},
+			},
+		}),
+		Entry("rename a missing field", processArgs{
+			processors:     []config.ProcessorConfig{{Type: config.ProcessorRename, Field: "missing", Target: "pod"}},
+			fields:         map[string]interface{}{"log": "msg"},
+			expectedFields: map[string]interface{}{"log": "msg"},
+		}),
+		Entry("rename into a field which is not a map", processArgs{
+			processors:     []config.ProcessorConfig{{Type: config.ProcessorRename, Field: "level", Target: "log.level"}},
+			fields:         map[string]interface{}{"log": "msg", "level": "info"},
+			expectedFields: map[string]interface{}{"log": "msg", "level": "info"},
+			wantErr:        true,
+		}),
+		Entry("copy into a field which is not a map", processArgs{
+			processors:     []config.ProcessorConfig{{Type: config.ProcessorCopy, Field: "level", Target: "log.level.name"}},
+			fields:         map[string]interface{}{"log": "msg", "level": "info"},
+			expectedFields: map[string]interface{}{"log": "msg", "level": "info"},
+			wantErr:        true,
+		}),
+		Entry("copy a label name with dots into a new nested field", processArgs{
+			processors: []config.ProcessorConfig{{Type: config.ProcessorCopy, Field: "kubernetes.labels.app.kubernetes.io/name", Target: "meta.app"}},
+			fields:     map[string]interface{}{"log": "msg", "kubernetes": kubernetes()},
+			expectedFields: map[string]interface{}{
+				"log":        "msg",
+				"kubernetes": kubernetes(),
+				"meta":       map[string]interface{}{"app": "gardener-resource-manager"},
+			},
+		}),
+		Entry("drop fields", processArgs{
+			processors:     []config.ProcessorConfig{{Type: config.ProcessorDropField, Field: "stream", Fields: []string{"kubernetes", "missing"}}},
+			fields:         map[string]interface{}{"log": "msg", "stream": "stdout", "kubernetes": kubernetes()},
+			expectedFields: map[string]interface{}{"log": "msg"},
+		}),
+		Entry("set labels from a value and a field", processArgs{
+			processors: []config.ProcessorConfig{
+				{Type: config.ProcessorSetLabel, Label: "origin", Value: "seed"},
+				{Type: config.ProcessorSetLabel, Label: "pod", Field: "kubernetes.pod_name"},
+				{Type: config.ProcessorSetLabel, Label: "container", Field: "kubernetes.container_name"},
+			},
+			fields:         map[string]interface{}{"log": "msg", "kubernetes": kubernetes()},
+			expectedFields: map[string]interface{}{"log": "msg", "kubernetes": kubernetes()},
+			expectedLabels: model.LabelSet{"origin": "seed", "pod": "gardener-resource-manager-0"},
+		}),
+		Entry("render a template", processArgs{
+			processors: []config.ProcessorConfig{{
+				Type:     config.ProcessorTemplate,
+				Template: "{{ .kubernetes.namespace_name }}/{{ .kubernetes.pod_name }}",
+				Target:   "source",
+			}},
+			fields:         map[string]interface{}{"log": "msg", "kubernetes": kubernetes()},
+			expectedFields: map[string]interface{}{"log": "msg", "kubernetes": kubernetes(), "source": "shoot--dev--test/gardener-resource-manager-0"},
+		}),
+		Entry("parse json into the record", processArgs{
+			processors:     []config.ProcessorConfig{{Type: config.ProcessorParseJSON, Field: "log", RemoveSource: true}},
+			fields:         map[string]interface{}{"log": `{"level":"info","msg":"started"}`, "stream": "stdout"},
+			expectedFields: map[string]interface{}{"level": "info", "msg": "started", "stream": "stdout"},
+		}),
+		Entry("parse json into a target", processArgs{
+			processors: []config.ProcessorConfig{{Type: config.ProcessorParseJSON, Field: "log", Target: "json"}},
+			fields:     map[string]interface{}{"log": `{"level":"info"}`},
+			expectedFields: map[string]interface{}{
+				"log":  `{"level":"info"}`,
+				"json": map[string]interface{}{"level": "info"},
+			},
+		}),
+		Entry("skip a plain text line in parse json", processArgs{
+			processors:     []config.ProcessorConfig{{Type: config.ProcessorParseJSON, Field: "log", RemoveSource: true}},
+			fields:         map[string]interface{}{"log": "plain text"},
+			expectedFields: map[string]interface{}{"log": "plain text"},
+		}),
+		Entry("fail on broken json", processArgs{
+			processors:     []config.ProcessorConfig{{Type: config.ProcessorParseJSON, Field: "log", RemoveSource: true}},
+			fields:         map[string]interface{}{"log": `{"level":`},
+			expectedFields: map[string]interface{}{"log": `{"level":`},
+			wantErr:        true,
+		}),
+		Entry("extract named groups", processArgs{
+			processors: []config.ProcessorConfig{{
+				Type:   config.ProcessorRegexExtract,
+				Field:  "log",
+				Regex:  `^(?P<level>[IWEF])(?P<date>\d{4}) `,
+				Target: "klog",
+			}},
+			fields: map[string]interface{}{"log": "I0102 15:04:05.000000 1 main.go:10] started"},
+			expectedFields: map[string]interface{}{
+				"log":  "I0102 15:04:05.000000 1 main.go:10] started",
+				"klog": map[string]interface{}{"level": "I", "date": "0102"},
+			},
+		}),
+		Entry("run the stages in order", processArgs{
+			processors: []config.ProcessorConfig{
+				{Type: config.ProcessorParseJSON, Field: "log", RemoveSource: true},
+				{Type: config.ProcessorRename, Field: "msg", Target: "log"},
+				{Type: config.ProcessorSetLabel, Label: "severity", Field: "level"},
+				{Type: config.ProcessorDropField, Field: "level"},
+			},
+			fields:         map[string]interface{}{"log": `{"level":"error","msg":"failed"}`},
+			expectedFields: map[string]interface{}{"log": "failed"},
+			expectedLabels: model.LabelSet{"severity": "error"},
+		}),
+	)
+
+	DescribeTable("#NewProcessors",
+		func(processors []config.ProcessorConfig) {
+			_, err := NewProcessors(processors)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("unknown type", []config.ProcessorConfig{{Type: "lua"}}),
+		Entry("rename without target", []config.ProcessorConfig{{Type: config.ProcessorRename, Field: "log"}}),
+		Entry("set-label with invalid name", []config.ProcessorConfig{{Type: config.ProcessorSetLabel, Label: "a-b", Value: "c"}}),
+		Entry("set-label with field and value", []config.ProcessorConfig{{Type: config.ProcessorSetLabel, Label: "a", Field: "b", Value: "c"}}),
+		Entry("broken template", []config.ProcessorConfig{{Type: config.ProcessorTemplate, Target: "a", Template: "{{ .a"}}),
+		Entry("regex without groups", []config.ProcessorConfig{{Type: config.ProcessorRegexExtract, Field: "log", Regex: "^I"}}),
+		Entry("regex with unnamed groups", []config.ProcessorConfig{{Type: config.ProcessorRegexExtract, Field: "log", Regex: "^(I)"}}),
+	)
+})
@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+// hashPrefixLength is the number of hex characters of the sha256 sum kept by the hash action.
+const hashPrefixLength = 16
+
+// redactor scrubs secrets from the record fields and the label values.
+type redactor struct {
+	rules      []*redactionRule
+	namespaces map[string][]*redactionRule
+}
+
+type redactionRule struct {
+	name    string
+	regex   *regexp.Regexp
+	secret  int
+	literal string
+	hash    bool
+	mask    string
+}
+
+func newRedactor(conf *config.RedactionConfig) (*redactor, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	var err error
+	r := &redactor{namespaces: make(map[string][]*redactionRule, len(conf.Namespaces))}
+	if r.rules, err = newRedactionRules(conf.Rules); err != nil {
+		return nil, err
+	}
+	for namespace, rules := range conf.Namespaces {
+		if r.namespaces[namespace], err = newRedactionRules(rules); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func newRedactionRules(rules []config.RedactionRule) ([]*redactionRule, error) {
+	res := make([]*redactionRule, 0, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		r := &redactionRule{
+			name:    rule.Name,
+			literal: rule.Literal,
+			hash:    rule.Action == config.RedactionHash,
+			mask:    rule.Mask,
+		}
+		if r.mask == "" {
+			r.mask = config.DefaultRedactionMask
+		}
+		if expression := rule.Expression(); expression != "" {
+			r.regex = regexp.MustCompile(expression)
+			r.secret = r.regex.SubexpIndex(config.RedactionSecretGroup)
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// redact replaces the secrets in all string values of the records and in the label values.
+// The namespace selects the additional rules.
+func (r *redactor) redact(records map[string]interface{}, lbs model.LabelSet, namespace string) {
+	rules := r.rules
+	if nsRules, ok := r.namespaces[namespace]; ok {
+		rules = append(rules[:len(rules):len(rules)], nsRules...)
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	for k, v := range records {
+		records[k] = redactValue(v, rules)
+	}
+	for name, value := range lbs {
+		lbs[name] = model.LabelValue(redactString(string(value), rules))
+	}
+}
+
+func redactValue(value interface{}, rules []*redactionRule) interface{} {
+	switch t := value.(type) {
+	case string:
+		return redactString(t, rules)
+	case map[string]interface{}:
+		for k, v := range t {
+			t[k] = redactValue(v, rules)
+		}
+	case []interface{}:
+		for i, v := range t {
+			t[i] = redactValue(v, rules)
+		}
+	}
+	return value
+}
+
+func redactString(s string, rules []*redactionRule) string {
+	for _, rule := range rules {
+		s = rule.apply(s)
+	}
+	return s
+}
+
+func (r *redactionRule) apply(s string) string {
+	if r.regex == nil {
+		n := strings.Count(s, r.literal)
+		if n == 0 {
+			return s
+		}
+		metrics.Redactions.WithLabelValues(r.name).Add(float64(n))
+		return strings.ReplaceAll(s, r.literal, r.replacement(r.literal))
+	}
+
+	matches := r.regex.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var (
+		b           strings.Builder
+		last, count int
+	)
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if r.secret > 0 {
+			start, end = m[2*r.secret], m[2*r.secret+1]
+			if start < 0 {
+				continue
+			}
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(r.replacement(s[start:end]))
+		last = end
+		count++
+	}
+	if count == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	metrics.Redactions.WithLabelValues(r.name).Add(float64(count))
+	return b.String()
+}
+
+func (r *redactionRule) replacement(secret string) string {
+	if !r.hash {
+		return r.mask
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return "[sha256:" + hex.EncodeToString(sum[:])[:hashPrefixLength] + "]"
+}
@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+// configReloader watches the files referenced by the plugin configuration and
+// swaps the reloadable part of the configuration when their content changes.
+// The directories of the files are watched, because ConfigMap volumes replace
+// the files through a symlink swap.
+type configReloader struct {
+	plugin  *vali
+	base    *config.Config
+	current atomic.Pointer[config.Config]
+	hashes  map[string][sha256.Size]byte
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+	logger  log.Logger
+}
+
+func newConfigReloader(v *vali, base *config.Config, logger log.Logger) (*configReloader, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %v", err)
+	}
+
+	r := &configReloader{
+		plugin:  v,
+		base:    base,
+		hashes:  map[string][sha256.Size]byte{},
+		watcher: watcher,
+		done:    make(chan struct{}),
+		logger:  log.With(logger, "component", "config-reloader"),
+	}
+
+	for _, file := range []string{base.PluginConfig.LabelMapPath, base.PluginConfig.ReloadConfigPath} {
+		if err := r.watch(file); err != nil {
+			_ = watcher.Close()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// watch starts watching the directory of the file and remembers its current content.
+func (r *configReloader) watch(file string) error {
+	if file == "" {
+		return nil
+	}
+	if _, ok := r.hashes[file]; ok {
+		return nil
+	}
+	if err := r.watcher.Add(filepath.Dir(file)); err != nil {
+		return fmt.Errorf("failed to watch %s: %v", file, err)
+	}
+	hash, err := hashFile(file)
+	if err != nil {
+		return err
+	}
+	r.hashes[file] = hash
+	return nil
+}
+
+func (r *configReloader) run() {
+	for {
+		select {
+		case <-r.done:
+			return
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if r.changed() {
+				r.reload()
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			_ = level.Error(r.logger).Log("msg", "file watcher error", "err", err)
+		}
+	}
+}
+
+// changed returns true if the content of any watched file changed since the last check.
+func (r *configReloader) changed() bool {
+	changed := false
+	for file, oldHash := range r.hashes {
+		hash, err := hashFile(file)
+		if err != nil {
+			// The file may be missing for a moment while it is being replaced.
+			continue
+		}
+		if hash != oldHash {
+			r.hashes[file] = hash
+			changed = true
+		}
+	}
+	return changed
+}
+
+// reload validates the new configuration and swaps it in the plugin.
+// On failure the current configuration is kept.
+func (r *configReloader) reload() bool {
+	getter := config.MapGetter{}
+	if r.base.PluginConfig.ReloadConfigPath != "" {
+		var err error
+		if getter, err = config.NewFileGetter(r.base.PluginConfig.ReloadConfigPath); err != nil {
+			metrics.ConfigReloads.WithLabelValues(metrics.ReloadFailure).Inc()
+			_ = level.Error(r.logger).Log("msg", "failed to read the reload configuration", "err", err)
+			return false
+		}
+	}
+
+	cfg, err := config.Reload(r.base, getter)
+	if err != nil {
+		metrics.ConfigReloads.WithLabelValues(metrics.ReloadFailure).Inc()
+		_ = level.Error(r.logger).Log("msg", "invalid configuration, keeping the current one", "err", err)
+		return false
+	}
+
+	// The configuration is swapped atomically, so a record is processed either
+	// with the old or with the new label mapping, but never with a mix of them.
+	r.current.Store(cfg)
+	if r.plugin.controller != nil {
+		r.plugin.controller.SetRoutingPolicy(cfg.ControllerConfig.RoutingPolicy)
+	}
+	if err := r.watch(cfg.PluginConfig.LabelMapPath); err != nil {
+		_ = level.Warn(r.logger).Log("msg", "new LabelMapPath will not be watched", "err", err)
+	}
+
+	metrics.ConfigReloads.WithLabelValues(metrics.ReloadSuccess).Inc()
+	_ = level.Info(r.logger).Log(
+		"msg", "configuration reloaded",
+		"label_map_path", cfg.PluginConfig.LabelMapPath,
+		"reload_config_path", cfg.PluginConfig.ReloadConfigPath,
+	)
+	return true
+}
+
+func (r *configReloader) stop() {
+	close(r.done)
+	_ = r.watcher.Close()
+}
+
+func hashFile(file string) ([sha256.Size]byte, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(content), nil
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("Config reloader", func() {
+	var (
+		dir          string
+		labelMapPath string
+		rec          *recorder
+		plugin       *vali
+		reloader     *configReloader
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "reloader")
+		Expect(err).ToNot(HaveOccurred())
+		labelMapPath = filepath.Join(dir, "labelmap.json")
+		Expect(os.WriteFile(labelMapPath, []byte(`{"app": "app"}`), 0644)).To(Succeed())
+
+		cfg, err := config.ParseConfig(config.MapGetter{
+			"LabelMapPath": labelMapPath,
+			"HotReload":    "true",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		rec = &recorder{}
+		plugin = &vali{cfg: cfg, defaultClient: rec, converter: newConverter(nil), logger: logger}
+		reloader, err = newConfigReloader(plugin, cfg, logger)
+		Expect(err).ToNot(HaveOccurred())
+		plugin.reloader = reloader
+	})
+
+	AfterEach(func() {
+		reloader.stop()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("Should use the new label map after the file is changed", func() {
+		go reloader.run()
+		Expect(os.WriteFile(labelMapPath, []byte(`{"app": "application"}`), 0644)).To(Succeed())
+
+		Eventually(func() *config.Config {
+			return reloader.current.Load()
+		}, 5*time.Second, 10*time.Millisecond).ShouldNot(BeNil())
+
+		Expect(plugin.SendRecord(map[interface{}]interface{}{"app": "foo", "log": "line"}, now, "")).To(Succeed())
+		Expect(rec.lbs).To(Equal(model.LabelSet{"application": "foo"}))
+	})
+
+	It("Should keep the current configuration when the new one is invalid", func() {
+		Expect(os.WriteFile(labelMapPath, []byte(`{"app": `), 0644)).To(Succeed())
+		Expect(reloader.changed()).To(BeTrue())
+		Expect(reloader.reload()).To(BeFalse())
+		Expect(reloader.current.Load()).To(BeNil())
+
+		Expect(plugin.SendRecord(map[interface{}]interface{}{"app": "foo", "log": "line"}, now, "")).To(Succeed())
+		Expect(rec.lbs).To(Equal(model.LabelSet{"app": "foo"}))
+	})
+
+	It("Should not report a change when the content is the same", func() {
+		Expect(os.WriteFile(labelMapPath, []byte(`{"app": "app"}`), 0644)).To(Succeed())
+		Expect(reloader.changed()).To(BeFalse())
+	})
+})
@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+var (
+	klogLevelRegexp   = regexp.MustCompile(`^([IWEF])\d{4} `)
+	jsonLevelRegexp   = regexp.MustCompile(`"(?:level|severity|lvl)"\s*:\s*"([^"]*)"`)
+	logfmtLevelRegexp = regexp.MustCompile(`(?:^|\s)(?:level|severity|lvl)=(?:"([^"]*)"|(\S+))`)
+	klogLevels        = map[string]string{"I": config.LevelInfo, "W": config.LevelWarn, "E": config.LevelError, "F": config.LevelFatal}
+	normalizedLevels  = map[string]string{}
+	levelSynonyms     = map[string][]string{
+		config.LevelDebug: {"trace", "debug", "dbug", "dbg", "verbose", "d", "t"},
+		config.LevelInfo:  {"info", "information", "informational", "notice", "i"},
+		config.LevelWarn:  {"warn", "warning", "w"},
+		config.LevelError: {"error", "err", "eror", "e"},
+		config.LevelFatal: {"fatal", "panic", "dpanic", "critical", "crit", "alert", "emerg", "emergency", "f"},
+	}
+)
+
+func init() {
+	for level, synonyms := range levelSynonyms {
+		for _, s := range synonyms {
+			normalizedLevels[s] = level
+		}
+	}
+}
+
+// severity detects the level of a record and maps it to a normalized value.
+type severity struct {
+	label      model.LabelName
+	keys       []string
+	messageKey string
+	thresholds map[string]int
+}
+
+func newSeverity(conf *config.SeverityConfig) *severity {
+	if conf == nil {
+		return nil
+	}
+	s := &severity{
+		label:      model.LabelName(conf.Label),
+		keys:       conf.Keys,
+		messageKey: conf.MessageKey,
+		thresholds: make(map[string]int, len(conf.Thresholds)),
+	}
+	for namespace, level := range conf.Thresholds {
+		s.thresholds[namespace] = config.LevelIndex(level)
+	}
+	return s
+}
+
+// detect returns the normalized level of the record.
+// The structured level keys take precedence over the klog prefix, json and logfmt levels in the message.
+func (s *severity) detect(records map[string]interface{}) string {
+	for _, key := range s.keys {
+		if value, ok := records[key]; ok {
+			if level := normalizeLevel(fmt.Sprintf("%v", value)); level != config.LevelUnknown {
+				return level
+			}
+		}
+	}
+
+	message, ok := records[s.messageKey].(string)
+	if !ok {
+		return config.LevelUnknown
+	}
+	if m := klogLevelRegexp.FindStringSubmatch(message); m != nil {
+		return klogLevels[m[1]]
+	}
+	if strings.HasPrefix(strings.TrimSpace(message), "{") {
+		if m := jsonLevelRegexp.FindStringSubmatch(message); m != nil {
+			return normalizeLevel(m[1])
+		}
+		return config.LevelUnknown
+	}
+	if m := logfmtLevelRegexp.FindStringSubmatch(message); m != nil {
+		return normalizeLevel(m[1] + m[2])
+	}
+	return config.LevelUnknown
+}
+
+// drop reports whether the level is below the threshold of the namespace.
+// Unknown levels are never dropped.
+func (s *severity) drop(level, namespace string) bool {
+	threshold, ok := s.thresholds[namespace]
+	if !ok {
+		if threshold, ok = s.thresholds[config.AllNamespaces]; !ok {
+			return false
+		}
+	}
+	index := config.LevelIndex(level)
+	return index >= 0 && index < threshold
+}
+
+func normalizeLevel(level string) string {
+	if normalized, ok := normalizedLevels[strings.ToLower(strings.TrimSpace(level))]; ok {
+		return normalized
+	}
+	return config.LevelUnknown
+}
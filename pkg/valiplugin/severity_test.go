@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("Severity", func() {
+	s := newSeverity(&config.SeverityConfig{
+		Label:      config.DefaultLevelLabel,
+		Keys:       config.DefaultLevelKeys,
+		MessageKey: config.DefaultLevelMessageKey,
+		Thresholds: map[string]string{"garden": config.LevelWarn, config.AllNamespaces: config.LevelInfo},
+	})
+
+	DescribeTable("#detect",
+		func(records map[string]interface{}, expected string) {
+			Expect(s.detect(records)).To(Equal(expected))
+		},
+		Entry("level field", map[string]interface{}{"level": "WARNING", "log": "E0102 ignored"}, config.LevelWarn),
+		Entry("severity field", map[string]interface{}{"severity": "critical"}, config.LevelFatal),
+		Entry("lvl field", map[string]interface{}{"lvl": "dbug"}, config.LevelDebug),
+		Entry("unknown field value falls back to the message", map[string]interface{}{"level": "30", "log": "W0102 12:00:00.000000 1 main.go:1] slow"}, config.LevelWarn),
+		Entry("klog info", map[string]interface{}{"log": "I0102 15:04:05.000000       1 main.go:10] started"}, config.LevelInfo),
+		Entry("klog error", map[string]interface{}{"log": "E1231 15:04:05.000000       1 main.go:10] failed"}, config.LevelError),
+		Entry("klog fatal", map[string]interface{}{"log": "F0102 15:04:05.000000       1 main.go:10] exiting"}, config.LevelFatal),
+		Entry("json", map[string]interface{}{"log": `{"ts":"2024-01-02T15:04:05Z","level":"error","msg":"failed"}`}, config.LevelError),
+		Entry("json without level", map[string]interface{}{"log": `{"msg":"level=debug"}`}, config.LevelUnknown),
+		Entry("logfmt", map[string]interface{}{"log": `ts=2024-01-02T15:04:05Z level=info msg="started"`}, config.LevelInfo),
+		Entry("quoted logfmt", map[string]interface{}{"log": `time="2024" severity="Warning" msg=slow`}, config.LevelWarn),
+		Entry("plain text", map[string]interface{}{"log": "Error while starting"}, config.LevelUnknown),
+		Entry("no message", map[string]interface{}{"message": "I0102 started"}, config.LevelUnknown),
+	)
+
+	DescribeTable("#drop",
+		func(level, namespace string, expected bool) {
+			Expect(s.drop(level, namespace)).To(Equal(expected))
+		},
+		Entry("info below the namespace threshold", config.LevelInfo, "garden", true),
+		Entry("warn at the namespace threshold", config.LevelWarn, "garden", false),
+		Entry("debug below the default threshold", config.LevelDebug, "shoot--dev--test", true),
+		Entry("info at the default threshold", config.LevelInfo, "shoot--dev--test", false),
+		Entry("unknown level", config.LevelUnknown, "garden", false),
+	)
+})
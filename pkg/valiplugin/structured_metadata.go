@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-logfmt/logfmt"
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+// structuredMetadata moves the configured attributes out of the records. They are appended
+// to the line, because Vali has no structured metadata.
+type structuredMetadata struct {
+	attributes []structuredMetadataAttribute
+	encoding   string
+}
+
+type structuredMetadataAttribute struct {
+	name  model.LabelName
+	field fieldPath
+}
+
+func newStructuredMetadata(conf *config.StructuredMetadataConfig) *structuredMetadata {
+	if conf == nil {
+		return nil
+	}
+	res := &structuredMetadata{encoding: conf.Encoding}
+	for _, a := range conf.Attributes {
+		res.attributes = append(res.attributes, structuredMetadataAttribute{name: a.Name, field: fieldPath(a.Field)})
+	}
+	return res
+}
+
+// extract removes the attributes from the records and returns them.
+// Missing keys, nested objects and values which are not valid UTF-8 are skipped.
+func (s *structuredMetadata) extract(records map[string]interface{}) model.LabelSet {
+	var res model.LabelSet
+	for _, a := range s.attributes {
+		value, ok := a.field.get(records)
+		if !ok {
+			continue
+		}
+		var v model.LabelValue
+		switch t := value.(type) {
+		case nil, map[string]interface{}, []interface{}:
+			continue
+		case string:
+			v = model.LabelValue(t)
+		case []byte:
+			v = model.LabelValue(t)
+		default:
+			v = model.LabelValue(fmt.Sprintf("%v", t))
+		}
+		if !v.IsValid() {
+			continue
+		}
+		a.field.remove(records)
+		if res == nil {
+			res = make(model.LabelSet, len(s.attributes))
+		}
+		res[a.name] = v
+	}
+	return res
+}
+
+// appendTo appends the attributes to the line in the configured encoding.
+func (s *structuredMetadata) appendTo(line string, metadata model.LabelSet) (string, error) {
+	buf := bytes.NewBufferString(line)
+	if line != "" {
+		buf.WriteByte(' ')
+	}
+
+	if s.encoding == config.StructuredMetadataJSON {
+		js, err := json.Marshal(metadata)
+		if err != nil {
+			return "", err
+		}
+		buf.Write(js)
+		return buf.String(), nil
+	}
+
+	// The logfmt attributes keep the configured order.
+	enc := logfmt.NewEncoder(buf)
+	for _, a := range s.attributes {
+		if value, ok := metadata[a.name]; ok {
+			if err := enc.EncodeKeyval(string(a.name), string(value)); err != nil {
+				return "", err
+			}
+		}
+	}
+	return buf.String(), nil
+}
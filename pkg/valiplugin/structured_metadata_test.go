@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/client"
+	"github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("StructuredMetadata", func() {
+	attributes := []config.StructuredMetadataAttribute{
+		{Name: "trace_id", Field: "trace_id"},
+		{Name: "request_id", Field: "request_id"},
+		{Name: "pod_uid", Field: "kubernetes.pod_id"},
+	}
+
+	type extractArgs struct {
+		records     map[string]interface{}
+		want        model.LabelSet
+		wantRecords map[string]interface{}
+	}
+
+	DescribeTable("#extract",
+		func(args extractArgs) {
+			s := newStructuredMetadata(&config.StructuredMetadataConfig{Attributes: attributes, Encoding: config.StructuredMetadataLogfmt})
+			Expect(s.extract(args.records)).To(Equal(args.want))
+			Expect(args.records).To(Equal(args.wantRecords))
+		},
+		Entry("top level and nested keys",
+			extractArgs{
+				records:     map[string]interface{}{"log": "line", "trace_id": "abc", "kubernetes": map[string]interface{}{"pod_name": "pod", "pod_id": "uid"}},
+				want:        model.LabelSet{"trace_id": "abc", "pod_uid": "uid"},
+				wantRecords: map[string]interface{}{"log": "line", "kubernetes": map[string]interface{}{"pod_name": "pod"}},
+			},
+		),
+		Entry("numbers and bytes",
+			extractArgs{
+				records:     map[string]interface{}{"trace_id": []byte("abc"), "request_id": 42},
+				want:        model.LabelSet{"trace_id": "abc", "request_id": "42"},
+				wantRecords: map[string]interface{}{},
+			},
+		),
+		Entry("nested objects and invalid values are kept in the record",
+			extractArgs{
+				records:     map[string]interface{}{"trace_id": map[string]interface{}{"id": "abc"}, "request_id": "a\xc5z"},
+				want:        nil,
+				wantRecords: map[string]interface{}{"trace_id": map[string]interface{}{"id": "abc"}, "request_id": "a\xc5z"},
+			},
+		),
+	)
+
+	DescribeTable("#appendTo",
+		func(encoding, line string, metadata model.LabelSet, want string) {
+			s := newStructuredMetadata(&config.StructuredMetadataConfig{Attributes: attributes, Encoding: encoding})
+			got, err := s.appendTo(line, metadata)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got).To(Equal(want))
+		},
+		Entry("logfmt in the configured order", config.StructuredMetadataLogfmt, `{"log":"line"}`,
+			model.LabelSet{"pod_uid": "uid", "trace_id": "abc"}, `{"log":"line"} trace_id=abc pod_uid=uid`),
+		Entry("logfmt with quoted values", config.StructuredMetadataLogfmt, "line",
+			model.LabelSet{"request_id": "a b"}, `line request_id="a b"`),
+		Entry("json", config.StructuredMetadataJSON, "line",
+			model.LabelSet{"trace_id": "abc", "pod_uid": "uid"}, `line {"pod_uid":"uid","trace_id":"abc"}`),
+		Entry("empty line", config.StructuredMetadataLogfmt, "",
+			model.LabelSet{"trace_id": "abc"}, `trace_id=abc`),
+	)
+
+	Describe("#SendRecord", func() {
+		newPlugin := func(c client.ValiClient) *vali {
+			cfg := &config.Config{PluginConfig: config.PluginConfig{
+				LineFormat:         config.JSONFormat,
+				StructuredMetadata: &config.StructuredMetadataConfig{Attributes: attributes, Encoding: config.StructuredMetadataLogfmt},
+			}}
+			return &vali{cfg: cfg, defaultClient: c, converter: newConverter(nil), logger: logger, structuredMetadata: newStructuredMetadata(cfg.PluginConfig.StructuredMetadata)}
+		}
+
+		It("should append the attributes to the line", func() {
+			c := &linesClient{}
+			Expect(newPlugin(c).SendRecord(map[interface{}]interface{}{"log": "line", "trace_id": "abc"}, now, "")).To(Succeed())
+			Expect(c.lines).To(Equal([]string{`{"log":"line"} trace_id=abc`}))
+		})
+
+		It("should send the entries without attributes unchanged", func() {
+			c := &linesClient{}
+			Expect(newPlugin(c).SendRecord(map[interface{}]interface{}{"log": "line"}, now, "")).To(Succeed())
+			Expect(c.lines).To(Equal([]string{`{"log":"line"}`}))
+		})
+	})
+})
@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"github.com/gardener/logging/pkg/config"
+)
+
+// tagRecordKey is the reserved record key which carries the fluent-bit tag
+// from SendRecord, through the multiline grouping, to sendRecord.
+const tagRecordKey = "__gardener_fluentbit_tag__"
+
+// tagRouting selects the route of the records by their fluent-bit tag.
+type tagRouting struct {
+	routes []config.TagRoute
+}
+
+func newTagRouting(routes []config.TagRoute) *tagRouting {
+	if len(routes) == 0 {
+		return nil
+	}
+	return &tagRouting{routes: routes}
+}
+
+// route returns the first route matching the tag.
+func (t *tagRouting) route(tag string) *config.TagRoute {
+	for i := range t.routes {
+		if t.routes[i].Matches(tag) {
+			return &t.routes[i]
+		}
+	}
+	return nil
+}
+
+// pop removes the tag from the records and returns its route.
+func (t *tagRouting) pop(records map[string]interface{}) *config.TagRoute {
+	tag, ok := records[tagRecordKey].(string)
+	if !ok {
+		return nil
+	}
+	delete(records, tagRecordKey)
+	return t.route(tag)
+}
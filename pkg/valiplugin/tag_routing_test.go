@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/model"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+var _ = Describe("TagRouting", func() {
+	routing := newTagRouting([]config.TagRoute{
+		{Match: "journald.kubelet", Labels: model.LabelSet{"unit": "kubelet"}},
+		{Match: "journald.*", Labels: model.LabelSet{"unit": "other"}},
+		{Match: "kube.*.containers.*", Tenant: "operator"},
+	})
+
+	DescribeTable("#route",
+		func(tag string, expected *config.TagRoute) {
+			Expect(routing.route(tag)).To(Equal(expected))
+		},
+		Entry("exact match before glob", "journald.kubelet", &config.TagRoute{Match: "journald.kubelet", Labels: model.LabelSet{"unit": "kubelet"}}),
+		Entry("glob", "journald.containerd", &config.TagRoute{Match: "journald.*", Labels: model.LabelSet{"unit": "other"}}),
+		Entry("glob in the middle", "kube.var.log.containers.app.log", &config.TagRoute{Match: "kube.*.containers.*", Tenant: "operator"}),
+		Entry("no match", "systemd", nil),
+	)
+
+	It("should remove the tag from the records", func() {
+		records := map[string]interface{}{"log": "line", tagRecordKey: "journald.kubelet"}
+		Expect(routing.pop(records)).To(Equal(&config.TagRoute{Match: "journald.kubelet", Labels: model.LabelSet{"unit": "kubelet"}}))
+		Expect(records).To(Equal(map[string]interface{}{"log": "line"}))
+		Expect(routing.pop(records)).To(BeNil())
+	})
+
+	It("should not create a routing without routes", func() {
+		Expect(newTagRouting(nil)).To(BeNil())
+	})
+})
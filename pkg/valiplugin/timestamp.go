@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+const klogTimestampLayout = "0102 15:04:05.000000"
+
+var (
+	klogTimestampRegexp = regexp.MustCompile(`^[IWEF](\d{4} \d{2}:\d{2}:\d{2}\.\d{6})`)
+	unixUnits           = map[string]int64{
+		config.TimestampUnix:   int64(time.Second),
+		config.TimestampUnixMs: int64(time.Millisecond),
+		config.TimestampUnixNs: 1,
+	}
+)
+
+// timestamp takes the entry timestamp from a record key instead of the fluent-bit event time.
+type timestamp struct {
+	key      fieldPath
+	format   string
+	maxSkew  time.Duration
+	fallback string
+	now      func() time.Time
+}
+
+func newTimestamp(conf *config.TimestampConfig) *timestamp {
+	if conf == nil {
+		return nil
+	}
+	return &timestamp{
+		key:      fieldPath(conf.Key),
+		format:   conf.Format,
+		maxSkew:  conf.MaxSkew,
+		fallback: conf.Fallback,
+		now:      time.Now,
+	}
+}
+
+// extract returns the timestamp of the record. A record without a usable timestamp is counted
+// and gets the timestamp of the fallback policy. The result is false if the record has to be dropped.
+func (t *timestamp) extract(records map[string]interface{}, eventTime time.Time) (time.Time, bool) {
+	now := t.now()
+	reason := ""
+	value, ok := t.key.get(records)
+	if !ok || value == nil {
+		reason = metrics.TimestampMissing
+	} else if ts, err := t.parse(value, now); err != nil {
+		reason = metrics.TimestampInvalid
+	} else if t.maxSkew > 0 && (ts.Sub(now) > t.maxSkew || now.Sub(ts) > t.maxSkew) {
+		reason = metrics.TimestampSkew
+	} else {
+		return ts, true
+	}
+
+	metrics.TimestampFailures.WithLabelValues(reason).Inc()
+	switch t.fallback {
+	case config.TimestampFallbackNow:
+		return now, true
+	case config.TimestampFallbackDrop:
+		return time.Time{}, false
+	default:
+		return eventTime, true
+	}
+}
+
+func (t *timestamp) parse(value interface{}, now time.Time) (time.Time, error) {
+	if unit, ok := unixUnits[t.format]; ok {
+		return parseUnix(value, unit)
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("timestamp %v is not a string", value)
+	}
+	s = strings.TrimSpace(s)
+
+	var (
+		ts  time.Time
+		err error
+	)
+	switch t.format {
+	case config.TimestampRFC3339:
+		return time.Parse(time.RFC3339Nano, s)
+	case config.TimestampKlog:
+		match := klogTimestampRegexp.FindStringSubmatch(s)
+		if match == nil {
+			return time.Time{}, fmt.Errorf("timestamp %q has no klog header", s)
+		}
+		ts, err = time.ParseInLocation(klogTimestampLayout, match[1], time.Local)
+	default:
+		ts, err = time.Parse(t.format, s)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return withYear(ts, now), nil
+}
+
+// withYear completes a timestamp of a layout without year, like the klog header.
+// A timestamp which would be in the future belongs to the previous year.
+func withYear(ts, now time.Time) time.Time {
+	if ts.Year() != 0 {
+		return ts
+	}
+	ts = ts.AddDate(now.Year(), 0, 0)
+	if ts.Sub(now) > 24*time.Hour {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+	return ts
+}
+
+// parseUnix parses an epoch timestamp in the given unit. Fractions are kept up to nanoseconds.
+func parseUnix(value interface{}, unit int64) (time.Time, error) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+	case json.Number:
+		s = v.String()
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		s = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s = fmt.Sprintf("%d", v)
+	default:
+		return time.Time{}, fmt.Errorf("timestamp %v is not a number", value)
+	}
+
+	integer, fraction, _ := strings.Cut(s, ".")
+	n, err := strconv.ParseInt(integer, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid epoch timestamp %q: %v", s, err)
+	}
+	nanos := n * unit
+	if fraction != "" {
+		if len(fraction) > 9 {
+			fraction = fraction[:9]
+		}
+		f, err := strconv.ParseInt(fraction, 10, 64)
+		if err != nil || f < 0 {
+			return time.Time{}, fmt.Errorf("invalid epoch timestamp %q", s)
+		}
+		for i := len(fraction); i < 9; i++ {
+			f *= 10
+		}
+		frac := f * unit / int64(time.Second)
+		if n < 0 || strings.HasPrefix(integer, "-") {
+			frac = -frac
+		}
+		nanos += frac
+	}
+	return time.Unix(0, nanos), nil
+}
@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/metrics"
+)
+
+var _ = Describe("Timestamp", func() {
+	var (
+		now       = time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+		eventTime = now.Add(-time.Minute)
+	)
+
+	newTestTimestamp := func(format string, maxSkew time.Duration, fallback string) *timestamp {
+		t := newTimestamp(&config.TimestampConfig{Key: "ts", Format: format, MaxSkew: maxSkew, Fallback: fallback})
+		t.now = func() time.Time { return now }
+		return t
+	}
+
+	failures := func(reason string) float64 {
+		m := &dto.Metric{}
+		Expect(metrics.TimestampFailures.WithLabelValues(reason).Write(m)).To(Succeed())
+		return m.GetCounter().GetValue()
+	}
+
+	DescribeTable("#extract",
+		func(format string, value interface{}, expected time.Time) {
+			ts, ok := newTestTimestamp(format, 0, config.TimestampFallbackEventTime).extract(map[string]interface{}{"ts": value}, eventTime)
+			Expect(ok).To(BeTrue())
+			Expect(ts.Equal(expected)).To(BeTrue(), "got %s, expected %s", ts, expected)
+		},
+		Entry("rfc3339", config.TimestampRFC3339, "2024-03-10T11:59:30Z", time.Date(2024, time.March, 10, 11, 59, 30, 0, time.UTC)),
+		Entry("rfc3339 with nanoseconds and offset", config.TimestampRFC3339, "2024-03-10T13:59:30.123456789+02:00", time.Date(2024, time.March, 10, 11, 59, 30, 123456789, time.UTC)),
+		Entry("unix seconds", config.TimestampUnix, int64(1710071970), time.Date(2024, time.March, 10, 11, 59, 30, 0, time.UTC)),
+		Entry("unix seconds with fraction", config.TimestampUnix, 1710071970.25, time.Date(2024, time.March, 10, 11, 59, 30, 250000000, time.UTC)),
+		Entry("unix seconds as string", config.TimestampUnix, "1710071970.000001", time.Date(2024, time.March, 10, 11, 59, 30, 1000, time.UTC)),
+		Entry("unix milliseconds", config.TimestampUnixMs, uint64(1710071970123), time.Date(2024, time.March, 10, 11, 59, 30, 123000000, time.UTC)),
+		Entry("unix milliseconds as json number", config.TimestampUnixMs, json.Number("1710071970123.5"), time.Date(2024, time.March, 10, 11, 59, 30, 123500000, time.UTC)),
+		Entry("unix nanoseconds", config.TimestampUnixNs, "1710071970123456789", time.Date(2024, time.March, 10, 11, 59, 30, 123456789, time.UTC)),
+		Entry("klog", config.TimestampKlog, "I0310 11:59:30.500000       1 main.go:10] started", time.Date(2024, time.March, 10, 11, 59, 30, 500000000, time.Local)),
+		Entry("klog of the previous year", config.TimestampKlog, "E1231 23:59:59.000000 1 main.go:10] failed", time.Date(2023, time.December, 31, 23, 59, 59, 0, time.Local)),
+		Entry("custom layout", "02/Jan/2006:15:04:05 -0700", "10/Mar/2024:11:59:30 +0000", time.Date(2024, time.March, 10, 11, 59, 30, 0, time.UTC)),
+		Entry("custom layout without year", time.StampMilli, "Mar 10 11:59:30.042", time.Date(2024, time.March, 10, 11, 59, 30, 42000000, time.UTC)),
+	)
+
+	DescribeTable("#extract failures",
+		func(value interface{}, maxSkew time.Duration, fallback, reason string, expected time.Time, expectedOK bool) {
+			before := failures(reason)
+			records := map[string]interface{}{"log": "line"}
+			if value != nil {
+				records["ts"] = value
+			}
+			ts, ok := newTestTimestamp(config.TimestampRFC3339, maxSkew, fallback).extract(records, eventTime)
+			Expect(ok).To(Equal(expectedOK))
+			Expect(ts).To(Equal(expected))
+			Expect(failures(reason)).To(Equal(before + 1))
+		},
+		Entry("missing key falls back to the event time", nil, time.Duration(0), config.TimestampFallbackEventTime, metrics.TimestampMissing, eventTime, true),
+		Entry("invalid value falls back to now", "yesterday", time.Duration(0), config.TimestampFallbackNow, metrics.TimestampInvalid, now, true),
+		Entry("not a string", 42, time.Duration(0), config.TimestampFallbackEventTime, metrics.TimestampInvalid, eventTime, true),
+		Entry("too old is dropped", "2024-03-10T10:00:00Z", time.Hour, config.TimestampFallbackDrop, metrics.TimestampSkew, time.Time{}, false),
+		Entry("too far in the future", "2024-03-10T12:10:00Z", 5*time.Minute, config.TimestampFallbackEventTime, metrics.TimestampSkew, eventTime, true),
+	)
+
+	It("should accept a timestamp within the max skew", func() {
+		ts, ok := newTestTimestamp(config.TimestampRFC3339, time.Hour, config.TimestampFallbackDrop).extract(map[string]interface{}{"ts": "2024-03-10T11:30:00Z"}, eventTime)
+		Expect(ok).To(BeTrue())
+		Expect(ts).To(Equal(time.Date(2024, time.March, 10, 11, 30, 0, 0, time.UTC)))
+	})
+})
@@ -14,6 +14,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"text/template"
 
 	"github.com/go-logfmt/logfmt"
 	"github.com/prometheus/common/model"
@@ -31,47 +32,6 @@ const (
 	inCaseKubernetesMetadataIsMissing = 1
 )
 
-// prevent base64-encoding []byte values (default json.Encoder rule) by
-// converting them to strings
-
-func toStringSlice(slice []interface{}) []interface{} {
-	s := make([]interface{}, 0, len(slice))
-	for _, v := range slice {
-		switch t := v.(type) {
-		case []byte:
-			s = append(s, string(t))
-		case map[interface{}]interface{}:
-			s = append(s, toStringMap(t))
-		case []interface{}:
-			s = append(s, toStringSlice(t))
-		default:
-			s = append(s, t)
-		}
-	}
-	return s
-}
-
-func toStringMap(record map[interface{}]interface{}) map[string]interface{} {
-	m := make(map[string]interface{}, len(record)+inCaseKubernetesMetadataIsMissing)
-	for k, v := range record {
-		key, ok := k.(string)
-		if !ok {
-			continue
-		}
-		switch t := v.(type) {
-		case []byte:
-			m[key] = string(t)
-		case map[interface{}]interface{}:
-			m[key] = toStringMap(t)
-		case []interface{}:
-			m[key] = toStringSlice(t)
-		default:
-			m[key] = v
-		}
-	}
-	return m
-}
-
 func autoLabels(records map[string]interface{}, kuberneteslbs model.LabelSet) error {
 	kube, ok := records["kubernetes"]
 	if !ok {
@@ -161,19 +121,22 @@ func mapLabels(records map[string]interface{}, mapping map[string]interface{}, r
 	}
 }
 
-func getDynamicHostName(records map[string]interface{}, mapping map[string]interface{}) string {
-	for k, v := range mapping {
-		switch nextKey := v.(type) {
-		// if the next level is a map we are expecting we need to move deeper in the tree
-		case map[string]interface{}:
-			if nextValue, ok := records[k].(map[string]interface{}); ok {
-				// recursively search through the next level map.
-				return getDynamicHostName(nextValue, nextKey)
-			}
+// getDynamicHostName returns the value of the first of the paths which is present in the record.
+func getDynamicHostName(records map[string]interface{}, paths []string) string {
+	for _, p := range paths {
+		value, ok := fieldPath(p).get(records)
+		if !ok {
+			continue
+		}
+		switch typedVal := value.(type) {
 		case string:
-			if value, ok := getRecordValue(k, records); ok {
-				return value
-			}
+			return typedVal
+		case []byte:
+			return string(typedVal)
+		case map[string]interface{}:
+			continue
+		default:
+			return fmt.Sprintf("%v", typedVal)
 		}
 	}
 	return ""
@@ -193,6 +156,33 @@ func getRecordValue(key string, records map[string]interface{}) (string, bool) {
 	return "", false
 }
 
+// getNamespace returns the namespace from the kubernetes metadata of the record.
+func getNamespace(records map[string]interface{}) string {
+	if kubernetes, ok := records["kubernetes"].(map[string]interface{}); ok {
+		if namespace, ok := getRecordValue(namespaceName, kubernetes); ok {
+			return namespace
+		}
+	}
+	return ""
+}
+
+// workload identifies the namespace, pod and container of a record.
+type workload struct {
+	namespace string
+	pod       string
+	container string
+}
+
+func workloadOf(records map[string]interface{}) workload {
+	var res workload
+	if kubernetes, ok := records["kubernetes"].(map[string]interface{}); ok {
+		res.namespace, _ = getRecordValue(namespaceName, kubernetes)
+		res.pod, _ = getRecordValue(podName, kubernetes)
+		res.container, _ = getRecordValue(containerName, kubernetes)
+	}
+	return res
+}
+
 func removeKeys(records map[string]interface{}, keys []string) {
 	for _, k := range keys {
 		delete(records, k)
@@ -213,7 +203,7 @@ func removeMultiTenantClientLabel(records map[string]interface{}) {
 	delete(records, client.MultiTenantClientLabel)
 }
 
-func createLine(records map[string]interface{}, f config.Format) (string, error) {
+func createLine(records map[string]interface{}, f config.Format, tmpl *template.Template) (string, error) {
 	switch f {
 	case config.JSONFormat:
 		js, err := json.Marshal(records)
@@ -243,6 +233,15 @@ func createLine(records map[string]interface{}, f config.Format) (string, error)
 			}
 		}
 		return buf.String(), nil
+	case config.TemplateFormat:
+		if tmpl == nil {
+			return "", fmt.Errorf("missing line template")
+		}
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, records); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
 	default:
 		return "", fmt.Errorf("invalid line format: %v", f)
 	}
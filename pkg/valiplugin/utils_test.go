@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"text/template"
 
 	jsoniter "github.com/json-iterator/go"
 	. "github.com/onsi/ginkgo/v2"
@@ -21,6 +22,7 @@ import (
 type createLineArgs struct {
 	records map[string]interface{}
 	f       config.Format
+	tmpl    string
 	want    string
 	wantErr bool
 }
@@ -50,7 +52,7 @@ type labelMappingArgs struct {
 
 type getDynamicHostNameArgs struct {
 	records map[string]interface{}
-	mapping map[string]interface{}
+	paths   []string
 	want    string
 }
 
@@ -71,7 +73,13 @@ type fallbackToTagWhenMetadataIsMissing struct {
 var _ = Describe("Vali plugin utils", func() {
 	DescribeTable("#createLine",
 		func(args createLineArgs) {
-			got, err := createLine(args.records, args.f)
+			var tmpl *template.Template
+			if args.tmpl != "" {
+				var err error
+				tmpl, err = config.ParseTemplate("test", args.tmpl)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			got, err := createLine(args.records, args.f, tmpl)
 			if args.wantErr {
 				Expect(err).To(HaveOccurred())
 				return
@@ -157,6 +165,41 @@ var _ = Describe("Vali plugin utils", func() {
 				wantErr: false,
 			},
 		),
+		Entry("template",
+			createLineArgs{
+				records: map[string]interface{}{"severity": "INFO", "log": "started\n"},
+				f:       config.TemplateFormat,
+				tmpl:    `{{.severity | lower}} {{trim .log}}`,
+				want:    `info started`,
+				wantErr: false,
+			},
+		),
+		Entry("template with helpers",
+			createLineArgs{
+				records: map[string]interface{}{"log": "0123456789", "map": map[string]interface{}{"foo": "bar"}},
+				f:       config.TemplateFormat,
+				tmpl:    `{{default "-" .missing}} {{trunc 4 .log}} {{trunc -2 .log}} {{toJson .map}}`,
+				want:    `- 0123 89 {"foo":"bar"}`,
+				wantErr: false,
+			},
+		),
+		Entry("template render error",
+			createLineArgs{
+				records: map[string]interface{}{"foo": make(chan interface{})},
+				f:       config.TemplateFormat,
+				tmpl:    `{{toJson .foo}}`,
+				want:    "",
+				wantErr: true,
+			},
+		),
+		Entry("template without template",
+			createLineArgs{
+				records: map[string]interface{}{},
+				f:       config.TemplateFormat,
+				want:    "",
+				wantErr: true,
+			},
+		),
 		Entry("bad format",
 			createLineArgs{
 				records: map[string]interface{}{},
@@ -201,6 +244,26 @@ var _ = Describe("Vali plugin utils", func() {
 		),
 	)
 
+	DescribeTable("#workloadOf",
+		func(records map[string]interface{}, expected workload) {
+			Expect(workloadOf(records)).To(Equal(expected))
+		},
+		Entry("kubernetes metadata",
+			map[string]interface{}{"kubernetes": map[string]interface{}{
+				"namespace_name": "kube-system", "pod_name": "coredns-1", "container_name": []byte("coredns"),
+			}},
+			workload{namespace: "kube-system", pod: "coredns-1", container: "coredns"},
+		),
+		Entry("namespace only",
+			map[string]interface{}{"kubernetes": map[string]interface{}{"namespace_name": "kube-system"}},
+			workload{namespace: "kube-system"},
+		),
+		Entry("no kubernetes metadata",
+			map[string]interface{}{"log": "message", "namespace_name": "kube-system"},
+			workload{},
+		),
+	)
+
 	DescribeTable("#extractLabels",
 		func(args extractLabelsArgs) {
 			got := extractLabels(args.records, args.keys)
@@ -252,7 +315,7 @@ var _ = Describe("Vali plugin utils", func() {
 
 	DescribeTable("#extractLabels",
 		func(args toStringMapArgs) {
-			got := toStringMap(args.record)
+			got := newConverter(nil).toStringMap(args.record)
 			Expect(got).To(Equal(args.want))
 		},
 		Entry("already string",
@@ -338,21 +401,17 @@ var _ = Describe("Vali plugin utils", func() {
 
 	DescribeTable("#getDynamicHostName",
 		func(args getDynamicHostNameArgs) {
-			got := getDynamicHostName(args.records, args.mapping)
+			got := getDynamicHostName(args.records, args.paths)
 			Expect(got).To(Equal(args.want))
 		},
 		Entry("empty record",
 			getDynamicHostNameArgs{
 				records: map[string]interface{}{},
-				mapping: map[string]interface{}{
-					"kubernetes": map[string]interface{}{
-						"namespace_name": "namespace",
-					},
-				},
-				want: "",
+				paths:   []string{"kubernetes.namespace_name"},
+				want:    "",
 			},
 		),
-		Entry("empty mapping",
+		Entry("empty paths",
 			getDynamicHostNameArgs{
 				records: map[string]interface{}{
 					"kubernetes": map[string]interface{}{
@@ -360,8 +419,8 @@ var _ = Describe("Vali plugin utils", func() {
 						"namespace_name": []byte("garden"),
 					},
 				},
-				mapping: map[string]interface{}{},
-				want:    "",
+				paths: []string{},
+				want:  "",
 			},
 		),
 		Entry("empty subrecord",
@@ -371,12 +430,8 @@ var _ = Describe("Vali plugin utils", func() {
 						"foo": []byte("buzz"),
 					},
 				},
-				mapping: map[string]interface{}{
-					"kubernetes": map[string]interface{}{
-						"namespace_name": "namespace",
-					},
-				},
-				want: "",
+				paths: []string{"kubernetes.namespace_name"},
+				want:  "",
 			},
 		),
 		Entry("subrecord",
@@ -387,12 +442,8 @@ var _ = Describe("Vali plugin utils", func() {
 						"namespace_name": []byte("garden"),
 					},
 				},
-				mapping: map[string]interface{}{
-					"kubernetes": map[string]interface{}{
-						"namespace_name": "namespace",
-					},
-				},
-				want: "garden",
+				paths: []string{"kubernetes.namespace_name"},
+				want:  "garden",
 			},
 		),
 		Entry("deep string",
@@ -409,22 +460,46 @@ var _ = Describe("Vali plugin utils", func() {
 						},
 					},
 				},
-				mapping: map[string]interface{}{
+				paths: []string{"kubernetes.label.component.buzz"},
+				want:  "value",
+			}),
+		Entry("first present path",
+			getDynamicHostNameArgs{
+				records: map[string]interface{}{
 					"kubernetes": map[string]interface{}{
-						"label": map[string]interface{}{
-							"component": map[string]interface{}{
-								"buzz": "label",
-							},
-						},
+						"namespace_name": "garden",
+					},
+				},
+				paths: []string{"kubernetes.labels.shoot", "kubernetes.namespace_name"},
+				want:  "garden",
+			}),
+		Entry("paths in order",
+			getDynamicHostNameArgs{
+				records: map[string]interface{}{
+					"kubernetes": map[string]interface{}{
+						"labels":         map[string]interface{}{"shoot": "shoot--dev--test"},
+						"namespace_name": "garden",
+					},
+				},
+				paths: []string{"kubernetes.labels.shoot", "kubernetes.namespace_name"},
+				want:  "shoot--dev--test",
+			}),
+		Entry("path to a map",
+			getDynamicHostNameArgs{
+				records: map[string]interface{}{
+					"kubernetes": map[string]interface{}{
+						"labels":         map[string]interface{}{"shoot": "shoot--dev--test"},
+						"namespace_name": "garden",
 					},
 				},
-				want: "value",
+				paths: []string{"kubernetes.labels", "kubernetes.namespace_name"},
+				want:  "garden",
 			}),
 	)
 
 	DescribeTable("#autoKubernetesLabels",
 		func(args autoKubernetesLabelsArgs) {
-			m := toStringMap(args.records)
+			m := newConverter(nil).toStringMap(args.records)
 			lbs := model.LabelSet{}
 			err := autoLabels(m, lbs)
 			if args.err != nil {
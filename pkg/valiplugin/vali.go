@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"text/template"
 	"time"
 
 	grafanavaliclient "github.com/credativ/vali/pkg/valitail/client"
@@ -19,32 +20,52 @@ import (
 	"github.com/prometheus/common/model"
 	"k8s.io/client-go/tools/cache"
 
+	"github.com/gardener/logging/pkg/chunk"
 	"github.com/gardener/logging/pkg/client"
 	"github.com/gardener/logging/pkg/config"
 	"github.com/gardener/logging/pkg/controller"
 	"github.com/gardener/logging/pkg/metrics"
+	"github.com/gardener/logging/pkg/toptalkers"
 )
 
 // Vali plugin interface
 type Vali interface {
-	SendRecord(r map[interface{}]interface{}, ts time.Time) error
+	SendRecord(r map[interface{}]interface{}, ts time.Time, tag string) error
+	// SendChunk sends the records of a fluent-bit chunk. It returns the records which were sent,
+	// also after a failure, so a retry of the chunk does not send them twice.
+	SendChunk(records []ChunkRecord, tag string) (chunk.Sent, error)
 	Close()
 }
 
 type vali struct {
 	cfg                             *config.Config
+	reloader                        *configReloader
 	defaultClient                   client.ValiClient
 	dynamicHostRegexp               *regexp.Regexp
-	dynamicTenantRegexp             *regexp.Regexp
-	dynamicTenant                   string
-	dynamicTenantField              string
+	dynamicTenant                   *dynamicTenant
+	tagRouting                      *tagRouting
+	logMetrics                      *logMetrics
+	topTalkers                      *toptalkers.Tracker
+	workers                         *workerPool
+	converter                       *converter
+	structuredMetadata              *structuredMetadata
 	extractKubernetesMetadataRegexp *regexp.Regexp
+	processors                      []Processor
+	redactor                        *redactor
+	multiline                       *multiline
+	severity                        *severity
+	timestamp                       *timestamp
+	filter                          *filter
+	podMetadata                     *podMetadata
+	podAnnotations                  *podAnnotations
+	lineTemplate                    *template.Template
 	controller                      controller.Controller
 	logger                          log.Logger
 }
 
-// NewPlugin returns Vali output plugin
-func NewPlugin(informer cache.SharedIndexInformer, cfg *config.Config, logger log.Logger) (Vali, error) {
+// NewPlugin returns Vali output plugin. The targetSource is only used with a DynamicHostPath and
+// the podInformer only when the enrichment with the pod metadata is enabled.
+func NewPlugin(targetSource controller.TargetSource, podInformer cache.SharedIndexInformer, cfg *config.Config, logger log.Logger) (Vali, error) {
 	var err error
 	v := &vali{cfg: cfg, logger: logger}
 
@@ -64,7 +85,9 @@ func NewPlugin(informer cache.SharedIndexInformer, cfg *config.Config, logger lo
 	//TODO(nickytd): Remove this magic check and introduce an Id field in the plugin output configuration
 	// If the plugin ID is "shoot" then we shall have a dynamic host and a default "controller" client
 	if len(cfg.PluginConfig.DynamicHostPath) > 0 {
-		v.dynamicHostRegexp = regexp.MustCompile(cfg.PluginConfig.DynamicHostRegex)
+		if v.dynamicHostRegexp, err = regexp.Compile(cfg.PluginConfig.DynamicHostRegex); err != nil {
+			return nil, fmt.Errorf("failed to compile DynamicHostRegex: %v", err)
+		}
 
 		cfgShallowCopy := *cfg
 		cfgShallowCopy.ClientConfig.BufferConfig.DqueConfig.QueueName = cfg.ClientConfig.BufferConfig.DqueConfig.QueueName + "-controller"
@@ -85,21 +108,91 @@ func NewPlugin(informer cache.SharedIndexInformer, cfg *config.Config, logger lo
 		}
 
 		//  Controller with default client set, is used when to send logs when shoots are not present.
-		if v.controller, err = controller.NewController(informer, cfg, controllerDefaultClient, logger); err != nil {
+		if v.controller, err = controller.NewController(targetSource, cfg, controllerDefaultClient, logger); err != nil {
 			return nil, err
 		}
 	}
 
 	if cfg.PluginConfig.KubernetesMetadata.FallbackToTagWhenMetadataIsMissing {
-		v.extractKubernetesMetadataRegexp = regexp.MustCompile(cfg.PluginConfig.KubernetesMetadata.TagPrefix + cfg.PluginConfig.KubernetesMetadata.TagExpression)
+		if v.extractKubernetesMetadataRegexp, err = regexp.Compile(cfg.PluginConfig.KubernetesMetadata.TagPrefix + cfg.PluginConfig.KubernetesMetadata.TagExpression); err != nil {
+			return nil, fmt.Errorf("failed to compile TagExpression: %v", err)
+		}
+	}
+
+	if v.dynamicTenant, err = newDynamicTenant(cfg.PluginConfig.DynamicTenant); err != nil {
+		return nil, err
+	}
+	v.tagRouting = newTagRouting(cfg.PluginConfig.TagRouting)
+
+	if v.logMetrics, err = newLogMetrics(cfg.PluginConfig.LogMetrics); err != nil {
+		return nil, err
+	}
+	if v.logMetrics != nil {
+		if err = v.logMetrics.register(); err != nil {
+			return nil, fmt.Errorf("failed to register LogMetrics: %v", err)
+		}
+	}
+
+	if conf := cfg.PluginConfig.TopTalkers; conf != nil {
+		if v.topTalkers, err = toptalkers.Shared(conf.Size, conf.Window); err != nil {
+			return nil, err
+		}
+		if v.topTalkers.Size() != conf.Size || v.topTalkers.Window() != conf.Window {
+			_ = level.Warn(logger).Log("msg", "TopTalkers is already enabled by another plugin instance, its settings apply",
+				"size", v.topTalkers.Size(), "window", v.topTalkers.Window())
+		}
+	}
+
+	if v.processors, err = newProcessorChain(cfg.PluginConfig); err != nil {
+		return nil, err
+	}
+
+	if v.redactor, err = newRedactor(cfg.PluginConfig.Redaction); err != nil {
+		return nil, err
+	}
+
+	v.severity = newSeverity(cfg.PluginConfig.Severity)
+	v.timestamp = newTimestamp(cfg.PluginConfig.Timestamp)
+
+	if v.filter, err = newFilter(cfg.PluginConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse DropIf or KeepIf: %v", err)
+	}
+
+	if cfg.PluginConfig.PodMetadata != nil {
+		if podInformer == nil {
+			return nil, fmt.Errorf("pod informer is required when EnrichKubernetesMetadata is enabled")
+		}
+		v.podMetadata = newPodMetadata(cfg.PluginConfig.PodMetadata, podInformer.GetIndexer())
+	}
+
+	if v.lineTemplate, err = newLineTemplate(cfg.PluginConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse LineTemplate: %v", err)
+	}
+
+	v.podAnnotations = newPodAnnotations(cfg.PluginConfig.PodAnnotations)
+	v.converter = newConverter(cfg.PluginConfig.Conversion)
+	v.structuredMetadata = newStructuredMetadata(cfg.PluginConfig.StructuredMetadata)
+
+	if v.multiline, err = newMultiline(cfg.PluginConfig.Multiline, cfg.PluginConfig.PodAnnotations.Allows(config.PodAnnotationMultilinePreset)); err != nil {
+		return nil, err
+	}
+	if v.multiline != nil {
+		v.multiline.startFlusher(v.sendMultilineEntry)
 	}
 
-	if cfg.PluginConfig.DynamicTenant.Tenant != "" && cfg.PluginConfig.DynamicTenant.Field != "" && cfg.PluginConfig.DynamicTenant.Regex != "" {
-		v.dynamicTenantRegexp = regexp.MustCompile(cfg.PluginConfig.DynamicTenant.Regex)
-		v.dynamicTenant = cfg.PluginConfig.DynamicTenant.Tenant
-		v.dynamicTenantField = cfg.PluginConfig.DynamicTenant.Field
+	if cfg.PluginConfig.HotReload {
+		if v.reloader, err = newConfigReloader(v, cfg, logger); err != nil {
+			return nil, err
+		}
+		if cfg.PluginConfig.ReloadConfigPath != "" && !v.reloader.reload() {
+			v.reloader.stop()
+			return nil, fmt.Errorf("failed to load %s", cfg.PluginConfig.ReloadConfigPath)
+		}
+		go v.reloader.run()
 	}
 
+	v.workers = newWorkerPool(cfg.PluginConfig.WorkerPool, v.shardKey, v.sendPrepared)
+
 	_ = level.Info(logger).Log(
 		"msg", "vali plugin created",
 		"default_client_url", v.defaultClient.GetEndPoint(),
@@ -109,50 +202,211 @@ func NewPlugin(informer cache.SharedIndexInformer, cfg *config.Config, logger lo
 }
 
 // SendRecord sends fluent-bit records to vali as an entry.
-func (v *vali) SendRecord(r map[interface{}]interface{}, ts time.Time) error {
-	records := toStringMap(r)
-	//_ = level.Debug(v.logger).Log("msg", "processing records", "records", fluentBitRecords(records))
-	lbs := make(model.LabelSet, v.cfg.PluginConfig.LabelSetInitCapacity)
+func (v *vali) SendRecord(r map[interface{}]interface{}, ts time.Time, tag string) error {
+	records, ok := v.prepareRecord(r, tag)
+	if !ok {
+		return nil
+	}
+	return v.sendPrepared(records, ts)
+}
+
+// SendChunk sends the records of a chunk, in parallel when the worker pool is configured.
+func (v *vali) SendChunk(chunkRecords []ChunkRecord, tag string) (chunk.Sent, error) {
+	if v.workers == nil {
+		for i, r := range chunkRecords {
+			if err := v.SendRecord(r.Record, r.Timestamp, tag); err != nil {
+				return chunk.Sent{Prefix: i}, err
+			}
+		}
+		return chunk.Sent{Prefix: len(chunkRecords)}, nil
+	}
+
+	// The records are prepared on the flush thread, because their shard depends on the kubernetes metadata.
+	prepared := make([]preparedRecord, 0, len(chunkRecords))
+	for i, r := range chunkRecords {
+		if records, ok := v.prepareRecord(r.Record, tag); ok {
+			prepared = append(prepared, preparedRecord{records: records, ts: r.Timestamp, index: i})
+		}
+	}
+	sent, err := v.workers.sendChunk(prepared)
+	if err == nil {
+		return chunk.Sent{Prefix: len(chunkRecords)}, nil
+	}
+	// The dropped records are handled as sent.
+	flags := make([]bool, len(chunkRecords))
+	for i := range flags {
+		flags[i] = true
+	}
+	for i, r := range prepared {
+		flags[r.index] = sent[i]
+	}
+	return chunk.NewSent(flags), err
+}
+
+// shardKey returns the key of the worker shard of the records.
+func (v *vali) shardKey(records map[string]interface{}) string {
+	cfg := v.config()
+	if cfg.PluginConfig.WorkerPool.ShardKey == config.WorkerShardHost {
+		return getDynamicHostName(records, cfg.PluginConfig.DynamicHostPath)
+	}
+	return getStream(records, cfg.PluginConfig.KubernetesMetadata.TagKey)
+}
 
+// prepareRecord converts the record and adds the tag and the kubernetes metadata.
+// It returns false if the record has to be dropped.
+func (v *vali) prepareRecord(r map[interface{}]interface{}, tag string) (map[string]interface{}, bool) {
+	records := v.converter.toStringMap(r)
+	cfg := v.config()
+	if tag != "" {
+		if _, ok := records[cfg.PluginConfig.KubernetesMetadata.TagKey]; !ok && cfg.PluginConfig.InjectTag {
+			records[cfg.PluginConfig.KubernetesMetadata.TagKey] = tag
+		}
+		if v.tagRouting != nil {
+			records[tagRecordKey] = tag
+		}
+	}
+	if !v.addKubernetesMetadata(records, cfg) {
+		return nil, false
+	}
+	return records, true
+}
+
+// sendPrepared sends the prepared records, or holds them back while their multiline entry is pending.
+func (v *vali) sendPrepared(records map[string]interface{}, ts time.Time) error {
+	cfg := v.config()
+	if v.multiline == nil {
+		return v.sendRecord(records, ts)
+	}
+
+	var preset string
+	if v.podAnnotations != nil {
+		preset = v.podAnnotations.of(records)[config.PodAnnotationMultilinePreset]
+	}
+
+	// The lines of a pending multiline entry are held back until the entry is complete.
+	var err error
+	stream := getStream(records, cfg.PluginConfig.KubernetesMetadata.TagKey)
+	for _, e := range v.multiline.add(stream, records, ts, preset) {
+		if sendErr := v.sendRecord(e.records, e.ts); sendErr != nil && err == nil {
+			err = sendErr
+		}
+	}
+	return err
+}
+
+// sendMultilineEntry sends an entry flushed outside of SendRecord, where no error can be returned to fluent-bit.
+// The chunks of its lines were already acknowledged, so a failed entry is dropped.
+func (v *vali) sendMultilineEntry(e multilineEntry) {
+	if err := v.sendRecord(e.records, e.ts); err != nil {
+		metrics.MultilineDroppedEntries.Inc()
+		_ = level.Error(v.logger).Log("msg", "dropped multiline entry", "err", err)
+	}
+}
+
+// addKubernetesMetadata adds the missing kubernetes metadata from the tag and the pod informer.
+// It returns false if the record has to be dropped because the metadata is missing.
+func (v *vali) addKubernetesMetadata(records map[string]interface{}, cfg *config.Config) bool {
 	// Check if metadata is missing
 	_, ok := records["kubernetes"]
-	if !ok && v.cfg.PluginConfig.KubernetesMetadata.FallbackToTagWhenMetadataIsMissing {
+	if !ok && cfg.PluginConfig.KubernetesMetadata.FallbackToTagWhenMetadataIsMissing {
 
 		/*_ = level.Debug(v.logger).Log(
 			"msg", "kubernetes metadata is missing, extracting it from the tag key",
-			"tag", v.cfg.PluginConfig.KubernetesMetadata.TagKey,
+			"tag", cfg.PluginConfig.KubernetesMetadata.TagKey,
 		)*/
 
-		if err := extractKubernetesMetadataFromTag(records, v.cfg.PluginConfig.KubernetesMetadata.TagKey, v.extractKubernetesMetadataRegexp); err != nil {
+		if err := extractKubernetesMetadataFromTag(records, cfg.PluginConfig.KubernetesMetadata.TagKey, v.extractKubernetesMetadataRegexp); err != nil {
 			metrics.Errors.WithLabelValues(metrics.ErrorCanNotExtractMetadataFromTag).Inc()
 
 			_ = level.Error(v.logger).Log("msg", "cannot extract kubernetes metadata", "err", err)
 
-			if v.cfg.PluginConfig.KubernetesMetadata.DropLogEntryWithoutK8sMetadata {
+			if cfg.PluginConfig.KubernetesMetadata.DropLogEntryWithoutK8sMetadata {
 				_ = level.Warn(v.logger).Log(
 					"msg", "kubernetes metadata is missing and the log entry will be dropped",
 					"records", fluentBitRecords(records),
 				)
 				metrics.LogsWithoutMetadata.WithLabelValues(metrics.MissingMetadataType).Inc()
-				return nil
+				return false
 			}
 		}
 	}
 
-	if v.cfg.PluginConfig.AutoKubernetesLabels {
+	if v.podMetadata != nil {
+		v.podMetadata.enrich(records)
+	}
+
+	return true
+}
+
+func (v *vali) sendRecord(records map[string]interface{}, ts time.Time) error {
+	cfg := v.config()
+	//_ = level.Debug(v.logger).Log("msg", "processing records", "records", fluentBitRecords(records))
+	lbs := make(model.LabelSet, cfg.PluginConfig.LabelSetInitCapacity)
+
+	var route *config.TagRoute
+	if v.tagRouting != nil {
+		route = v.tagRouting.pop(records)
+	}
+
+	// The annotations are read before the processors can modify the kubernetes record.
+	var annotations map[string]string
+	if v.podAnnotations != nil {
+		annotations = v.podAnnotations.of(records)
+		if annotation, drop := v.podAnnotations.drop(annotations); drop {
+			metrics.LogsDroppedByAnnotation.WithLabelValues(annotation).Inc()
+			return nil
+		}
+	}
+
+	processed := v.process(records)
+
+	if v.timestamp != nil {
+		var ok bool
+		if ts, ok = v.timestamp.extract(records, ts); !ok {
+			return nil
+		}
+	}
+
+	if cfg.PluginConfig.AutoKubernetesLabels {
 		if err := autoLabels(records, lbs); err != nil {
 			metrics.Errors.WithLabelValues(metrics.ErrorK8sLabelsNotFound).Inc()
 			_ = level.Error(v.logger).Log("msg", err.Error(), "records", fluentBitRecords(records))
 		}
 	}
 
-	if v.cfg.PluginConfig.LabelMap != nil {
-		mapLabels(records, v.cfg.PluginConfig.LabelMap, lbs)
+	if cfg.PluginConfig.LabelMap != nil {
+		mapLabels(records, cfg.PluginConfig.LabelMap, lbs)
 	} else {
-		lbs = extractLabels(records, v.cfg.PluginConfig.LabelKeys)
+		lbs = extractLabels(records, cfg.PluginConfig.LabelKeys)
+	}
+	for name, value := range processed.Labels {
+		lbs[name] = value
+	}
+
+	if v.podAnnotations != nil {
+		if err := v.podAnnotations.addExtraLabels(annotations, lbs); err != nil {
+			_ = level.Warn(v.logger).Log("msg", "ignoring pod annotation", "err", err)
+		}
 	}
 
-	dynamicHostName := getDynamicHostName(records, v.cfg.PluginConfig.DynamicHostPath)
+	if v.severity != nil {
+		severity := v.severity.detect(records)
+		if v.severity.drop(severity, getNamespace(records)) {
+			metrics.LogsDroppedBySeverity.WithLabelValues(severity).Inc()
+			return nil
+		}
+		lbs[v.severity.label] = model.LabelValue(severity)
+	}
+
+	dynamicHostName := getDynamicHostName(records, cfg.PluginConfig.DynamicHostPath)
+	if route != nil {
+		for name, value := range route.Labels {
+			lbs[name] = value
+		}
+		if route.Host != "" {
+			dynamicHostName = route.Host
+		}
+	}
 	host := dynamicHostName
 	if !v.isDynamicHost(host) {
 		host = "garden"
@@ -167,13 +421,35 @@ func (v *vali) SendRecord(r map[interface{}]interface{}, ts time.Time) error {
 	extractMultiTenantClientLabel(records, lbs)
 	removeMultiTenantClientLabel(records)
 
-	removeKeys(records, append(v.cfg.PluginConfig.LabelKeys, v.cfg.PluginConfig.RemoveKeys...))
+	if v.redactor != nil {
+		v.redactor.redact(records, lbs, getNamespace(records))
+	}
+
+	// The attributes are moved out of the records, so they are neither labels nor part of the line.
+	var metadata model.LabelSet
+	if v.structuredMetadata != nil {
+		metadata = v.structuredMetadata.extract(records)
+	}
+
+	// The workload is read before its keys can be removed.
+	var talker workload
+	if v.topTalkers != nil {
+		talker = workloadOf(records)
+	}
+
+	removeKeys(records, append(cfg.PluginConfig.LabelKeys, cfg.PluginConfig.RemoveKeys...))
 	if len(records) == 0 {
 		_ = level.Debug(v.logger).Log("msg", "no records left after removing keys", "host", dynamicHostName)
 		return nil
 	}
 
 	c := v.getClient(dynamicHostName)
+	if route != nil && route.Target != "" {
+		c = v.getTarget(route.Target)
+	}
+	if route != nil && route.Tenant != "" {
+		lbs[grafanavaliclient.ReservedLabelTenantID] = model.LabelValue(route.Tenant)
+	}
 
 	if c == nil {
 		metrics.DroppedLogs.WithLabelValues(host).Inc()
@@ -186,28 +462,44 @@ func (v *vali) SendRecord(r map[interface{}]interface{}, ts time.Time) error {
 		_ = level.Warn(v.logger).Log("err", err)
 	}
 
-	if v.cfg.PluginConfig.DropSingleKey && len(records) == 1 {
+	if lbs = cfg.PluginConfig.RelabelConfigs.Process(lbs); lbs == nil {
+		metrics.DroppedLogs.WithLabelValues(host).Inc()
+		return nil
+	}
+
+	var line string
+	if cfg.PluginConfig.DropSingleKey && len(records) == 1 {
 		for _, record := range records {
-			err := v.send(c, lbs, ts, fmt.Sprintf("%v", record))
-			if err != nil {
-				_ = level.Error(v.logger).Log(
-					"msg", "error sending record to vali",
-					"err", err,
-					"host", dynamicHostName,
-				)
-				metrics.Errors.WithLabelValues(metrics.ErrorSendRecordToVali).Inc()
-			}
-			return err
+			line = fmt.Sprintf("%v", record)
+		}
+	} else {
+		var err error
+		if line, err = createLine(records, cfg.PluginConfig.LineFormat, v.lineTemplate); err != nil {
+			metrics.Errors.WithLabelValues(metrics.ErrorCreateLine).Inc()
+			return fmt.Errorf("error creating line: %v", err)
 		}
 	}
 
-	line, err := createLine(records, v.cfg.PluginConfig.LineFormat)
-	if err != nil {
-		metrics.Errors.WithLabelValues(metrics.ErrorCreateLine).Inc()
-		return fmt.Errorf("error creating line: %v", err)
+	// Vali has no structured metadata, so the attributes are appended to the line.
+	if len(metadata) > 0 {
+		var err error
+		if line, err = v.structuredMetadata.appendTo(line, metadata); err != nil {
+			metrics.Errors.WithLabelValues(metrics.ErrorCreateLine).Inc()
+			return fmt.Errorf("error appending structured metadata: %v", err)
+		}
 	}
 
-	err = v.send(c, lbs, ts, line)
+	// The log metrics count the entries which are dropped by the filters as well.
+	if v.logMetrics != nil {
+		v.logMetrics.observe(lbs, line)
+	}
+
+	// The filters see the final labels and the line as it is sent.
+	if v.filter != nil && v.filter.drop(lbs, line) {
+		return nil
+	}
+
+	err := v.send(c, lbs, ts, line)
 	if err != nil {
 		_ = level.Error(v.logger).Log(
 			"msg", "error sending record to vali",
@@ -219,10 +511,62 @@ func (v *vali) SendRecord(r map[interface{}]interface{}, ts time.Time) error {
 		return err
 	}
 
+	if v.topTalkers != nil {
+		v.topTalkers.Add(host, talker.namespace, talker.pod, talker.container, len(line))
+	}
+
 	return nil
 }
 
+// newProcessorChain returns the configured processors behind the built-in stages.
+func newProcessorChain(conf config.PluginConfig) ([]Processor, error) {
+	processors, err := NewProcessors(conf.Processors)
+	if err != nil {
+		return nil, err
+	}
+	// The embedded payload is parsed first, so the configured processors can use its keys.
+	if p := newParseLogProcessor(conf.ParseLog); p != nil {
+		processors = append([]Processor{p}, processors...)
+	}
+	return processors, nil
+}
+
+// newLineTemplate returns the parsed LineTemplate or nil if the LineFormat is not template.
+func newLineTemplate(conf config.PluginConfig) (*template.Template, error) {
+	if conf.LineFormat != config.TemplateFormat {
+		return nil, nil
+	}
+	return config.ParseTemplate("LineTemplate", conf.LineTemplate)
+}
+
+// process runs the records through the processor chain.
+// A failing stage is counted and the chain continues with the next stage.
+func (v *vali) process(records map[string]interface{}) *Record {
+	record := &Record{Fields: records}
+	for _, p := range v.processors {
+		if err := p.Process(record); err != nil {
+			metrics.Errors.WithLabelValues(metrics.ErrorProcessRecord).Inc()
+			_ = level.Error(v.logger).Log("msg", "error processing record", "err", err)
+		}
+	}
+	return record
+}
+
 func (v *vali) Close() {
+	if v.workers != nil {
+		v.workers.stop()
+	}
+	if v.reloader != nil {
+		v.reloader.stop()
+	}
+	if v.multiline != nil {
+		for _, e := range v.multiline.stop() {
+			v.sendMultilineEntry(e)
+		}
+	}
+	if v.logMetrics != nil {
+		v.logMetrics.unregister()
+	}
 	v.defaultClient.Stop()
 	if v.controller != nil {
 		v.controller.Stop()
@@ -234,6 +578,16 @@ func (v *vali) Close() {
 	)
 }
 
+// config returns the last reloaded configuration or the initial one.
+func (v *vali) config() *config.Config {
+	if v.reloader != nil {
+		if cfg := v.reloader.current.Load(); cfg != nil {
+			return cfg
+		}
+	}
+	return v.cfg
+}
+
 func (v *vali) getClient(dynamicHosName string) client.ValiClient {
 	if v.isDynamicHost(dynamicHosName) && v.controller != nil {
 		if c, isStopped := v.controller.GetClient(dynamicHosName); !isStopped {
@@ -245,6 +599,17 @@ func (v *vali) getClient(dynamicHosName string) client.ValiClient {
 	return v.defaultClient
 }
 
+// getTarget returns the client of the routing target or nil if the controller is stopped.
+func (v *vali) getTarget(name string) client.ValiClient {
+	if v.controller == nil {
+		return nil
+	}
+	if c, isStopped := v.controller.GetTarget(name); !isStopped {
+		return c
+	}
+	return nil
+}
+
 func (v *vali) isDynamicHost(dynamicHostName string) bool {
 	return dynamicHostName != "" &&
 		v.dynamicHostRegexp != nil &&
@@ -252,17 +617,10 @@ func (v *vali) isDynamicHost(dynamicHostName string) bool {
 }
 
 func (v *vali) setDynamicTenant(record map[string]interface{}, lbs model.LabelSet) model.LabelSet {
-	if v.dynamicTenantRegexp == nil {
+	if v.dynamicTenant == nil {
 		return lbs
 	}
-	dynamicTenantFieldValue, ok := record[v.dynamicTenantField]
-	if !ok {
-		return lbs
-	}
-	s, ok := dynamicTenantFieldValue.(string)
-	if ok && v.dynamicTenantRegexp.MatchString(s) {
-		lbs[grafanavaliclient.ReservedLabelTenantID] = model.LabelValue(v.dynamicTenant)
-	}
+	v.dynamicTenant.set(record, lbs)
 	return lbs
 }
 
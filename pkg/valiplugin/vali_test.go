@@ -14,6 +14,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
 	"github.com/weaveworks/common/logging"
 	"k8s.io/utils/pointer"
 
@@ -54,6 +55,7 @@ func (r *recorder) StopWait() {}
 type sendRecordArgs struct {
 	cfg     *config.Config
 	record  map[interface{}]interface{}
+	tag     string
 	want    *entry
 	wantErr bool
 }
@@ -75,6 +77,7 @@ func (c *fakeValiClient) StopWait() {}
 
 type fakeController struct {
 	clients map[string]client.ValiClient
+	targets map[string]client.ValiClient
 }
 
 func (ctl *fakeController) GetClient(name string) (client.ValiClient, bool) {
@@ -84,6 +87,12 @@ func (ctl *fakeController) GetClient(name string) (client.ValiClient, bool) {
 	return nil, false
 }
 
+func (ctl *fakeController) GetTarget(name string) (client.ValiClient, bool) {
+	return ctl.targets[name], false
+}
+
+func (ctl *fakeController) SetRoutingPolicy(_ config.RoutingPolicy) {}
+
 func (ctl *fakeController) Stop() {}
 
 var (
@@ -140,12 +149,26 @@ var _ = Describe("Vali plugin", func() {
 	DescribeTable("#SendRecord",
 		func(args sendRecordArgs) {
 			rec := &recorder{}
+			processors, err := newProcessorChain(args.cfg.PluginConfig)
+			Expect(err).ToNot(HaveOccurred())
+			lineTemplate, err := newLineTemplate(args.cfg.PluginConfig)
+			Expect(err).ToNot(HaveOccurred())
+			filter, err := newFilter(args.cfg.PluginConfig)
+			Expect(err).ToNot(HaveOccurred())
 			l := &vali{
-				cfg:           args.cfg,
-				defaultClient: rec,
-				logger:        logger,
+				cfg:            args.cfg,
+				defaultClient:  rec,
+				converter:      newConverter(args.cfg.PluginConfig.Conversion),
+				processors:     processors,
+				severity:       newSeverity(args.cfg.PluginConfig.Severity),
+				timestamp:      newTimestamp(args.cfg.PluginConfig.Timestamp),
+				lineTemplate:   lineTemplate,
+				filter:         filter,
+				podAnnotations: newPodAnnotations(args.cfg.PluginConfig.PodAnnotations),
+				tagRouting:     newTagRouting(args.cfg.PluginConfig.TagRouting),
+				logger:         logger,
 			}
-			err := l.SendRecord(args.record, now)
+			err = l.SendRecord(args.record, now, args.tag)
 			if args.wantErr {
 				Expect(err).To(HaveOccurred())
 				return
@@ -172,6 +195,241 @@ var _ = Describe("Vali plugin", func() {
 				want:    &entry{model.LabelSet{"A": "A"}, `B=B C=C D=D E=E F=F G=G H=H`, now},
 				wantErr: false,
 			}),
+		Entry("map with processors",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:  []string{"A"},
+						LineFormat: config.JSONFormat,
+						RemoveKeys: []string{"C", "D", "E", "F", "G"},
+						Processors: []config.ProcessorConfig{
+							{Type: config.ProcessorRename, Field: "B", Target: "b"},
+							{Type: config.ProcessorSetLabel, Label: "h", Field: "H"},
+							{Type: config.ProcessorDropField, Field: "H"},
+						},
+					},
+				},
+				record:  mapRecordFixture,
+				want:    &entry{model.LabelSet{"A": "A", "h": "H"}, `{"b":"B"}`, now},
+				wantErr: false,
+			}),
+		Entry("embedded json",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:  []string{"level"},
+						LineFormat: config.JSONFormat,
+						ParseLog:   &config.ParseLogConfig{Format: config.ParseLogAuto, Key: "log"},
+					},
+				},
+				record:  map[interface{}]interface{}{"log": []byte(`{"level":"info","msg":"started","took":12}` + "\n")},
+				want:    &entry{model.LabelSet{"level": "info"}, `{"msg":"started","took":12}`, now},
+				wantErr: false,
+			}),
+		Entry("map with detected level",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:  []string{"A"},
+						LineFormat: config.KvPairFormat,
+						Severity:   &config.SeverityConfig{Label: "level", Keys: config.DefaultLevelKeys, MessageKey: "B"},
+					},
+				},
+				record:  map[interface{}]interface{}{"A": "A", "B": "level=Warning msg=slow"},
+				want:    &entry{model.LabelSet{"A": "A", "level": "warn"}, `B="level=Warning msg=slow"`, now},
+				wantErr: false,
+			}),
+		Entry("drop below the level threshold",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:  []string{"A"},
+						LineFormat: config.JSONFormat,
+						Severity: &config.SeverityConfig{
+							Label:      "level",
+							Keys:       config.DefaultLevelKeys,
+							MessageKey: "B",
+							Thresholds: map[string]string{config.AllNamespaces: config.LevelWarn},
+						},
+					},
+				},
+				record:  map[interface{}]interface{}{"A": "A", "B": "I0102 15:04:05.000000 1 main.go:10] started"},
+				want:    nil,
+				wantErr: false,
+			}),
+		Entry("map to template",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:    []string{"A"},
+						LineFormat:   config.TemplateFormat,
+						LineTemplate: `{{.B | lower}} {{.severity | default "info"}} {{trunc 3 .log}}`,
+					},
+				},
+				record:  map[interface{}]interface{}{"A": "A", "B": "B", "log": []byte("started\n")},
+				want:    &entry{model.LabelSet{"A": "A"}, `b info sta`, now},
+				wantErr: false,
+			}),
+		Entry("template render error",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:    []string{"A"},
+						LineFormat:   config.TemplateFormat,
+						LineTemplate: `{{toJson .error}}`,
+					},
+				},
+				record:  map[interface{}]interface{}{"A": "A", "error": make(chan struct{})},
+				wantErr: true,
+			}),
+		Entry("timestamp from record key",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:  []string{"A"},
+						LineFormat: config.JSONFormat,
+						Timestamp:  &config.TimestampConfig{Key: "time", Format: config.TimestampRFC3339, Fallback: config.TimestampFallbackEventTime},
+					},
+				},
+				record:  map[interface{}]interface{}{"A": "A", "time": []byte("2024-03-10T11:59:30.5Z")},
+				want:    &entry{model.LabelSet{"A": "A"}, `{"time":"2024-03-10T11:59:30.5Z"}`, time.Date(2024, time.March, 10, 11, 59, 30, 500000000, time.UTC)},
+				wantErr: false,
+			}),
+		Entry("dropped by a DropIf rule",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:  []string{"A"},
+						LineFormat: config.JSONFormat,
+						DropIf:     config.FilterRules{"noise": `{A="A"} |= "\"H\":\"H\""`},
+					},
+				},
+				record:  mapRecordFixture,
+				want:    nil,
+				wantErr: false,
+			}),
+		Entry("kept by a KeepIf rule",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:     []string{"A"},
+						LineFormat:    config.JSONFormat,
+						DropSingleKey: true,
+						RemoveKeys:    []string{"C", "D", "E", "F", "G", "H"},
+						KeepIf:        config.FilterRules{"b": `{A="A"} |= "B"`},
+					},
+				},
+				record:  mapRecordFixture,
+				want:    &entry{model.LabelSet{"A": "A"}, `B`, now},
+				wantErr: false,
+			}),
+		Entry("extra labels from the pod annotation",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:  []string{"A"},
+						LineFormat: config.JSONFormat,
+						RemoveKeys: []string{"kubernetes"},
+						PodAnnotations: &config.PodAnnotationsConfig{
+							Annotations: []string{config.PodAnnotationExtraLabels},
+							Namespaces:  []string{config.AllNamespaces},
+						},
+					},
+				},
+				record: map[interface{}]interface{}{"A": "A", "B": "B", "kubernetes": map[interface{}]interface{}{
+					"namespace_name": "garden",
+					"annotations":    map[interface{}]interface{}{"logging.gardener.cloud/extra-labels": "team=logging"},
+				}},
+				want:    &entry{model.LabelSet{"A": "A", "team": "logging"}, `{"B":"B"}`, now},
+				wantErr: false,
+			}),
+		Entry("dropped by the pod annotation",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:  []string{"A"},
+						LineFormat: config.JSONFormat,
+						PodAnnotations: &config.PodAnnotationsConfig{
+							Annotations: []string{config.PodAnnotationDrop},
+							Namespaces:  []string{"garden"},
+						},
+					},
+				},
+				record: map[interface{}]interface{}{"A": "A", "kubernetes": map[interface{}]interface{}{
+					"namespace_name": "garden",
+					"annotations":    map[interface{}]interface{}{"logging.gardener.cloud/drop": "true"},
+				}},
+				want:    nil,
+				wantErr: false,
+			}),
+		Entry("labels and tenant of the tag route with the injected tag",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:          []string{"tag"},
+						LineFormat:         config.JSONFormat,
+						KubernetesMetadata: config.KubernetesMetadataExtraction{TagKey: "tag"},
+						InjectTag:          true,
+						TagRouting: []config.TagRoute{
+							{Match: "kube.*", Labels: model.LabelSet{"origin": "container"}},
+							{Match: "journald.*", Labels: model.LabelSet{"origin": "journald"}, Tenant: "operator"},
+						},
+					},
+				},
+				record: map[interface{}]interface{}{"MESSAGE": "Started kubelet"},
+				tag:    "journald.kubelet",
+				want: &entry{
+					model.LabelSet{"tag": "journald.kubelet", "origin": "journald", "__tenant_id__": "operator"},
+					`{"MESSAGE":"Started kubelet"}`,
+					now,
+				},
+				wantErr: false,
+			}),
+		Entry("tag without a matching route",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:  []string{"A"},
+						LineFormat: config.JSONFormat,
+						TagRouting: []config.TagRoute{{Match: "journald.*", Labels: model.LabelSet{"origin": "journald"}}},
+					},
+				},
+				record:  mapRecordFixture,
+				tag:     "kube.var.log.containers.app.log",
+				want:    &entry{model.LabelSet{"A": "A"}, `{"B":"B","C":"C","D":"D","E":"E","F":"F","G":"G","H":"H"}`, now},
+				wantErr: false,
+			}),
+		Entry("relabeled labels",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:  []string{"A", "B"},
+						LineFormat: config.JSONFormat,
+						RelabelConfigs: config.RelabelConfigs{
+							{SourceLabels: model.LabelNames{"A", "B"}, Separator: "-", Regex: relabel.MustNewRegexp("(.*)"), TargetLabel: "AB", Replacement: "$1", Action: relabel.Replace},
+							{Regex: relabel.MustNewRegexp("B"), Action: relabel.LabelDrop},
+						},
+					},
+				},
+				record:  mapRecordFixture,
+				want:    &entry{model.LabelSet{"A": "A", "AB": "A-B"}, `{"C":"C","D":"D","E":"E","F":"F","G":"G","H":"H"}`, now},
+				wantErr: false,
+			}),
+		Entry("dropped by relabeling",
+			sendRecordArgs{
+				cfg: &config.Config{
+					PluginConfig: config.PluginConfig{
+						LabelKeys:  []string{"A"},
+						LineFormat: config.JSONFormat,
+						RelabelConfigs: config.RelabelConfigs{
+							{SourceLabels: model.LabelNames{"A"}, Separator: ";", Regex: relabel.MustNewRegexp("A"), Action: relabel.Drop},
+						},
+					},
+				},
+				record:  mapRecordFixture,
+				want:    nil,
+				wantErr: false,
+			}),
 		Entry(
 			"not enough records",
 			sendRecordArgs{
@@ -257,6 +515,41 @@ var _ = Describe("Vali plugin", func() {
 		),
 	)
 
+	Describe("tag routing", func() {
+		It("should send the records of a routed tag to the target", func() {
+			defaultClient, target, shoot := &recorder{}, &recorder{}, &recorder{}
+			cfg := &config.Config{PluginConfig: config.PluginConfig{
+				LineFormat:      config.JSONFormat,
+				DynamicHostPath: []string{"kubernetes.namespace_name"},
+				TagRouting: []config.TagRoute{
+					{Match: "journald.*", Target: "audit"},
+					{Match: "node.*", Host: "shoot--dev--test"},
+				},
+			}}
+			l := &vali{
+				cfg:               cfg,
+				defaultClient:     defaultClient,
+				converter:         newConverter(nil),
+				dynamicHostRegexp: regexp.MustCompile("^shoot--"),
+				controller: &fakeController{
+					clients: map[string]client.ValiClient{"shoot--dev--test": shoot},
+					targets: map[string]client.ValiClient{"audit": target},
+				},
+				tagRouting: newTagRouting(cfg.PluginConfig.TagRouting),
+				logger:     logger,
+			}
+
+			Expect(l.SendRecord(map[interface{}]interface{}{"log": "audit"}, now, "journald.kubelet")).To(Succeed())
+			Expect(target.toEntry()).To(Equal(&entry{model.LabelSet{}, `{"log":"audit"}`, now}))
+
+			Expect(l.SendRecord(map[interface{}]interface{}{"log": "node"}, now, "node.kubelet")).To(Succeed())
+			Expect(shoot.toEntry()).To(Equal(&entry{model.LabelSet{}, `{"log":"node"}`, now}))
+
+			Expect(l.SendRecord(map[interface{}]interface{}{"log": "default"}, now, "kube.var.log")).To(Succeed())
+			Expect(defaultClient.toEntry()).To(Equal(&entry{model.LabelSet{}, `{"log":"default"}`, now}))
+		})
+	})
+
 	Describe("#getClient", func() {
 		fc := fakeController{
 			clients: map[string]client.ValiClient{
@@ -327,10 +620,10 @@ var _ = Describe("Vali plugin", func() {
 			Entry("Existing field with maching regex",
 				setDynamicTenantArgs{
 					valiplugin: vali{
-						dynamicTenantRegexp: regexp.MustCompile("user-exposed.kubernetes"),
-						dynamicTenant:       "test-user",
-						dynamicTenantField:  "tag",
-						defaultClient:       &fakeValiClient{},
+						dynamicTenant: &dynamicTenant{rules: []*dynamicTenantRule{
+							{field: "tag", regex: regexp.MustCompile("user-exposed.kubernetes"), tenant: "test-user"},
+						}},
+						defaultClient: &fakeValiClient{},
 					},
 					labelSet: model.LabelSet{
 						"foo": "bar",
@@ -356,10 +649,10 @@ var _ = Describe("Vali plugin", func() {
 			Entry("Existing field with no maching regex",
 				setDynamicTenantArgs{
 					valiplugin: vali{
-						dynamicTenantRegexp: regexp.MustCompile("user-exposed.kubernetes"),
-						dynamicTenant:       "test-user",
-						dynamicTenantField:  "tag",
-						defaultClient:       &fakeValiClient{},
+						dynamicTenant: &dynamicTenant{rules: []*dynamicTenantRule{
+							{field: "tag", regex: regexp.MustCompile("user-exposed.kubernetes"), tenant: "test-user"},
+						}},
+						defaultClient: &fakeValiClient{},
 					},
 					labelSet: model.LabelSet{
 						"foo": "bar",
@@ -384,10 +677,10 @@ var _ = Describe("Vali plugin", func() {
 			Entry("Not Existing field with maching regex",
 				setDynamicTenantArgs{
 					valiplugin: vali{
-						dynamicTenantRegexp: regexp.MustCompile("user-exposed.kubernetes"),
-						dynamicTenant:       "test-user",
-						dynamicTenantField:  "tag",
-						defaultClient:       &fakeValiClient{},
+						dynamicTenant: &dynamicTenant{rules: []*dynamicTenantRule{
+							{field: "tag", regex: regexp.MustCompile("user-exposed.kubernetes"), tenant: "test-user"},
+						}},
+						defaultClient: &fakeValiClient{},
 					},
 					labelSet: model.LabelSet{
 						"foo": "bar",
@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/gardener/logging/pkg/config"
+)
+
+// ChunkRecord is a record of a fluent-bit chunk with its timestamp.
+type ChunkRecord struct {
+	Record    map[interface{}]interface{}
+	Timestamp time.Time
+}
+
+// preparedRecord is a record with its kubernetes metadata, ready to be processed.
+type preparedRecord struct {
+	records map[string]interface{}
+	ts      time.Time
+	// index is the index of the record in its chunk.
+	index int
+}
+
+// workerPool processes the records of a chunk in parallel. The records are sharded by their
+// stream or dynamic host, so the records of a shard are processed in order by the same worker.
+type workerPool struct {
+	queues []chan workerTask
+	key    func(records map[string]interface{}) string
+	send   func(records map[string]interface{}, ts time.Time) error
+	wg     sync.WaitGroup
+}
+
+type workerTask struct {
+	record preparedRecord
+	index  int
+	chunk  *workerChunk
+}
+
+// workerChunk collects the result of the records of a chunk.
+type workerChunk struct {
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	sent    []bool
+	err     error
+	aborted bool
+}
+
+func newWorkerPool(conf *config.WorkerPoolConfig, key func(map[string]interface{}) string, send func(map[string]interface{}, time.Time) error) *workerPool {
+	if conf == nil {
+		return nil
+	}
+	p := &workerPool{
+		queues: make([]chan workerTask, conf.Workers),
+		key:    key,
+		send:   send,
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan workerTask, conf.QueueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+// sendChunk processes the records and waits for their result. It returns which records were sent.
+// After a failure the queued records are dropped and no further records are queued, but the
+// workers of the other shards may already have sent records after the failed one.
+func (p *workerPool) sendChunk(records []preparedRecord) ([]bool, error) {
+	c := &workerChunk{sent: make([]bool, len(records))}
+	for i, r := range records {
+		if c.isAborted() {
+			break
+		}
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(p.key(r.records)))
+		c.wg.Add(1)
+		// A full queue blocks until the worker catches up.
+		p.queues[h.Sum32()%uint32(len(p.queues))] <- workerTask{record: r, index: i, chunk: c}
+	}
+	c.wg.Wait()
+	return c.sent, c.err
+}
+
+func (p *workerPool) work(queue chan workerTask) {
+	defer p.wg.Done()
+	for t := range queue {
+		if !t.chunk.isAborted() {
+			t.chunk.done(t.index, p.send(t.record.records, t.record.ts))
+		}
+		t.chunk.wg.Done()
+	}
+}
+
+func (c *workerChunk) isAborted() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.aborted
+}
+
+// done records the result of the record at index. An error aborts the chunk.
+func (c *workerChunk) done(index int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.sent[index] = true
+		return
+	}
+	if c.err == nil {
+		c.err = err
+	}
+	c.aborted = true
+}
+
+// stop waits for the queued records and stops the workers.
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
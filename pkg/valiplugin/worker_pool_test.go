@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package valiplugin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/model"
+
+	chunkpkg "github.com/gardener/logging/pkg/chunk"
+	"github.com/gardener/logging/pkg/config"
+)
+
+// linesClient keeps the lines it receives and fails the lines containing "fail".
+type linesClient struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (c *linesClient) GetEndPoint() string { return "http://localhost" }
+
+func (c *linesClient) Handle(_ model.LabelSet, _ time.Time, line string) error {
+	if strings.Contains(line, "fail") {
+		return errors.New("failed to send")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines = append(c.lines, line)
+	return nil
+}
+
+func (c *linesClient) Stop()     {}
+func (c *linesClient) StopWait() {}
+
+var _ = Describe("WorkerPool", func() {
+	stream := func(records map[string]interface{}) string { return records["stream"].(string) }
+	chunk := func(n, streams int) []preparedRecord {
+		res := make([]preparedRecord, n)
+		for i := range res {
+			res[i] = preparedRecord{records: map[string]interface{}{"stream": fmt.Sprintf("stream-%d", i%streams), "index": i}, index: i}
+		}
+		return res
+	}
+
+	It("should keep the order of the records of each stream", func() {
+		var mu sync.Mutex
+		streams := map[string][]int{}
+		p := newWorkerPool(&config.WorkerPoolConfig{Workers: 4, QueueSize: 2}, stream, func(records map[string]interface{}, _ time.Time) error {
+			mu.Lock()
+			defer mu.Unlock()
+			streams[stream(records)] = append(streams[stream(records)], records["index"].(int))
+			return nil
+		})
+		defer p.stop()
+
+		sent, err := p.sendChunk(chunk(500, 7))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sent).To(HaveLen(500))
+		Expect(sent).ToNot(ContainElement(false))
+		Expect(streams).To(HaveLen(7))
+		total := 0
+		for s, indices := range streams {
+			total += len(indices)
+			for i := 1; i < len(indices); i++ {
+				Expect(indices[i]).To(BeNumerically(">", indices[i-1]), s)
+			}
+		}
+		Expect(total).To(Equal(500))
+	})
+
+	It("should return which records were sent", func() {
+		var mu sync.Mutex
+		sent := map[int]bool{}
+		p := newWorkerPool(&config.WorkerPoolConfig{Workers: 3, QueueSize: 1}, stream, func(records map[string]interface{}, _ time.Time) error {
+			if records["index"].(int) == 40 {
+				return errors.New("failed to send")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			sent[records["index"].(int)] = true
+			return nil
+		})
+		defer p.stop()
+
+		flags, err := p.sendChunk(chunk(100, 5))
+		Expect(err).To(MatchError("failed to send"))
+		Expect(flags[40]).To(BeFalse())
+		for i, flag := range flags {
+			Expect(sent[i]).To(Equal(flag), fmt.Sprintf("record %d", i))
+		}
+		// The records of the failed stream are not sent after the failed one.
+		for i := 45; i < 100; i += 5 {
+			Expect(sent).ToNot(HaveKey(i))
+		}
+	})
+
+	It("should report the records which another shard sent after a failure", func() {
+		// The streams a and b are processed by different workers.
+		records := make([]preparedRecord, 6)
+		for i := range records {
+			s := "a"
+			if i >= 3 {
+				s = "b"
+			}
+			records[i] = preparedRecord{records: map[string]interface{}{"stream": s, "index": i}, index: i}
+		}
+		sending, failed := make(chan struct{}), make(chan struct{})
+		p := newWorkerPool(&config.WorkerPoolConfig{Workers: 2, QueueSize: 3}, stream, func(records map[string]interface{}, _ time.Time) error {
+			switch records["index"].(int) {
+			case 2:
+				<-sending
+				defer close(failed)
+				return errors.New("failed to send")
+			case 5:
+				close(sending)
+				<-failed
+			}
+			return nil
+		})
+		defer p.stop()
+
+		flags, err := p.sendChunk(records)
+		Expect(err).To(MatchError("failed to send"))
+		Expect(flags).To(Equal([]bool{true, true, false, true, true, true}))
+		Expect(chunkpkg.NewSent(flags)).To(Equal(chunkpkg.Sent{Prefix: 2, After: []int{3, 4, 5}}))
+	})
+
+	It("should send the chunk with the pool of the plugin", func() {
+		c := &linesClient{}
+		cfg := &config.Config{PluginConfig: config.PluginConfig{
+			LineFormat: config.JSONFormat,
+			WorkerPool: &config.WorkerPoolConfig{Workers: 2, QueueSize: 4, ShardKey: config.WorkerShardStream},
+		}}
+		l := &vali{cfg: cfg, defaultClient: c, converter: newConverter(nil), logger: logger}
+		l.workers = newWorkerPool(cfg.PluginConfig.WorkerPool, l.shardKey, l.sendPrepared)
+		defer l.workers.stop()
+
+		var records []ChunkRecord
+		for i := 0; i < 10; i++ {
+			records = append(records, ChunkRecord{Record: map[interface{}]interface{}{
+				"log":        fmt.Sprintf("line %d", i),
+				"kubernetes": map[interface{}]interface{}{"namespace_name": "garden", "pod_name": fmt.Sprintf("pod-%d", i%2)},
+			}, Timestamp: now})
+		}
+		sent, err := l.SendChunk(records, "kubernetes.var.log")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sent).To(Equal(chunkpkg.Sent{Prefix: 10}))
+		Expect(c.lines).To(HaveLen(10))
+
+		records[0].Record["log"] = "fail"
+		sent, err = l.SendChunk(records[:1], "kubernetes.var.log")
+		Expect(err).To(HaveOccurred())
+		Expect(sent).To(Equal(chunkpkg.Sent{}))
+	})
+
+	It("should send the chunk sequentially without pool", func() {
+		c := &linesClient{}
+		l := &vali{cfg: &config.Config{PluginConfig: config.PluginConfig{LineFormat: config.JSONFormat}}, defaultClient: c, converter: newConverter(nil), logger: logger}
+		records := []ChunkRecord{
+			{Record: map[interface{}]interface{}{"log": "first"}, Timestamp: now},
+			{Record: map[interface{}]interface{}{"log": "second"}, Timestamp: now},
+			{Record: map[interface{}]interface{}{"log": "fail"}, Timestamp: now},
+			{Record: map[interface{}]interface{}{"log": "fourth"}, Timestamp: now},
+		}
+		sent, err := l.SendChunk(records, "")
+		Expect(err).To(HaveOccurred())
+		Expect(sent).To(Equal(chunkpkg.Sent{Prefix: 2}))
+		Expect(c.lines).To(Equal([]string{`{"log":"first"}`, `{"log":"second"}`}))
+	})
+})
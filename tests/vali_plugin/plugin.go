@@ -11,6 +11,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
 
 	"github.com/gardener/logging/pkg/config"
+	"github.com/gardener/logging/pkg/controller"
 	"github.com/gardener/logging/pkg/valiplugin"
 	plugintestclient "github.com/gardener/logging/tests/vali_plugin/plugintest/client"
 	plugintestcluster "github.com/gardener/logging/tests/vali_plugin/plugintest/cluster"
@@ -48,7 +49,7 @@ func main() {
 
 	fakeInformer.Synced = true
 	fmt.Println("Creating new plugin")
-	plugin, err = valiplugin.NewPlugin(fakeInformer, &valiPluginConfiguration, logger)
+	plugin, err = valiplugin.NewPlugin(controller.NewClusterSource(fakeInformer, logger), nil, &valiPluginConfiguration, logger)
 	if err != nil {
 		panic(err)
 	}
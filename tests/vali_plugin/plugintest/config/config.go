@@ -70,13 +70,9 @@ func NewConfiguration() (config.Config, error) {
 				"severity": "severity",
 				"job":      "job",
 			},
-			LineFormat:    config.KvPairFormat,
-			DropSingleKey: false,
-			DynamicHostPath: map[string]interface{}{
-				"kubernetes": map[string]interface{}{
-					"namespace_name": "namespace",
-				},
-			},
+			LineFormat:       config.KvPairFormat,
+			DropSingleKey:    false,
+			DynamicHostPath:  []string{"kubernetes.namespace_name"},
 			DynamicHostRegex: "^shoot-",
 			KubernetesMetadata: config.KubernetesMetadataExtraction{
 				FallbackToTagWhenMetadataIsMissing: true,
@@ -86,9 +82,8 @@ func NewConfiguration() (config.Config, error) {
 				TagExpression:                      "\\.([^_]+)_([^_]+)_(.+)-([a-z0-9]{64})\\.log$",
 			},
 			DynamicTenant: config.DynamicTenant{
-				Tenant:                                "user",
-				Field:                                 "gardenuser",
-				Regex:                                 "user",
+				Rules:                                 []config.DynamicTenantRule{{Tenant: "user", Field: "gardenuser", Regex: "user"}},
+				Match:                                 config.DynamicTenantMatchFirst,
 				RemoveTenantIdWhenSendingToDefaultURL: false,
 			},
 			LabelSetInitCapacity: 12,
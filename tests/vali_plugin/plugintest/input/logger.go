@@ -57,7 +57,7 @@ func (c *LoggerController) worker(pod Pod) {
 	//fmt.Println("Pod start to logs ", c.config.NumberOfLogs, " of logs")
 	for i := 0; i < c.config.NumberOfLogs; i++ {
 		record := pod.GenerateLogRecord()
-		err := c.plugin.SendRecord(record, time.Now())
+		err := c.plugin.SendRecord(record, time.Now(), "")
 		if err != nil {
 			panic(err)
 		}